@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/dstockto/csv-chef/recipe"
@@ -45,32 +46,87 @@ var (
 	delimiter       string
 	inputDelimiter  string
 	outputDelimiter string
+	parameterSets   []string
+	maxGroups       int
+	dedupeKey       string
+	dedupeKeep      string
+	sortBy          []string
+	traceLines      []int
+	bakeSchema      string
+	bakeViolations  string
+	bakeFailFast    bool
 )
 
-// resolveDelimiter converts a delimiter flag string to a rune. The literal
+// parseDelimiter converts a delimiter setting to a rune. The literal
 // two-character string `\t` is interpreted as a tab. Otherwise the string
-// must be exactly one rune. On invalid input the program exits non-zero.
-func resolveDelimiter(name, value string) rune {
+// must be exactly one rune.
+func parseDelimiter(name, value string) (rune, error) {
 	if value == `\t` {
-		return '\t'
+		return '\t', nil
 	}
 	if utf8.RuneCountInString(value) != 1 {
-		log.Errorf("%s must be a single character (or the literal \\t for tab), got %q", name, value)
+		return 0, fmt.Errorf("%s must be a single character (or the literal \\t for tab), got %q", name, value)
+	}
+	return []rune(value)[0], nil
+}
+
+// resolveDelimiter converts a delimiter flag string to a rune like
+// parseDelimiter. On invalid input the program exits non-zero.
+func resolveDelimiter(name, value string) rune {
+	comma, err := parseDelimiter(name, value)
+	if err != nil {
+		log.Errorf("%v", err)
 		os.Exit(9)
 	}
-	return []rune(value)[0]
+	return comma
 }
 
-// effectiveDelimiter returns the specific override if set, else the shared
+// delimiterFor returns the specific override if set, else the shared
 // delimiter if set, else a comma.
-func effectiveDelimiter(name, specific, shared string) rune {
+func delimiterFor(name, specific, shared string) (rune, error) {
 	if specific != "" {
-		return resolveDelimiter(name, specific)
+		return parseDelimiter(name, specific)
 	}
 	if shared != "" {
-		return resolveDelimiter("--delimiter", shared)
+		return parseDelimiter("--delimiter", shared)
 	}
-	return ','
+	return ',', nil
+}
+
+// effectiveDelimiter is delimiterFor for flags, exiting non-zero on invalid
+// input.
+func effectiveDelimiter(name, specific, shared string) rune {
+	comma, err := delimiterFor(name, specific, shared)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(9)
+	}
+	return comma
+}
+
+// describeRecipeError describes an error from reading a recipe file. Parse
+// errors name the file and show the offending line with a caret under the
+// problem.
+func describeRecipeError(err error, path string) string {
+	if parseErr, ok := err.(*recipe.ParseError); ok {
+		parseErr.File = path
+		return "\n" + parseErr.Render()
+	}
+	return err.Error()
+}
+
+// parseParameterSets converts repeated --set name=value flags into a map of
+// parameter values. The name may be given with or without the leading %.
+func parseParameterSets(sets []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, set := range sets {
+		eq := strings.Index(set, "=")
+		if eq < 1 {
+			return nil, fmt.Errorf("--set expects name=value, got %q", set)
+		}
+		values[strings.TrimPrefix(set[:eq], "%")] = set[eq+1:]
+	}
+	return values, nil
 }
 
 // bakeCmd represents the bake command
@@ -82,10 +138,171 @@ output file where each output line can be manipulated according to the rules you
 created in the recipe file. Please see the README for how to make recipes. The -f flag can be used to
 overwrite the output file if it exists. The -d flag will disable processing of headers with header rules 
 for the first line of the file. The -n flag can tag a number representing the maximum number of lines
-to process from the input file. This can be helpful if you are testing a recipe and the input file is large.'`,
+to process from the input file. This can be helpful if you are testing a recipe and the input file is large.
+Recipe parameters (%name) are supplied with --set name=value or the CSVCHEF_PARAM_NAME environment variable.
+With --schema, only input rows that match the schema are baked; see validate.'`,
 	Run: runBake,
 }
 
+// bakeOptions are the settings of one bake other than its input and
+// output, whether they come from flags or from a pipeline step.
+type bakeOptions struct {
+	recipePath        string
+	lines             int
+	noHeader          bool
+	parseErrorIsError bool
+	sanitize          bool
+	inputDelimiter    rune
+	outputDelimiter   rune
+	parameters        map[string]string
+	maxGroups         int
+	dedupeKey         string
+	dedupeKeep        string
+	sortBy            []string
+	traceLines        []int
+	schema            string
+	violations        string
+	failFast          bool
+	// status receives traces and the violations found with schema when
+	// they aren't written to a file.
+	status io.Writer
+}
+
+// bakeResult summarizes a finished bake.
+type bakeResult struct {
+	*recipe.TransformationResult
+	// Duplicates is the number of rows dropped by dedupeKey.
+	Duplicates int
+	// Checked is true when the input was checked against a schema.
+	Checked bool
+	// SchemaSkipped is the number of input lines left out for not
+	// matching the schema.
+	SchemaSkipped int
+}
+
+// bakeError is an error from bake along with the exit status the bake
+// command uses for it.
+type bakeError struct {
+	code int
+	err  error
+}
+
+func (e *bakeError) Error() string {
+	return e.err.Error()
+}
+
+func bakeErrorf(code int, format string, args ...interface{}) error {
+	return &bakeError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// bake transforms the CSV read from in into out with the recipe and
+// settings in options.
+func bake(in io.Reader, out io.Writer, options bakeOptions) (*bakeResult, error) {
+	recipeFile, err := os.Open(options.recipePath)
+	if err != nil {
+		return nil, bakeErrorf(6, "Unable to open recipe file: %v", err)
+	}
+	defer func() { _ = recipeFile.Close() }()
+
+	transformer, err := recipe.Parse(recipeFile)
+	if err != nil {
+		return nil, bakeErrorf(7, "Error processing your recipe: %s", describeRecipeError(err, options.recipePath))
+	}
+	if err := transformer.BindParameters(options.parameters, os.LookupEnv); err != nil {
+		return nil, bakeErrorf(7, "Error processing your recipe: %v", err)
+	}
+
+	transformer.Sanitize = options.sanitize
+	transformer.TypeErrorLog = options.status
+	transformer.MaxGroupsInMemory = options.maxGroups
+	if len(options.traceLines) > 0 {
+		transformer.Tracer = recipe.NewLineTracer(options.status, options.traceLines...)
+	}
+
+	// Don't count the header
+	lines := options.lines
+	if lines > 0 && !options.noHeader {
+		lines++
+	}
+
+	r := csv.NewReader(in)
+	r.Comma = options.inputDelimiter
+
+	var checker *contractChecker
+	if options.schema != "" {
+		var closeViolations func() error
+		checker, closeViolations, err = openContractChecker(options.schema, options.violations, options.status, options.failFast)
+		if err != nil {
+			return nil, &bakeError{code: 7, err: err}
+		}
+		defer func() { _ = closeViolations() }()
+		transformer.Filter = checker
+		// let the schema report rows with the wrong number of columns
+		r.FieldsPerRecord = -1
+	}
+
+	w := csv.NewWriter(out)
+	w.Comma = options.outputDelimiter
+
+	var rowWriter recipe.RowWriter = w
+	var sorter *recipe.RowSorter
+	if len(options.sortBy) > 0 {
+		var keys []recipe.SortKey
+		for _, spec := range options.sortBy {
+			key, err := recipe.ParseSortKey(spec)
+			if err != nil {
+				return nil, bakeErrorf(7, "Error in --sort-by: %v", err)
+			}
+			keys = append(keys, key)
+		}
+		sorter = recipe.NewRowSorter(rowWriter, keys, !options.noHeader, 0)
+		defer func() { _ = sorter.Discard() }()
+		rowWriter = sorter
+	}
+
+	var deduper *recipe.Deduper
+	if options.dedupeKey != "" {
+		if options.dedupeKeep != "first" && options.dedupeKeep != "last" {
+			return nil, bakeErrorf(1, "--dedupe-keep must be first or last, got %q", options.dedupeKeep)
+		}
+		key, err := recipe.NewExpression(options.dedupeKey)
+		if err != nil {
+			return nil, bakeErrorf(7, "Error in --dedupe-key expression: %v", err)
+		}
+		deduper = recipe.NewDeduper(rowWriter, key, options.dedupeKeep == "last", !options.noHeader, 0)
+		defer func() { _ = deduper.Discard() }()
+		rowWriter = deduper
+	}
+
+	result, err := transformer.Execute(r, rowWriter, !options.noHeader, lines, options.parseErrorIsError)
+	if err != nil {
+		return nil, bakeErrorf(8, "Error during baking: %v", err)
+	}
+
+	if deduper != nil {
+		if err := deduper.Close(); err != nil {
+			return nil, bakeErrorf(8, "Error removing duplicates: %v", err)
+		}
+	}
+	if sorter != nil {
+		if err := sorter.Close(); err != nil {
+			return nil, bakeErrorf(8, "Error sorting output: %v", err)
+		}
+	}
+	if err := w.Error(); err != nil {
+		return nil, bakeErrorf(8, "Error writing output: %v", err)
+	}
+
+	summary := &bakeResult{TransformationResult: result, Checked: checker != nil, SchemaSkipped: result.SkippedLines}
+	if !transformer.IsAggregate() {
+		summary.SchemaSkipped -= result.TypeErrors
+	}
+	if deduper != nil {
+		summary.Duplicates = deduper.Dropped()
+	}
+	return summary, nil
+}
+
 func runBake(cmd *cobra.Command, args []string) {
 	if inputFile == "" {
 		log.Errorf("Please specify an input file path with -i or --in")
@@ -137,42 +354,52 @@ func runBake(cmd *cobra.Command, args []string) {
 		out = outFile
 	}
 
-	recipeFile, err := os.Open(recipeFile)
+	parameters, err := parseParameterSets(parameterSets)
 	if err != nil {
-		log.Errorf("Unable to open recipe file: %v", err)
-		os.Exit(6)
-	}
-	defer func() { _ = recipeFile.Close() }()
-
-	transformer, err := recipe.Parse(recipeFile)
-	if err != nil {
-		log.Errorf("Error processing your recipe: %v", err)
+		log.Errorf("%v", err)
 		os.Exit(7)
 	}
 
-	transformer.Sanitize = sanitize
-
-	// Don't count the header
-	if transformLines > 0 && !disableHeader {
-		transformLines++
-	}
-
-	inComma := effectiveDelimiter("--input-delimiter", inputDelimiter, delimiter)
-	outComma := effectiveDelimiter("--output-delimiter", outputDelimiter, delimiter)
-
-	r := csv.NewReader(in)
-	r.Comma = inComma
-	w := csv.NewWriter(out)
-	w.Comma = outComma
-
-	result, err := transformer.Execute(r, w, !disableHeader, transformLines, parseErrIsError)
+	result, err := bake(in, out, bakeOptions{
+		recipePath:        recipeFile,
+		lines:             transformLines,
+		noHeader:          disableHeader,
+		parseErrorIsError: parseErrIsError,
+		sanitize:          sanitize,
+		inputDelimiter:    effectiveDelimiter("--input-delimiter", inputDelimiter, delimiter),
+		outputDelimiter:   effectiveDelimiter("--output-delimiter", outputDelimiter, delimiter),
+		parameters:        parameters,
+		maxGroups:         maxGroups,
+		dedupeKey:         dedupeKey,
+		dedupeKeep:        dedupeKeep,
+		sortBy:            sortBy,
+		traceLines:        traceLines,
+		schema:            bakeSchema,
+		violations:        bakeViolations,
+		failFast:          bakeFailFast,
+		status:            os.Stderr,
+	})
 	if err != nil {
-		log.Errorf("Error during baking: %v", err)
+		log.Errorf("%v", err)
+		if bakeErr, ok := err.(*bakeError); ok {
+			os.Exit(bakeErr.code)
+		}
 		os.Exit(8)
 	}
 
 	fmt.Fprintf(os.Stderr, "Baking complete. Your output is here: %s\n\n", outputFile)
 	fmt.Fprintf(os.Stderr, "Processed %d header lines and %d input lines\n", result.HeaderLines, result.Lines)
+	if dedupeKey != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d output lines after dropping %d duplicates\n", result.OutputLines-result.Duplicates, result.Duplicates)
+	} else {
+		fmt.Fprintf(os.Stderr, "Wrote %d output lines\n", result.OutputLines)
+	}
+	if result.Checked {
+		fmt.Fprintf(os.Stderr, "Skipped %d input lines that don't match the schema\n", result.SchemaSkipped)
+	}
+	if result.TypeErrors > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d rows with values that don't match their column type\n", result.TypeErrors)
+	}
 }
 
 func init() {
@@ -194,6 +421,15 @@ func init() {
 	bakeCmd.Flags().StringVar(&delimiter, "delimiter", "", "field delimiter for both input and output (default ,); use \\t for tab")
 	bakeCmd.Flags().StringVar(&inputDelimiter, "input-delimiter", "", "field delimiter for input only (overrides --delimiter)")
 	bakeCmd.Flags().StringVar(&outputDelimiter, "output-delimiter", "", "field delimiter for output only (overrides --delimiter)")
+	bakeCmd.Flags().IntVar(&maxGroups, "max-groups", 0, "groups an aggregate recipe keeps in memory before spilling to disk (default 100000)")
+	bakeCmd.Flags().StringVar(&dedupeKey, "dedupe-key", "", "drop output rows with a repeated key; the key is a recipe expression over output columns, e.g. \"1\" or \"3 -> lowercase\"")
+	bakeCmd.Flags().StringVar(&dedupeKeep, "dedupe-keep", "first", "which duplicate to keep with --dedupe-key: first or last")
+	bakeCmd.Flags().StringArrayVar(&sortBy, "sort-by", nil, "--sort-by 5:numeric:desc (sort output by a column or expression with optional asc|desc and string|numeric|date; may be repeated)")
+	bakeCmd.Flags().IntSliceVar(&traceLines, "trace-line", nil, "--trace-line 3 (print each recipe step for input line 3 to stderr; may be repeated)")
+	bakeCmd.Flags().StringVar(&bakeSchema, "schema", "", "--schema contract.yaml (only bake input rows that match the schema; see validate)")
+	bakeCmd.Flags().StringVar(&bakeViolations, "violations", "", "--violations violations.csv (write the violations found by --schema to a CSV file instead of standard error)")
+	bakeCmd.Flags().BoolVar(&bakeFailFast, "fail-fast", false, "--fail-fast (stop baking at the first row that breaks --schema)")
+	bakeCmd.Flags().StringArrayVar(&parameterSets, "set", nil, "--set name=value (sets recipe parameter %name; may be repeated)")
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// bakeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
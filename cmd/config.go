@@ -0,0 +1,139 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dstockto/csv-chef/config"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configurable lists the flags of each command whose defaults can be set in
+// the config file, in a profile or in the environment, in the order config
+// show lists them.
+var configurable = []struct {
+	cmd      *cobra.Command
+	settings []config.Setting
+}{
+	{bakeCmd, []config.Setting{
+		{Key: "delimiter", Flag: "delimiter"},
+		{Key: "input_delimiter", Flag: "input-delimiter"},
+		{Key: "output_delimiter", Flag: "output-delimiter"},
+		{Key: "no_header", Flag: "no-header"},
+		{Key: "sanitize", Flag: "sanitize"},
+		{Key: "parse_error_is_error", Flag: "parseErrorIsError"},
+		{Key: "force", Flag: "force"},
+		{Key: "max_groups", Flag: "max-groups"},
+		{Key: "dedupe_keep", Flag: "dedupe-keep"},
+		{Key: "fail_fast", Flag: "fail-fast"},
+	}},
+	{identityCmd, []config.Setting{
+		{Key: "with_headers", Flag: "with-headers"},
+		{Key: "force", Flag: "force"},
+		{Key: "sample", Flag: "sample"},
+	}},
+	{lintCmd, []config.Setting{
+		{Key: "format", Flag: "format"},
+		{Key: "disable", Flag: "disable"},
+		{Key: "level", Flag: "level"},
+	}},
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Shows the settings read from the config file and environment",
+	Long: `The flags of bake, identity and lint can take their defaults from the config
+file ($HOME/.csv-chef.yaml or --config), from a named profile in it chosen
+with --profile or CSVCHEF_PROFILE, and from environment variables like
+CSVCHEF_BAKE_DELIMITER. See the README for the config file format.`,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Prints the effective settings and where each came from",
+	Long: `Show prints the config file and profile in use, followed by the value every
+configurable flag has when it isn't given on the command line, and whether
+that value came from an environment variable, the profile, the config file
+or the flag's default.`,
+	Args: cobra.NoArgs,
+	Run:  showConfig,
+}
+
+func showConfig(cmd *cobra.Command, args []string) {
+	c, err := loadConfig()
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
+	var entries []config.Entry
+	for _, commandSettings := range configurable {
+		found, err := c.Apply(commandSettings.cmd.Name(), commandSettings.cmd.Flags(), commandSettings.settings)
+		if err != nil {
+			log.Errorf("%v", err)
+			os.Exit(1)
+		}
+		entries = append(entries, found...)
+	}
+	if err := config.WriteReport(os.Stdout, c, entries); err != nil {
+		log.Errorf("Unable to write settings: %v", err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig returns the settings of the config file read by initConfig,
+// using the profile chosen with --profile or CSVCHEF_PROFILE.
+func loadConfig() (*config.Config, error) {
+	name := profile
+	if !rootCmd.PersistentFlags().Changed("profile") {
+		name = os.Getenv(config.EnvPrefix + "_PROFILE")
+	}
+	return config.New(viper.GetViper(), name)
+}
+
+// applyConfig sets the flags of cmd that weren't given on the command line
+// to their configured defaults, if cmd has any.
+func applyConfig(cmd *cobra.Command) error {
+	for _, commandSettings := range configurable {
+		if commandSettings.cmd != cmd {
+			continue
+		}
+		c, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if _, err := c.Apply(cmd.Name(), cmd.Flags(), commandSettings.settings); err != nil {
+			return fmt.Errorf("invalid setting %v", err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+
+	"github.com/dstockto/csv-chef/csvdiff"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffKeys            []int
+	diffFormat          string
+	diffNoHeader        bool
+	diffSorted          bool
+	diffDelimiter       string
+	diffInputDelimiter  string
+	diffOutputDelimiter string
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff old.csv new.csv --key 1",
+	Short: "Reports the rows added, removed and changed between two CSV files",
+	Long: `Diff compares two CSV files, matching rows by the key column(s) given
+with --key, and reports rows that were added, removed or changed along with
+the columns that changed in each changed row. The report can be text, csv or
+json, chosen with --format.
+
+If both files are sorted by the key they are compared in a single streaming
+pass. Otherwise diff compares them again with the old file held in memory
+while the new one is read. Pass --sorted to report changes as they are found
+and stop with an error at the first key that is out of order instead.
+
+Diff exits with status 0 if the files match, 1 if they differ and 2 if they
+could not be compared.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runDiff,
+}
+
+// diffSource opens path for reading with the given delimiter. Standard input
+// can only be read once, so what is read from "-" is kept in case the source
+// is opened again.
+func diffSource(path string, comma rune) csvdiff.Source {
+	var stdin bytes.Buffer
+	opened := false
+	return func() (*csv.Reader, func() error, error) {
+		if path == "-" {
+			var in io.Reader = io.TeeReader(os.Stdin, &stdin)
+			if opened {
+				in = io.MultiReader(bytes.NewReader(stdin.Bytes()), os.Stdin)
+			}
+			opened = true
+			r := csv.NewReader(in)
+			r.Comma = comma
+			return r, func() error { return nil }, nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		r := csv.NewReader(file)
+		r.Comma = comma
+		return r, file.Close, nil
+	}
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	if args[0] == "-" && args[1] == "-" {
+		log.Errorf("Only one of the files can be read from standard input")
+		os.Exit(2)
+	}
+
+	inComma := effectiveDelimiter("--input-delimiter", diffInputDelimiter, diffDelimiter)
+	outComma := effectiveDelimiter("--output-delimiter", diffOutputDelimiter, diffDelimiter)
+
+	reporter, err := csvdiff.NewReporter(diffFormat, os.Stdout, outComma)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(2)
+	}
+
+	options := csvdiff.Options{KeyColumns: diffKeys, Header: !diffNoHeader, Sorted: diffSorted}
+	summary, err := csvdiff.Compare(diffSource(args[0], inComma), diffSource(args[1], inComma), options, reporter)
+	if err != nil {
+		log.Errorf("Error comparing files: %v", err)
+		os.Exit(2)
+	}
+	if summary.Differences() > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().IntSliceVarP(&diffKeys, "key", "k", nil, "--key 1 (key column; repeat or use --key 1,3 for a composite key)")
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "report format: text, csv or json")
+	diffCmd.Flags().BoolVarP(&diffNoHeader, "no-header", "d", false, "--no-header (compare the first row too)")
+	diffCmd.Flags().BoolVar(&diffSorted, "sorted", false, "--sorted (both files must be sorted by the key; report changes as they are found)")
+	diffCmd.Flags().StringVar(&diffDelimiter, "delimiter", "", "field delimiter for both input and output (default ,); use \\t for tab")
+	diffCmd.Flags().StringVar(&diffInputDelimiter, "input-delimiter", "", "field delimiter for the input files only (overrides --delimiter)")
+	diffCmd.Flags().StringVar(&diffOutputDelimiter, "output-delimiter", "", "field delimiter for the csv report only (overrides --delimiter)")
+	_ = diffCmd.MarkFlagRequired("key")
+}
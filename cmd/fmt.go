@@ -0,0 +1,116 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dstockto/csv-chef/recipe"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fmtWrite bool
+	fmtCheck bool
+)
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [-w | --check] recipe...",
+	Short: "Rewrites recipes in canonical form",
+	Long: `Fmt parses each recipe and prints it in canonical form: one statement per
+line with single spaces around <-, -> and +, canonical function names, and
+each header line placed just before its column. Comments are kept.
+
+By default the formatted recipe is printed. With -w the recipe file is
+rewritten in place. With --check nothing is written; the names of recipes
+that are not already formatted are printed and fmt exits with status 1.
+Use - to read a recipe from standard input.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runFmt,
+}
+
+func runFmt(cmd *cobra.Command, args []string) {
+	if fmtWrite && fmtCheck {
+		log.Errorf("Please use only one of -w and --check")
+		os.Exit(2)
+	}
+
+	unformatted := 0
+	for _, path := range args {
+		var source []byte
+		var err error
+		if path == "-" {
+			source, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			source, err = ioutil.ReadFile(path)
+		}
+		if err != nil {
+			log.Errorf("Unable to open recipe file: %v", err)
+			os.Exit(2)
+		}
+
+		formatted, err := recipe.Format(bytes.NewReader(source))
+		if err != nil {
+			log.Errorf("Error processing recipe %s: %s", path, describeRecipeError(err, path))
+			os.Exit(2)
+		}
+
+		switch {
+		case fmtCheck:
+			if formatted != string(source) {
+				unformatted++
+				fmt.Println(path)
+			}
+		case fmtWrite && path != "-":
+			if formatted == string(source) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Errorf("Unable to write recipe file: %v", err)
+				os.Exit(3)
+			}
+			if err := ioutil.WriteFile(path, []byte(formatted), info.Mode()); err != nil {
+				log.Errorf("Unable to write recipe file: %v", err)
+				os.Exit(3)
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	if unformatted > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+
+	fmtCmd.Flags().BoolVarP(&fmtWrite, "write", "w", false, "-w (rewrite the recipe files in place)")
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "--check (list recipes that are not formatted and exit 1 if there are any)")
+}
@@ -26,15 +26,25 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"github.com/dstockto/csv-chef/csvprofile"
+	"github.com/dstockto/csv-chef/headermap"
+	"github.com/dstockto/csv-chef/recipe"
 	"github.com/google/martian/log"
 	"github.com/spf13/cobra"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 )
 
 var (
-	withHeaders bool
-	output      string
+	withHeaders     bool
+	output          string
+	identityForce   bool
+	identityInfer   bool
+	identitySample  int
+	identityTo      string
+	identityToNames []string
 )
 
 // identityCmd represents the identity command
@@ -44,7 +54,18 @@ var identityCmd = &cobra.Command{
 	Long: `The identity command creates a recipe that will read in and write out a file
 unchanged from a given input file. This can then be used to build the recipe you want without
 needing to specify all the columns (and headers, optionally) through typing. The intent is to
-save you some time. To save it to a file, redirect the output to a file or provide the -o flag.`,
+save you some time. To save it to a file, redirect the output to a file or provide the -o flag.
+
+With --infer, identity reads the first rows of the file (100 by default, see --sample) and
+suggests cleanup functions for each column, such as trim for values with stray whitespace,
+readDate and formatDate for dates in a consistent layout and trimZeros for numbers with padded
+decimals. The reason for each suggestion is written in the line's comment.
+
+With --to target.csv (or --to-names with a list of column names), identity instead writes a
+recipe that converts the input file to the target's layout. Each target column gets a header
+recipe and is filled from the input column with the same name, ignoring case and punctuation,
+a synonym of it (such as zip and postal code) or a close misspelling. Target columns that match
+nothing are left empty with a TODO comment.`,
 	Run: runIdentity,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
@@ -55,6 +76,15 @@ save you some time. To save it to a file, redirect the output to a file or provi
 }
 
 func runIdentity(cmd *cobra.Command, args []string) {
+	if identityTo != "" && len(identityToNames) > 0 {
+		log.Errorf("Please give the target columns with only one of --to or --to-names")
+		os.Exit(1)
+	}
+	target := identityToNames
+	if identityTo != "" {
+		target = readTargetHeader(identityTo)
+	}
+
 	// try to read file
 	in, err := os.Open(args[0])
 	if err != nil {
@@ -73,11 +103,23 @@ func runIdentity(cmd *cobra.Command, args []string) {
 		os.Exit(4)
 	}
 
+	cleanups := make([]csvprofile.Cleanup, len(row))
+	if identityInfer {
+		samples, err := sampleColumns(csvReader, len(row), identitySample)
+		if err != nil {
+			log.Errorf("Error reading a line from CSV: %v", err)
+			os.Exit(4)
+		}
+		for i := range row {
+			cleanups[i] = csvprofile.SuggestCleanup(samples[i])
+		}
+	}
+
 	var w io.Writer
 
 	if output != "" {
 		// check for existence
-		if !forceOverwrite {
+		if !identityForce {
 			if _, err := os.Stat(output); err == nil {
 				log.Errorf("Output file already exists: %s", output)
 				os.Exit(5)
@@ -95,6 +137,16 @@ func runIdentity(cmd *cobra.Command, args []string) {
 		w = os.Stdout
 	}
 
+	if target != nil {
+		for _, line := range mappingRecipe(row, target, cleanups) {
+			if _, err = fmt.Fprintln(w, line); err != nil {
+				log.Errorf("Error writing recipe line: %v", err)
+				os.Exit(11)
+			}
+		}
+		return
+	}
+
 	for zeroIndex, column := range row {
 		num := zeroIndex + 1
 		if withHeaders {
@@ -102,12 +154,17 @@ func runIdentity(cmd *cobra.Command, args []string) {
 				log.Errorf("Error writing header recipe: %v", err)
 				os.Exit(10)
 			}
-			if _, err = fmt.Fprintf(w, "%d <- %d # %s\n", num, num, trimBom(column)); err != nil {
+			comment := append([]string{trimBom(column)}, cleanups[zeroIndex].Reasons...)
+			if _, err = fmt.Fprintf(w, "%s # %s\n", identityRecipe(num, cleanups[zeroIndex]), strings.Join(comment, "; ")); err != nil {
 				log.Errorf("Error writing header recipe: %v", err)
 				os.Exit(10)
 			}
 		} else {
-			_, err = fmt.Fprintf(w, "%d <- %d\n", num, num)
+			line := identityRecipe(num, cleanups[zeroIndex])
+			if reasons := cleanups[zeroIndex].Reasons; len(reasons) > 0 {
+				line += " # " + strings.Join(reasons, "; ")
+			}
+			_, err = fmt.Fprintln(w, line)
 			if err != nil {
 				log.Errorf("Error writing recipe line: %v", err)
 				os.Exit(11)
@@ -116,6 +173,98 @@ func runIdentity(cmd *cobra.Command, args []string) {
 	}
 }
 
+// identityRecipe returns the recipe that copies column num, followed by the
+// suggested cleanup if there is one.
+func identityRecipe(num int, cleanup csvprofile.Cleanup) string {
+	recipe := fmt.Sprintf("%d <- %d", num, num)
+	if pipe := cleanup.Pipe(); pipe != "" {
+		recipe += " -> " + pipe
+	}
+	return recipe
+}
+
+// readTargetHeader reads the header row of the file describing the layout
+// the recipe should produce.
+func readTargetHeader(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Errorf("Unable to read target file: %v", err)
+		os.Exit(3)
+	}
+	defer func() { _ = f.Close() }()
+
+	header, err := csv.NewReader(f).Read()
+	if err == io.EOF {
+		log.Errorf("Target CSV was empty")
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Errorf("Error reading the header of the target CSV: %v", err)
+		os.Exit(4)
+	}
+	for i, name := range header {
+		header[i] = trimBom(name)
+	}
+	return header
+}
+
+// mappingRecipe returns the lines of a recipe that writes the target
+// columns from the input columns with matching names. Cleanups, indexed by
+// input column, are added to the columns they are for.
+func mappingRecipe(input []string, target []string, cleanups []csvprofile.Cleanup) []string {
+	var lines []string
+	for _, m := range headermap.Columns(input, target) {
+		num := strconv.Itoa(m.Target)
+		header := recipe.Recipe{
+			Output: recipe.Output{Type: recipe.Header, Value: num},
+			Pipe:   []recipe.Operation{literalOperation(m.TargetName)},
+		}
+		column := recipe.Recipe{Output: recipe.Output{Type: recipe.Column, Value: num}}
+		if m.Input == 0 {
+			column.Pipe = []recipe.Operation{literalOperation("")}
+			column.Comment = "TODO: no input column matches " + m.TargetName
+		} else {
+			pipe := fmt.Sprintf("%d", m.Input)
+			comment := []string{m.TargetName}
+			if m.Kind != headermap.Exact {
+				comment[0] += fmt.Sprintf(" from %s (%s match)", trimBom(m.InputName), m.Kind)
+			}
+			if cleanup := cleanups[m.Input-1]; cleanup.Pipe() != "" {
+				pipe += " -> " + cleanup.Pipe()
+				comment = append(comment, cleanup.Reasons...)
+			}
+			lines = append(lines, recipe.FormatRecipe(header), fmt.Sprintf("%s <- %s # %s", num, pipe, strings.Join(comment, "; ")))
+			continue
+		}
+		lines = append(lines, recipe.FormatRecipe(header), recipe.FormatRecipe(column))
+	}
+	return lines
+}
+
+func literalOperation(value string) recipe.Operation {
+	return recipe.Operation{Name: "value", Arguments: []recipe.Argument{{Type: recipe.Literal, Value: value}}}
+}
+
+// sampleColumns reads up to rows rows from r and returns the values of each
+// of the first columns columns.
+func sampleColumns(r *csv.Reader, columns int, rows int) ([][]string, error) {
+	r.FieldsPerRecord = -1
+	samples := make([][]string, columns)
+	for n := 0; n < rows; n++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < columns && i < len(row); i++ {
+			samples[i] = append(samples[i], row[i])
+		}
+	}
+	return samples, nil
+}
+
 func init() {
 	rootCmd.AddCommand(identityCmd)
 
@@ -126,7 +275,11 @@ func init() {
 	// identityCmd.PersistentFlags().String("foo", "", "A help for foo")
 	identityCmd.Flags().BoolVarP(&withHeaders, "with-headers", "w", false, "--with-headers")
 	identityCmd.Flags().StringVarP(&output, "output", "o", "", "-o /path/to/output.csv")
-	identityCmd.Flags().BoolVarP(&forceOverwrite, "force", "f", false, "-f (write file even if it exists)")
+	identityCmd.Flags().BoolVarP(&identityForce, "force", "f", false, "-f (write file even if it exists)")
+	identityCmd.Flags().BoolVar(&identityInfer, "infer", false, "--infer (suggest cleanup functions for each column from sample rows)")
+	identityCmd.Flags().IntVar(&identitySample, "sample", 100, "number of rows --infer looks at")
+	identityCmd.Flags().StringVar(&identityTo, "to", "", "--to target.csv (write a recipe converting the input to this file's header layout)")
+	identityCmd.Flags().StringSliceVar(&identityToNames, "to-names", nil, "--to-names id,first_name,zip (like --to, with the target column names listed)")
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// identityCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
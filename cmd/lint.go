@@ -23,9 +23,11 @@ package cmd
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/dstockto/csv-chef/recipe"
 	"github.com/google/martian/log"
@@ -33,8 +35,12 @@ import (
 )
 
 var (
-	lintRecipeFile string
-	lintInputFile  string
+	lintRecipeFile    string
+	lintInputFile     string
+	lintParameterSets []string
+	lintFormat        string
+	lintDisabled      []string
+	lintLevels        []string
 )
 
 // lintCmd represents the lint command
@@ -46,15 +52,44 @@ reports parse errors (such as unknown functions, unterminated literals or
 incorrect argument counts) and recipe validation errors (such as missing
 column definitions). If an input CSV is provided with -i, lint also checks
 that the recipe does not reference an input column number greater than the
-number of columns in the input file's header row.`,
+number of columns in the input file's header row. Required recipe parameters
+must be supplied with --set name=value or the environment, just as for bake.
+
+Lint also looks for recipes that are valid but probably wrong. Each of these
+checks has a code and a default severity:
+
+  undefined-variable     error    a variable is used but never defined
+  use-before-definition  error    a variable is used by a variable defined before it
+  unused-variable        warning  a variable is defined but never used
+  invalid-argument       error    a literal argument will fail or be wrong at runtime
+  arity                  error    a function is given more arguments than it uses
+  missing-argument       warning  a function is given fewer arguments than it takes
+  header-column          warning  a header uses an input column its data column does not
+
+Turn a check off with --disable code, change its severity with
+--level code=warning or --level code=error, or ignore it on one line of the
+recipe with a "# lint:ignore code" comment. Warnings don't fail the lint.
+
+Lint reports every problem it finds rather than stopping at the first. Use
+--format json for a machine-readable report, for example for an editor.`,
 	Run: runLint,
 }
 
+// lintReport is the JSON form of lint's results.
+type lintReport struct {
+	File        string              `json:"file"`
+	Diagnostics []recipe.Diagnostic `json:"diagnostics"`
+}
+
 func runLint(cmd *cobra.Command, args []string) {
 	if lintRecipeFile == "" {
 		log.Errorf("Please specify a recipe file path with -r or --recipe")
 		os.Exit(1)
 	}
+	if lintFormat != "text" && lintFormat != "json" {
+		log.Errorf("Unknown format %q, expected text or json", lintFormat)
+		os.Exit(1)
+	}
 
 	recipeReader, err := os.Open(lintRecipeFile)
 	if err != nil {
@@ -63,15 +98,35 @@ func runLint(cmd *cobra.Command, args []string) {
 	}
 	defer func() { _ = recipeReader.Close() }()
 
-	transformer, err := recipe.Parse(recipeReader)
+	// Every check runs so that all problems are reported together. The exit
+	// code is that of the first check to find an error.
+	exitCode := 0
+	diagnostics := []recipe.Diagnostic{}
+	report := func(found []recipe.Diagnostic, code int) {
+		if exitCode == 0 && recipe.HasErrors(found) {
+			exitCode = code
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+
+	options, err := parseLintOptions(lintDisabled, lintLevels)
 	if err != nil {
-		log.Errorf("Error processing your recipe: %v", err)
-		os.Exit(3)
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
+	values, err := parseParameterSets(lintParameterSets)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
 	}
 
-	if err := transformer.ValidateRecipe(); err != nil {
-		log.Errorf("Recipe validation failed: %v", err)
-		os.Exit(4)
+	transformer, parseDiagnostics := recipe.ParseAll(recipeReader)
+	report(parseDiagnostics, 3)
+	report(transformer.Validate(), 4)
+	report(transformer.Lint(options), 4)
+
+	if err := transformer.BindParameters(values, os.LookupEnv); err != nil {
+		report([]recipe.Diagnostic{{Severity: recipe.SeverityError, Message: err.Error()}}, 3)
 	}
 
 	if lintInputFile != "" {
@@ -95,21 +150,81 @@ func runLint(cmd *cobra.Command, args []string) {
 		headerWidth := len(header)
 		maxReferenced := transformer.MaxInputColumnReferenced()
 		if maxReferenced > headerWidth {
-			log.Errorf(
-				"Recipe references input column %d, but input file %s only has %d column(s) in its header row",
-				maxReferenced, lintInputFile, headerWidth,
-			)
-			os.Exit(7)
+			report([]recipe.Diagnostic{{
+				Severity: recipe.SeverityError,
+				Message: fmt.Sprintf(
+					"Recipe references input column %d, but input file %s only has %d column(s) in its header row",
+					maxReferenced, lintInputFile, headerWidth,
+				),
+			}}, 7)
+		}
+	}
+
+	if lintFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(lintReport{File: lintRecipeFile, Diagnostics: diagnostics}); err != nil {
+			log.Errorf("Unable to write report: %v", err)
+			os.Exit(2)
 		}
+		os.Exit(exitCode)
+	}
+
+	for _, d := range diagnostics {
+		_, _ = fmt.Fprint(os.Stderr, d.Render(lintRecipeFile))
+	}
+	if exitCode != 0 {
+		errorCount := 0
+		for _, d := range diagnostics {
+			if d.Severity == recipe.SeverityError {
+				errorCount++
+			}
+		}
+		log.Errorf("Found %d error(s) in %s", errorCount, lintRecipeFile)
+		os.Exit(exitCode)
 	}
 
 	fmt.Println("Recipe OK")
 }
 
+// parseLintOptions converts the --disable and --level flags into options
+// for Lint, checking that each names a known check.
+func parseLintOptions(disabled []string, levels []string) (recipe.LintOptions, error) {
+	options := recipe.LintOptions{
+		Disabled:   make(map[string]bool),
+		Severities: make(map[string]recipe.Severity),
+	}
+	for _, code := range disabled {
+		if _, ok := recipe.LookupLintCheck(code); !ok {
+			return options, fmt.Errorf("--disable: unknown check %q", code)
+		}
+		options.Disabled[code] = true
+	}
+	for _, level := range levels {
+		eq := strings.Index(level, "=")
+		if eq < 1 {
+			return options, fmt.Errorf("--level expects check=severity, got %q", level)
+		}
+		code, severity := level[:eq], recipe.Severity(level[eq+1:])
+		if _, ok := recipe.LookupLintCheck(code); !ok {
+			return options, fmt.Errorf("--level: unknown check %q", code)
+		}
+		if severity != recipe.SeverityError && severity != recipe.SeverityWarning {
+			return options, fmt.Errorf("--level: unknown severity %q, expected error or warning", severity)
+		}
+		options.Severities[code] = severity
+	}
+	return options, nil
+}
+
 func init() {
 	rootCmd.AddCommand(lintCmd)
 
 	lintCmd.Flags().StringVarP(&lintRecipeFile, "recipe", "r", "", "-r /path/to/recipe.txt")
 	lintCmd.Flags().StringVarP(&lintInputFile, "in", "i", "", "-i /path/to/input.csv")
+	lintCmd.Flags().StringArrayVar(&lintParameterSets, "set", nil, "--set name=value (sets recipe parameter %name; may be repeated)")
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "report format: text or json")
+	lintCmd.Flags().StringSliceVar(&lintDisabled, "disable", nil, "--disable check (turns off a lint check; may be repeated)")
+	lintCmd.Flags().StringArrayVar(&lintLevels, "level", nil, "--level check=warning|error (changes the severity of a lint check; may be repeated)")
 	_ = lintCmd.MarkFlagRequired("recipe")
 }
@@ -33,8 +33,12 @@ import (
 var parseFakeCmd = &cobra.Command{
 	Use:   "parse",
 	Short: "Parses a given recipe file",
-	Long:  `Tests the parser by ensuring that it is reading the instructions as expected`,
-	Run:   runParse,
+	Long: `Tests the parser by ensuring that it is reading the instructions as expected.
+
+With --format json or --format yaml the recipe is written as a versioned
+syntax tree with the line and column of each statement, operation and
+comment, for use by editors and other tools.`,
+	Run: runParse,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return errors.New("please provide recipe file")
@@ -49,9 +53,25 @@ func runParse(cmd *cobra.Command, args []string) {
 		log.Errorf("%+v\n", err)
 		os.Exit(2)
 	}
+	defer func() { _ = recipeFile.Close() }()
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" {
+		ast, err := recipe.ParseAST(recipeFile)
+		if err != nil {
+			log.Errorf("%s", describeRecipeError(err, args[0]))
+			os.Exit(10)
+		}
+		if err := recipe.WriteAST(os.Stdout, ast, format); err != nil {
+			log.Errorf("%+v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
+
 	transformation, err := recipe.Parse(recipeFile)
 	if err != nil {
-		log.Errorf("%+v\n", err)
+		log.Errorf("%s", describeRecipeError(err, args[0]))
 		os.Exit(10)
 	}
 
@@ -61,6 +81,8 @@ func runParse(cmd *cobra.Command, args []string) {
 func init() {
 	rootCmd.AddCommand(parseFakeCmd)
 
+	parseFakeCmd.Flags().String("format", "text", "output format: text, json or yaml")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
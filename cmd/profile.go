@@ -0,0 +1,110 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+
+	"github.com/dstockto/csv-chef/csvprofile"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileFormat         string
+	profileNoHeader       bool
+	profileTop            int
+	profileSamples        int
+	profileDistinctLimit  int
+	profileDelimiter      string
+	profileInputDelimiter string
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile input.csv",
+	Short: "Reports statistics and the likely type of each column of a CSV file",
+	Long: `Profile reads a CSV file and reports, for each column, its header, how many
+values are not empty, how many are distinct, the shortest and longest value,
+the type the values appear to be (integer, decimal, boolean, date along with
+its layout, email, phone, string or empty), the most common values and a few
+sample values. Use it to get to know an input file before writing a recipe.
+
+The distinct count and most common values are exact until a column has more
+than --distinct-limit different values; past that they are estimates and the
+distinct count is shown with a ~. Use - to read from standard input.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runProfile,
+}
+
+func runProfile(cmd *cobra.Command, args []string) {
+	if profileFormat != "table" && profileFormat != "json" {
+		log.Errorf("Unknown format %q, expected table or json", profileFormat)
+		os.Exit(1)
+	}
+
+	var in io.Reader
+	if args[0] == "-" {
+		in = os.Stdin
+	} else {
+		inFile, err := os.Open(args[0])
+		if err != nil {
+			log.Errorf("Error opening input file: %v", err)
+			os.Exit(2)
+		}
+		defer func() { _ = inFile.Close() }()
+		in = inFile
+	}
+
+	r := csv.NewReader(in)
+	r.Comma = effectiveDelimiter("--input-delimiter", profileInputDelimiter, profileDelimiter)
+
+	report, err := csvprofile.Profile(r, csvprofile.Options{
+		Header:        !profileNoHeader,
+		TopValues:     profileTop,
+		Samples:       profileSamples,
+		DistinctLimit: profileDistinctLimit,
+	})
+	if err != nil {
+		log.Errorf("Error reading input file: %v", err)
+		os.Exit(2)
+	}
+
+	if err := csvprofile.Write(os.Stdout, report, profileFormat); err != nil {
+		log.Errorf("Unable to write profile: %v", err)
+		os.Exit(2)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+
+	profileCmd.Flags().StringVar(&profileFormat, "format", "table", "report format: table or json")
+	profileCmd.Flags().BoolVarP(&profileNoHeader, "no-header", "d", false, "--no-header (the first row is data, not column names)")
+	profileCmd.Flags().IntVar(&profileTop, "top", 5, "number of most common values to report for each column")
+	profileCmd.Flags().IntVar(&profileSamples, "samples", 3, "number of sample values to report for each column")
+	profileCmd.Flags().IntVar(&profileDistinctLimit, "distinct-limit", 10000, "distinct values counted exactly per column before switching to estimates")
+	profileCmd.Flags().StringVar(&profileDelimiter, "delimiter", "", "field delimiter (default ,); use \\t for tab")
+	profileCmd.Flags().StringVar(&profileInputDelimiter, "input-delimiter", "", "field delimiter for input (overrides --delimiter)")
+}
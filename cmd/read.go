@@ -22,21 +22,40 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"encoding/csv"
 	"errors"
-	"fmt"
-	"github.com/dstockto/csv-chef/csv"
-	"github.com/google/martian/log"
-	"github.com/spf13/cobra"
 	"io"
 	"os"
+
+	"github.com/dstockto/csv-chef/csvview"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	readColumns        []string
+	readHead           int
+	readTail           int
+	readSkip           int
+	readMaxWidth       int
+	readNoHeader       bool
+	readRowNumbers     bool
+	readVertical       bool
+	readDelimiter      string
+	readInputDelimiter string
 )
 
 // readCmd represents the read command
 var readCmd = &cobra.Command{
-	Use:   "read",
-	Short: "Reads in a CSV",
-	Long:  `Reads a CSV`,
-	Run:   runRead,
+	Use:   "read input.csv",
+	Short: "Shows the rows of a CSV file as a table",
+	Long: `Read shows a CSV file as an aligned table with row numbers, truncating long
+values to --max-width characters. Choose the columns to show with --columns,
+by number, range (2-4) or header name, and the rows with --skip, --head and
+--tail. For files with too many columns to fit across the screen, --vertical
+shows each row as a record with one line per column. Use - to read from
+standard input.`,
+	Run: runRead,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return errors.New("csv file required")
@@ -46,36 +65,49 @@ var readCmd = &cobra.Command{
 }
 
 func runRead(cmd *cobra.Command, args []string) {
-	csvFile, closeFunc, err := csv.NewCsvSource(args[0])
-	if err != nil {
-		log.Errorf("%+v", err)
-		os.Exit(1)
-	}
-	defer func() { _ = closeFunc() }()
-
-	for {
-		line, err := csvFile.Read()
-		if err == io.EOF {
-			break
-		}
+	var in io.Reader
+	if args[0] == "-" {
+		in = os.Stdin
+	} else {
+		inFile, err := os.Open(args[0])
 		if err != nil {
-			log.Errorf("Error reading CSV: %v", err)
+			log.Errorf("Error opening input file: %v", err)
 			os.Exit(1)
 		}
-		fmt.Println(line)
+		defer func() { _ = inFile.Close() }()
+		in = inFile
+	}
+
+	r := csv.NewReader(in)
+	r.Comma = effectiveDelimiter("--input-delimiter", readInputDelimiter, readDelimiter)
+
+	err := csvview.View(r, os.Stdout, csvview.Options{
+		Header:     !readNoHeader,
+		Columns:    readColumns,
+		Skip:       readSkip,
+		Head:       readHead,
+		Tail:       readTail,
+		MaxWidth:   readMaxWidth,
+		RowNumbers: readRowNumbers,
+		Vertical:   readVertical,
+	})
+	if err != nil {
+		log.Errorf("Error reading CSV: %v", err)
+		os.Exit(1)
 	}
 }
 
 func init() {
 	rootCmd.AddCommand(readCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// readCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// readCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	readCmd.Flags().StringSliceVarP(&readColumns, "columns", "c", nil, "--columns 1,3-5,email (columns to show by number, range or header name)")
+	readCmd.Flags().IntVar(&readHead, "head", 0, "show only the first N rows")
+	readCmd.Flags().IntVar(&readTail, "tail", 0, "show only the last N rows")
+	readCmd.Flags().IntVar(&readSkip, "skip", 0, "skip the first N rows")
+	readCmd.Flags().IntVarP(&readMaxWidth, "max-width", "w", 40, "truncate values longer than this many characters (0 for no limit)")
+	readCmd.Flags().BoolVarP(&readNoHeader, "no-header", "d", false, "--no-header (the first row is data, not column names)")
+	readCmd.Flags().BoolVar(&readRowNumbers, "row-numbers", true, "show row numbers (use --row-numbers=false to hide them)")
+	readCmd.Flags().BoolVarP(&readVertical, "vertical", "V", false, "--vertical (show each row as a record, for wide files)")
+	readCmd.Flags().StringVar(&readDelimiter, "delimiter", "", "field delimiter (default ,); use \\t for tab")
+	readCmd.Flags().StringVar(&readInputDelimiter, "input-delimiter", "", "field delimiter for input (overrides --delimiter)")
 }
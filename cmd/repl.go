@@ -0,0 +1,138 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dstockto/csv-chef/repl"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replInputFile      string
+	replRows           int
+	replNoHeader       bool
+	replParameterSets  []string
+	replDelimiter      string
+	replHistoryFile    string
+	replDisableHistory bool
+)
+
+// replCmd represents the repl command
+var replCmd = &cobra.Command{
+	Use:   "repl -i /path/to/input.csv",
+	Short: "Interactively try out recipe lines against an input file",
+	Long: `Repl reads the first few rows of an input file and then lets you type
+recipe lines one at a time. Each line is parsed along with the lines already
+accepted and evaluated against those rows, showing the result or error for
+each row. Use :save <file> to write the accepted lines to a recipe file and
+:help for the other commands.
+
+Everything typed is appended to a history file, ~/.csv-chef_history by
+default.`,
+	Run: runRepl,
+}
+
+func runRepl(cmd *cobra.Command, args []string) {
+	if replInputFile == "" {
+		log.Errorf("Please specify an input file path with -i or --in")
+		os.Exit(1)
+	}
+	in, err := os.Open(replInputFile)
+	if err != nil {
+		log.Errorf("Error opening input file: %v", err)
+		os.Exit(1)
+	}
+	defer func() { _ = in.Close() }()
+
+	r := csv.NewReader(in)
+	r.Comma = effectiveDelimiter("--delimiter", replDelimiter, "")
+	r.FieldsPerRecord = -1
+	wanted := replRows
+	if !replNoHeader {
+		wanted++
+	}
+	var rows [][]string
+	for len(rows) < wanted {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Errorf("Error reading input file: %v", err)
+			os.Exit(2)
+		}
+		rows = append(rows, row)
+	}
+
+	values, err := parseParameterSets(replParameterSets)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
+
+	session := repl.New(rows, !replNoHeader, os.Stdout)
+	session.Parameters = values
+	session.LookupEnv = os.LookupEnv
+
+	if !replDisableHistory {
+		historyFile := replHistoryFile
+		if historyFile == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				historyFile = filepath.Join(home, ".csv-chef_history")
+			}
+		}
+		if historyFile != "" {
+			history, err := os.OpenFile(historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				log.Errorf("Unable to open history file, history will not be saved: %v", err)
+			} else {
+				defer func() { _ = history.Close() }()
+				session.HistoryWriter = history
+			}
+		}
+	}
+
+	fmt.Printf("Loaded %d row(s) from %s. Type :help for help.\n", len(rows), replInputFile)
+	if err := session.Run(os.Stdin); err != nil {
+		log.Errorf("Error reading input: %v", err)
+		os.Exit(3)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+
+	replCmd.Flags().StringVarP(&replInputFile, "in", "i", "", "-i /path/to/input.csv")
+	replCmd.Flags().IntVarP(&replRows, "lines", "n", 5, "-n 5 (number of data rows to evaluate each line against)")
+	replCmd.Flags().BoolVarP(&replNoHeader, "no-header", "d", false, "--no-header")
+	replCmd.Flags().StringArrayVar(&replParameterSets, "set", nil, "--set name=value (sets recipe parameter %name; may be repeated)")
+	replCmd.Flags().StringVar(&replDelimiter, "delimiter", "", "field delimiter for the input (default ,); use \\t for tab")
+	replCmd.Flags().StringVar(&replHistoryFile, "history", "", "--history /path/to/file (default ~/.csv-chef_history)")
+	replCmd.Flags().BoolVar(&replDisableHistory, "no-history", false, "--no-history (don't save what is typed)")
+}
@@ -25,11 +25,20 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/dstockto/csv-chef/config"
+	"github.com/dstockto/csv-chef/recipe"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	profile string
+	seed    int64
+	nowFlag string
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -49,13 +58,19 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initClock)
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(applyConfig(cmd))
+	}
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.csv-chef.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "--profile vendorX (use the settings of a profile in the config file)")
+	rootCmd.PersistentFlags().Int64Var(&seed, "seed", 0, "--seed 42 (seed for random values, so runs can be repeated)")
+	rootCmd.PersistentFlags().StringVar(&nowFlag, "now", "", "--now 2021-08-30T18:22:13Z (RFC 3339 time to use as the current time)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -78,6 +93,8 @@ func initConfig() {
 		viper.SetConfigName(".csv-chef")
 	}
 
+	viper.SetEnvPrefix(config.EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
@@ -85,3 +102,28 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// initClock pins the current time seen by recipes and generators when --now
+// is given.
+func initClock() {
+	if nowFlag == "" {
+		return
+	}
+	now, err := time.Parse(time.RFC3339, nowFlag)
+	if err != nil {
+		cobra.CheckErr(fmt.Errorf("--now must be an RFC 3339 time like 2021-08-30T18:22:13Z: %v", err))
+	}
+	recipe.Now = func() time.Time {
+		return now
+	}
+}
+
+// randomSeed returns the --seed value, or a seed from the clock when it
+// isn't given. The wall clock is used even when --now is set so that pinning
+// the time alone doesn't make random values repeat.
+func randomSeed() int64 {
+	if rootCmd.PersistentFlags().Changed("seed") {
+		return seed
+	}
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/dstockto/csv-chef/pipeline"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var runParallel int
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run pipeline.yaml",
+	Short: "Runs the bake steps listed in a pipeline file",
+	Long: `Run bakes every step listed in a YAML or JSON pipeline file. Each step takes
+the same options as bake. A step can read the output of another step with
+from instead of an input file; the rows are streamed between the steps
+without a temporary file. Steps that don't depend on each other run in
+parallel, up to --parallel at a time. A step that reads a file another step
+writes, or that lists it in after, waits for it to finish.
+
+Relative paths are relative to the pipeline file. When every step has run,
+run reports how each one went. Steps that wait for a step that failed are
+skipped, and run exits with status 1 if any step failed. See the README for
+the pipeline format.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runPipeline,
+}
+
+func runPipeline(cmd *cobra.Command, args []string) {
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Errorf("Unable to open pipeline file: %v", err)
+		os.Exit(2)
+	}
+	p, err := pipeline.Load(f)
+	_ = f.Close()
+	if err != nil {
+		log.Errorf("Error in pipeline %s: %v", args[0], err)
+		os.Exit(2)
+	}
+	p.ResolvePaths(filepath.Dir(args[0]))
+
+	results, err := p.Run(bakeStep, runParallel)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(2)
+	}
+	if err := pipeline.WriteReport(os.Stdout, results); err != nil {
+		log.Errorf("Unable to write report: %v", err)
+		os.Exit(2)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			os.Exit(1)
+		}
+	}
+}
+
+// bakeStep bakes one pipeline step.
+func bakeStep(step pipeline.Step, in io.Reader, out io.Writer) (string, error) {
+	inComma, err := delimiterFor("input_delimiter", step.InputDelimiter, step.Delimiter)
+	if err != nil {
+		return "", err
+	}
+	outComma, err := delimiterFor("output_delimiter", step.OutputDelimiter, step.Delimiter)
+	if err != nil {
+		return "", err
+	}
+	dedupeKeep := step.DedupeKeep
+	if dedupeKeep == "" {
+		dedupeKeep = "first"
+	}
+
+	result, err := bake(in, out, bakeOptions{
+		recipePath:        step.Recipe,
+		lines:             step.Lines,
+		noHeader:          step.NoHeader,
+		parseErrorIsError: step.ParseErrorIsError,
+		sanitize:          step.Sanitize,
+		inputDelimiter:    inComma,
+		outputDelimiter:   outComma,
+		parameters:        step.Set,
+		maxGroups:         step.MaxGroups,
+		dedupeKey:         step.DedupeKey,
+		dedupeKeep:        dedupeKeep,
+		sortBy:            step.SortBy,
+		schema:            step.Schema,
+		violations:        step.Violations,
+		failFast:          step.FailFast,
+		status:            os.Stderr,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary := fmt.Sprintf("read %d lines, wrote %d", result.Lines, result.OutputLines-result.Duplicates)
+	if result.Duplicates > 0 {
+		summary += fmt.Sprintf(", dropped %d duplicates", result.Duplicates)
+	}
+	if result.Checked {
+		summary += fmt.Sprintf(", skipped %d that don't match the schema", result.SchemaSkipped)
+	}
+	if result.TypeErrors > 0 {
+		summary += fmt.Sprintf(", skipped %d with the wrong column types", result.TypeErrors)
+	}
+	return summary, nil
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().IntVar(&runParallel, "parallel", runtime.NumCPU(), "number of independent steps to run at once")
+}
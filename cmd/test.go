@@ -0,0 +1,180 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dstockto/csv-chef/recipetest"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	testJUnitFile string
+	testVerbose   bool
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:   "test [recipe or directory ...]",
+	Short: "Runs the test cases for one or more recipes",
+	Long: `Test bakes the test cases found next to each recipe and compares the
+output with the expected output, cell by cell. For a recipe named clean.txt,
+clean.in.csv with clean.out.csv is one test case and every
+clean.<name>.in.csv with clean.<name>.out.csv is another. Cases can also be
+written inline in clean.test.yaml. Given a directory, every recipe in it that
+has test cases is tested. With no arguments the current directory is used.
+
+Test exits with a non-zero status if any case fails. Use --junit to also
+write a JUnit XML report for CI systems.`,
+	Run: runTest,
+}
+
+func runTest(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var recipes []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			log.Errorf("Unable to read %s: %v", arg, err)
+			os.Exit(1)
+		}
+		if !info.IsDir() {
+			recipes = append(recipes, arg)
+			continue
+		}
+		found, err := recipesWithTests(arg)
+		if err != nil {
+			log.Errorf("Unable to read directory %s: %v", arg, err)
+			os.Exit(1)
+		}
+		recipes = append(recipes, found...)
+	}
+
+	var suites []recipetest.Suite
+	passed, failed := 0, 0
+	for _, recipePath := range recipes {
+		source, err := ioutil.ReadFile(recipePath)
+		if err != nil {
+			log.Errorf("Unable to open recipe file: %v", err)
+			os.Exit(2)
+		}
+		cases, err := recipetest.Discover(recipePath)
+		if err != nil {
+			log.Errorf("Unable to load test cases for %s: %v", recipePath, err)
+			os.Exit(2)
+		}
+		if len(cases) == 0 {
+			fmt.Printf("No test cases found for %s\n", recipePath)
+			continue
+		}
+
+		suite := recipetest.Suite{Recipe: recipePath}
+		for _, c := range cases {
+			result := recipetest.Run(string(source), c)
+			suite.Results = append(suite.Results, result)
+			switch {
+			case result.Err != nil:
+				failed++
+				fmt.Printf("ERROR %s: %s\n    %v\n", recipePath, c.Name, result.Err)
+			case len(result.Diffs) > 0:
+				failed++
+				fmt.Printf("FAIL  %s: %s\n", recipePath, c.Name)
+				for _, d := range result.Diffs {
+					fmt.Printf("    %s\n", d)
+				}
+			default:
+				passed++
+				if testVerbose {
+					fmt.Printf("PASS  %s: %s\n", recipePath, c.Name)
+				}
+			}
+		}
+		suites = append(suites, suite)
+	}
+
+	if testJUnitFile != "" {
+		report, err := os.Create(testJUnitFile)
+		if err != nil {
+			log.Errorf("Unable to create JUnit report: %v", err)
+			os.Exit(3)
+		}
+		if err := recipetest.WriteJUnit(report, suites); err != nil {
+			_ = report.Close()
+			log.Errorf("Unable to write JUnit report: %v", err)
+			os.Exit(3)
+		}
+		if err := report.Close(); err != nil {
+			log.Errorf("Unable to write JUnit report: %v", err)
+			os.Exit(3)
+		}
+	}
+
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		os.Exit(4)
+	}
+}
+
+// recipesWithTests returns the recipes in dir that have at least one test
+// case. Fixture and test files themselves are never treated as recipes.
+func recipesWithTests(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var recipes []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".csv", ".yaml", ".yml", ".xml":
+			continue
+		}
+		path := filepath.Join(dir, name)
+		cases, err := recipetest.Discover(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(cases) > 0 {
+			recipes = append(recipes, path)
+		}
+	}
+	return recipes, nil
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().StringVar(&testJUnitFile, "junit", "", "--junit /path/to/report.xml (write a JUnit XML report)")
+	testCmd.Flags().BoolVarP(&testVerbose, "verbose", "v", false, "--verbose (list passing cases too)")
+}
@@ -0,0 +1,236 @@
+/*
+Copyright © 2021 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dstockto/csv-chef/csvschema"
+	"github.com/google/martian/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateSchema         string
+	validateViolations     string
+	validateFailFast       bool
+	validateNoHeader       bool
+	validateDelimiter      string
+	validateInputDelimiter string
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate input.csv --schema contract.yaml",
+	Short: "Checks a CSV file against a schema of its columns",
+	Long: `Validate checks that a CSV file keeps to the contract in a YAML or JSON
+schema: the names and order of its columns and, for each column, whether it
+is required, its type, a pattern or list of allowed values, a min and max,
+and whether its values must be unique. See the README for the schema format.
+
+Each violation is reported with its line and column, followed by a count of
+the violations per column and rule. Use --violations to write them to a CSV
+file instead and --fail-fast to stop at the first row that breaks the
+contract. Validate exits with status 1 when the file has any violations. Use
+- to read from standard input.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runValidate,
+}
+
+// contractChecker checks rows against a schema and reports each violation,
+// either as a row of a violations CSV or as a line of text.
+type contractChecker struct {
+	validator  *csvschema.Validator
+	violations *csv.Writer
+	report     io.Writer
+	failFast   bool
+}
+
+// openContractChecker loads the schema at path and opens the violations
+// CSV, if one is given. The returned function flushes and closes the
+// violations CSV.
+func openContractChecker(path, violationsPath string, report io.Writer, failFast bool) (*contractChecker, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open schema file: %v", err)
+	}
+	schema, err := csvschema.LoadSchema(f)
+	_ = f.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error in schema %s: %v", path, err)
+	}
+	validator, _ := csvschema.NewValidator(schema)
+
+	checker := &contractChecker{validator: validator, report: report, failFast: failFast}
+	closeFunc := func() error { return nil }
+	if violationsPath != "" {
+		var out io.Writer = os.Stdout
+		if violationsPath != "-" {
+			file, err := os.Create(violationsPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error creating violations file: %v", err)
+			}
+			out = file
+			closeFunc = file.Close
+		}
+		checker.violations = csv.NewWriter(out)
+		_ = checker.violations.Write(csvschema.ViolationHeader)
+		closeViolations := closeFunc
+		closeFunc = func() error {
+			checker.violations.Flush()
+			if err := checker.violations.Error(); err != nil {
+				return err
+			}
+			return closeViolations()
+		}
+	}
+	return checker, closeFunc, nil
+}
+
+// check validates one row and reports its violations.
+func (c *contractChecker) check(lineNo int, row []string, header bool) ([]csvschema.Violation, error) {
+	var violations []csvschema.Violation
+	if header {
+		violations = c.validator.CheckHeader(row)
+	} else {
+		violations = c.validator.CheckRow(lineNo, row)
+	}
+	for _, v := range violations {
+		var err error
+		if c.violations != nil {
+			err = c.violations.Write(v.Record())
+		} else {
+			_, err = fmt.Fprintln(c.report, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return violations, nil
+}
+
+// Keep lets bake skip the rows that break the contract. A header that
+// doesn't match the schema stops the bake, as does any violation when
+// failing fast.
+func (c *contractChecker) Keep(lineNo int, row []string, header bool) (bool, error) {
+	violations, err := c.check(lineNo, row, header)
+	if err != nil {
+		return false, err
+	}
+	if len(violations) == 0 {
+		return true, nil
+	}
+	if header {
+		return false, errors.New("the input header does not match the schema")
+	}
+	if c.failFast {
+		return false, fmt.Errorf("line %d does not match the schema", lineNo)
+	}
+	return false, nil
+}
+
+func runValidate(cmd *cobra.Command, args []string) {
+	if validateSchema == "" {
+		log.Errorf("Please specify a schema file with -s or --schema")
+		os.Exit(2)
+	}
+
+	var in io.Reader
+	if args[0] == "-" {
+		in = os.Stdin
+	} else {
+		inFile, err := os.Open(args[0])
+		if err != nil {
+			log.Errorf("Error opening input file: %v", err)
+			os.Exit(2)
+		}
+		defer func() { _ = inFile.Close() }()
+		in = inFile
+	}
+
+	checker, closeViolations, err := openContractChecker(validateSchema, validateViolations, os.Stdout, validateFailFast)
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(2)
+	}
+	summary := io.Writer(os.Stdout)
+	if validateViolations == "-" {
+		summary = os.Stderr
+	}
+
+	r := csv.NewReader(in)
+	r.Comma = effectiveDelimiter("--input-delimiter", validateInputDelimiter, validateDelimiter)
+	r.FieldsPerRecord = -1
+
+	invalid := false
+	for lineNo := 1; ; lineNo++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Errorf("Error reading input file: %v", err)
+			os.Exit(2)
+		}
+		violations, err := checker.check(lineNo, row, lineNo == 1 && !validateNoHeader)
+		if err != nil {
+			log.Errorf("Error writing violations: %v", err)
+			os.Exit(2)
+		}
+		if len(violations) > 0 {
+			invalid = true
+			if validateFailFast {
+				break
+			}
+		}
+	}
+
+	if err := closeViolations(); err != nil {
+		log.Errorf("Error writing violations: %v", err)
+		os.Exit(2)
+	}
+	if checker.violations == nil && invalid {
+		fmt.Fprintln(summary)
+	}
+	if err := csvschema.WriteSummary(summary, checker.validator); err != nil {
+		log.Errorf("Unable to write summary: %v", err)
+		os.Exit(2)
+	}
+	if invalid {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringVarP(&validateSchema, "schema", "s", "", "--schema contract.yaml (YAML or JSON description of the expected columns)")
+	validateCmd.Flags().StringVar(&validateViolations, "violations", "", "--violations violations.csv (write violations to a CSV file instead of listing them; - for standard output)")
+	validateCmd.Flags().BoolVar(&validateFailFast, "fail-fast", false, "--fail-fast (stop at the first row that breaks the schema)")
+	validateCmd.Flags().BoolVarP(&validateNoHeader, "no-header", "d", false, "--no-header (the first row is data, not column names)")
+	validateCmd.Flags().StringVar(&validateDelimiter, "delimiter", "", "field delimiter (default ,); use \\t for tab")
+	validateCmd.Flags().StringVar(&validateInputDelimiter, "input-delimiter", "", "field delimiter for input (overrides --delimiter)")
+}
@@ -23,21 +23,28 @@ package cmd
 
 import (
 	"errors"
+	"os"
+
 	"github.com/dstockto/csv-chef/csv"
+	"github.com/dstockto/csv-chef/fakedata"
+	"github.com/dstockto/csv-chef/recipe"
 	"github.com/google/martian/log"
 	"github.com/spf13/cobra"
-	"math/rand"
-	"os"
-	"syreclabs.com/go/faker"
-	"time"
 )
 
 // writeCmd represents the write command
 var writeCmd = &cobra.Command{
-	Use:   "write <file> [-n=lines (default 100)",
-	Short: "Writes a CSV",
-	Long:  `Write a CSV file`,
-	Run:   runWrite,
+	Use:   "write <file> [-n=lines (default 100)] [--schema schema.yaml] [--seed 42]",
+	Short: "Writes a CSV of fake data",
+	Long: `Write creates a CSV file of realistic looking fake data, for trying out
+recipes or as a test fixture. The columns and how to fill each one are read
+from a YAML or JSON schema given with --schema; see the README for the
+generators available. Without a schema it writes a sample voter file.
+
+Use --seed to get the same rows every time. Dates given relative to today in
+the schema are measured from --now when it is given, so with both flags the
+output is the same on any day.`,
+	Run: runWrite,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return errors.New("please provide file to write csv to")
@@ -46,9 +53,54 @@ var writeCmd = &cobra.Command{
 	},
 }
 
-var lines int
+var (
+	lines       int
+	writeSchema string
+)
+
+// defaultWriteSchema is the sample voter file written without --schema.
+var defaultWriteSchema = &fakedata.Schema{Columns: []fakedata.Column{
+	{Name: "voter_id", Type: fakedata.Integer, Min: 100000, Max: 99999999},
+	{Name: "first", Type: fakedata.FirstName},
+	{Name: "last", Type: fakedata.LastName},
+	{Name: "address", Type: fakedata.Address},
+	{Name: "city", Type: fakedata.City},
+	{Name: "state", Type: fakedata.State},
+	{Name: "zipcode", Type: fakedata.Zipcode},
+	{Name: "birthdate", Type: fakedata.Date, From: "-99y", To: "-17y"},
+	{Name: "party", Type: fakedata.Enum, Values: []fakedata.Choice{
+		{Value: "REP", Weight: 1},
+		{Value: "DEM", Weight: 1},
+		{Value: "", Weight: 1},
+		{Value: "IND", Weight: 1},
+		{Value: "GRN", Weight: 1},
+	}},
+	{Name: "sent", Type: fakedata.Date, From: "-10d", To: "+10d", Null: 90},
+	{Name: "email", Type: fakedata.Email},
+}}
 
 func runWrite(cmd *cobra.Command, args []string) {
+	schema := defaultWriteSchema
+	if writeSchema != "" {
+		f, err := os.Open(writeSchema)
+		if err != nil {
+			log.Errorf("Unable to open schema file: %v", err)
+			os.Exit(1)
+		}
+		schema, err = fakedata.LoadSchema(f)
+		_ = f.Close()
+		if err != nil {
+			log.Errorf("Error in schema %s: %v", writeSchema, err)
+			os.Exit(1)
+		}
+	}
+
+	generator, err := fakedata.NewGenerator(schema, randomSeed(), recipe.Now())
+	if err != nil {
+		log.Errorf("%v", err)
+		os.Exit(1)
+	}
+
 	output, closeFunc, err := csv.NewOutputSource(args[0])
 	if err != nil {
 		log.Errorf("%+v", err)
@@ -56,47 +108,15 @@ func runWrite(cmd *cobra.Command, args []string) {
 	}
 	defer func() { _ = closeFunc() }()
 
-	_ = output.Write([]string{
-		"voter_id",
-		"first",
-		"last",
-		"address",
-		"city",
-		"state",
-		"zipcode",
-		"birthdate",
-		"party",
-		"sent",
-		"email",
-	})
-
+	_ = output.Write(generator.Header())
 	for i := 0; i < lines; i++ {
-		var sent string
-		if rand.Intn(100) < 10 {
-			sent = faker.Date().Between(time.Now().AddDate(0, 0, -10), time.Now().AddDate(0, 0, 10)).Format("2006-01-02")
-		}
-
-		_ = output.Write([]string{
-			faker.Number().Between(100000, 99999999),
-			faker.Name().FirstName(),
-			faker.Name().LastName(),
-			faker.Address().StreetAddress(),
-			faker.Address().City(),
-			faker.Address().State(),
-			faker.Address().ZipCode(),
-			faker.Date().Birthday(17, 99).Format("2006-01-02"),
-			faker.RandomChoice([]string{
-				"REP",
-				"DEM",
-				"",
-				"IND",
-				"GRN",
-			}),
-			sent,
-			faker.Internet().Email(),
-		})
+		_ = output.Write(generator.Row())
 	}
 	output.Flush()
+	if err := output.Error(); err != nil {
+		log.Errorf("Error writing CSV: %v", err)
+		os.Exit(1)
+	}
 }
 
 func init() {
@@ -104,11 +124,5 @@ func init() {
 
 	// Here you will define your flags and configuration settings.
 	writeCmd.Flags().IntVarP(&lines, "lines", "n", 100, "Number of lines to write")
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// writeCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// writeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	writeCmd.Flags().StringVarP(&writeSchema, "schema", "s", "", "--schema schema.yaml (YAML or JSON list of columns and their generators)")
 }
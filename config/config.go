@@ -0,0 +1,168 @@
+// Package config finds the defaults of command flags in the csv-chef config
+// file, in the named profiles it holds and in CSVCHEF_ environment
+// variables, and keeps track of where each value came from.
+//
+// Settings are grouped by command, so the config file
+//
+//	bake:
+//	  delimiter: ";"
+//	  sanitize: true
+//	profiles:
+//	  vendorX:
+//	    bake:
+//	      delimiter: "|"
+//
+// makes bake use semicolons unless the vendorX profile is chosen. A flag
+// given on the command line wins over an environment variable such as
+// CSVCHEF_BAKE_DELIMITER, which wins over the profile, which wins over the
+// top of the config file.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix starts the names of the environment variables that set flag
+// defaults, like CSVCHEF_BAKE_DELIMITER, and of CSVCHEF_PROFILE.
+const EnvPrefix = "CSVCHEF"
+
+// Setting is a flag whose default can be configured. Key is its name in the
+// config file and environment variables.
+type Setting struct {
+	Key  string
+	Flag string
+}
+
+// Kinds of sources, from strongest to weakest.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceProfile = "profile"
+	SourceFile    = "config file"
+	SourceDefault = "default"
+)
+
+// Source says where a value came from. Name is the environment variable,
+// profile or file it was found in.
+type Source struct {
+	Kind string
+	Name string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + " " + s.Name
+}
+
+// Entry is the effective value of a setting of a command.
+type Entry struct {
+	Key    string
+	Value  string
+	Source Source
+}
+
+// Config looks up settings in a loaded config file and the environment.
+type Config struct {
+	v         *viper.Viper
+	profile   string
+	lookupEnv func(string) (string, bool)
+}
+
+// New returns a Config reading the file loaded by v, if any. A non-empty
+// profile must be one of the profiles in the file.
+func New(v *viper.Viper, profile string) (*Config, error) {
+	if profile != "" {
+		if v.ConfigFileUsed() == "" {
+			return nil, fmt.Errorf("profile %s needs a config file, but none was found", profile)
+		}
+		if !v.IsSet("profiles." + profile) {
+			return nil, fmt.Errorf("profile %s not found in %s", profile, v.ConfigFileUsed())
+		}
+	}
+	return &Config{v: v, profile: profile, lookupEnv: os.LookupEnv}, nil
+}
+
+// File returns the config file in use, or "" if there is none.
+func (c *Config) File() string {
+	return c.v.ConfigFileUsed()
+}
+
+// Profile returns the chosen profile, or "".
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// EnvName returns the environment variable that sets key for command.
+func EnvName(command, key string) string {
+	return strings.ToUpper(EnvPrefix + "_" + command + "_" + key)
+}
+
+// Lookup returns the value of key for command and where it came from, or
+// false if it isn't configured.
+func (c *Config) Lookup(command, key string) (interface{}, Source, bool) {
+	env := EnvName(command, key)
+	if value, ok := c.lookupEnv(env); ok {
+		return value, Source{Kind: SourceEnv, Name: env}, true
+	}
+	if c.profile != "" {
+		if path := "profiles." + c.profile + "." + command + "." + key; c.v.IsSet(path) {
+			return c.v.Get(path), Source{Kind: SourceProfile, Name: c.profile}, true
+		}
+	}
+	if path := command + "." + key; c.v.IsSet(path) {
+		return c.v.Get(path), Source{Kind: SourceFile, Name: c.v.ConfigFileUsed()}, true
+	}
+	return nil, Source{}, false
+}
+
+// Apply sets the flags of command that weren't given on the command line to
+// their configured values, and returns the effective value of every setting.
+func (c *Config) Apply(command string, flags *pflag.FlagSet, settings []Setting) ([]Entry, error) {
+	entries := make([]Entry, 0, len(settings))
+	for _, s := range settings {
+		f := flags.Lookup(s.Flag)
+		if f == nil {
+			return nil, fmt.Errorf("%s has no flag %s", command, s.Flag)
+		}
+		source := Source{Kind: SourceDefault}
+		if f.Changed {
+			source = Source{Kind: SourceFlag}
+		} else if value, from, ok := c.Lookup(command, s.Key); ok {
+			if err := setFlag(f, value); err != nil {
+				return nil, fmt.Errorf("%s.%s from %s: %v", command, s.Key, from, err)
+			}
+			source = from
+		}
+		entries = append(entries, Entry{Key: command + "." + s.Key, Value: f.Value.String(), Source: source})
+	}
+	return entries, nil
+}
+
+// setFlag sets f to a configured value without marking it as given on the
+// command line. Lists may be written as YAML lists or separated by commas.
+func setFlag(f *pflag.Flag, value interface{}) error {
+	if list, ok := f.Value.(pflag.SliceValue); ok {
+		var values []string
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+		case string:
+			if v != "" {
+				values = strings.Split(v, ",")
+			}
+		default:
+			values = []string{fmt.Sprint(v)}
+		}
+		return list.Replace(values)
+	}
+	return f.Value.Set(fmt.Sprint(value))
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const testConfig = `bake:
+  delimiter: ";"
+  sanitize: true
+  sort_by: [2, 1]
+lint:
+  disable: [unused-variable, header-column]
+profiles:
+  vendorX:
+    bake:
+      delimiter: "|"
+      max_groups: 50
+`
+
+// loadViper reads source as the config file config.yaml in a temporary
+// directory, returning the path of the file.
+func loadViper(t *testing.T, source string) (*viper.Viper, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return v, path
+}
+
+// env returns a lookupEnv function that finds the given variables.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestNew(t *testing.T) {
+	v, path := loadViper(t, testConfig)
+	tests := []struct {
+		name    string
+		v       *viper.Viper
+		profile string
+		wantErr string
+	}{
+		{
+			name: "no profile",
+			v:    v,
+		},
+		{
+			name:    "profile",
+			v:       v,
+			profile: "vendorX",
+		},
+		{
+			name:    "unknown profile",
+			v:       v,
+			profile: "vendorY",
+			wantErr: "profile vendorY not found in " + path,
+		},
+		{
+			name:    "profile without a config file",
+			v:       viper.New(),
+			profile: "vendorX",
+			wantErr: "profile vendorX needs a config file, but none was found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.v, tt.profile)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("New() error = %q, want %q", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Lookup(t *testing.T) {
+	v, path := loadViper(t, testConfig)
+	tests := []struct {
+		name       string
+		profile    string
+		env        map[string]string
+		command    string
+		key        string
+		want       interface{}
+		wantSource Source
+		wantOK     bool
+	}{
+		{
+			name:       "config file",
+			command:    "bake",
+			key:        "delimiter",
+			want:       ";",
+			wantSource: Source{Kind: SourceFile, Name: path},
+			wantOK:     true,
+		},
+		{
+			name:       "profile wins over the config file",
+			profile:    "vendorX",
+			command:    "bake",
+			key:        "delimiter",
+			want:       "|",
+			wantSource: Source{Kind: SourceProfile, Name: "vendorX"},
+			wantOK:     true,
+		},
+		{
+			name:       "profile falls back to the config file",
+			profile:    "vendorX",
+			command:    "bake",
+			key:        "sanitize",
+			want:       true,
+			wantSource: Source{Kind: SourceFile, Name: path},
+			wantOK:     true,
+		},
+		{
+			name:       "environment wins over the profile",
+			profile:    "vendorX",
+			env:        map[string]string{"CSVCHEF_BAKE_DELIMITER": "\\t"},
+			command:    "bake",
+			key:        "delimiter",
+			want:       "\\t",
+			wantSource: Source{Kind: SourceEnv, Name: "CSVCHEF_BAKE_DELIMITER"},
+			wantOK:     true,
+		},
+		{
+			name:    "not configured",
+			command: "identity",
+			key:     "sample",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(v, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.lookupEnv = env(tt.env)
+			got, source, ok := c.Lookup(tt.command, tt.key)
+			if !reflect.DeepEqual(got, tt.want) || source != tt.wantSource || ok != tt.wantOK {
+				t.Errorf("Lookup() = %v, %v, %v, want %v, %v, %v", got, source, ok, tt.want, tt.wantSource, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestConfig_Apply(t *testing.T) {
+	v, path := loadViper(t, testConfig)
+	settings := []Setting{
+		{Key: "delimiter", Flag: "delimiter"},
+		{Key: "sanitize", Flag: "sanitize"},
+		{Key: "max_groups", Flag: "max-groups"},
+		{Key: "sort_by", Flag: "sort-by"},
+		{Key: "disable", Flag: "disable"},
+	}
+	tests := []struct {
+		name    string
+		profile string
+		env     map[string]string
+		args    []string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			name: "config file",
+			want: []Entry{
+				{Key: "bake.delimiter", Value: ";", Source: Source{Kind: SourceFile, Name: path}},
+				{Key: "bake.sanitize", Value: "true", Source: Source{Kind: SourceFile, Name: path}},
+				{Key: "bake.max_groups", Value: "0", Source: Source{Kind: SourceDefault}},
+				{Key: "bake.sort_by", Value: "[2,1]", Source: Source{Kind: SourceFile, Name: path}},
+				{Key: "bake.disable", Value: "[]", Source: Source{Kind: SourceDefault}},
+			},
+		},
+		{
+			name:    "flags, environment and profile",
+			profile: "vendorX",
+			env:     map[string]string{"CSVCHEF_BAKE_DISABLE": "a,b", "CSVCHEF_BAKE_SANITIZE": "false"},
+			args:    []string{"--sort-by", "3"},
+			want: []Entry{
+				{Key: "bake.delimiter", Value: "|", Source: Source{Kind: SourceProfile, Name: "vendorX"}},
+				{Key: "bake.sanitize", Value: "false", Source: Source{Kind: SourceEnv, Name: "CSVCHEF_BAKE_SANITIZE"}},
+				{Key: "bake.max_groups", Value: "50", Source: Source{Kind: SourceProfile, Name: "vendorX"}},
+				{Key: "bake.sort_by", Value: "[3]", Source: Source{Kind: SourceFlag}},
+				{Key: "bake.disable", Value: "[a,b]", Source: Source{Kind: SourceEnv, Name: "CSVCHEF_BAKE_DISABLE"}},
+			},
+		},
+		{
+			name:    "bad value",
+			env:     map[string]string{"CSVCHEF_BAKE_MAX_GROUPS": "lots"},
+			wantErr: `bake.max_groups from env CSVCHEF_BAKE_MAX_GROUPS: strconv.ParseInt: parsing "lots": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("bake", pflag.ContinueOnError)
+			flags.String("delimiter", "", "")
+			flags.Bool("sanitize", false, "")
+			flags.Int("max-groups", 0, "")
+			flags.StringArray("sort-by", nil, "")
+			flags.StringSlice("disable", nil, "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := New(v, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.lookupEnv = env(tt.env)
+			got, err := c.Apply("bake", flags, settings)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() got %+v, want %+v", got, tt.want)
+			}
+			if flags.Changed("delimiter") {
+				t.Errorf("Apply() marked a configured flag as given on the command line")
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	v, path := loadViper(t, testConfig)
+	c, err := New(v, "vendorX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []Entry{
+		{Key: "bake.delimiter", Value: "|", Source: Source{Kind: SourceProfile, Name: "vendorX"}},
+		{Key: "bake.force", Value: "false", Source: Source{Kind: SourceDefault}},
+	}
+	var out bytes.Buffer
+	if err := WriteReport(&out, c, entries); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	want := "Config file: " + path + `
+Profile: vendorX
+
+KEY             VALUE  SOURCE
+bake.delimiter  |      profile vendorX
+bake.force      false  default
+`
+	if out.String() != want {
+		t.Errorf("WriteReport() got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteReport writes the config file and profile in use followed by a table
+// of the effective settings and where they came from.
+func WriteReport(w io.Writer, c *Config, entries []Entry) error {
+	file, profile := c.File(), c.Profile()
+	if file == "" {
+		file = "none"
+	}
+	if profile == "" {
+		profile = "none"
+	}
+	if _, err := fmt.Fprintf(w, "Config file: %s\nProfile: %s\n\n", file, profile); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Key, e.Value, e.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,418 @@
+// Package csvdiff compares two CSV files row by row, matching rows on one or
+// more key columns. Both files are first merged as if they were sorted by
+// the key, which needs little memory. If a key turns out to be out of order,
+// the files are compared again with the old file loaded into a hash table
+// keyed on the key columns.
+package csvdiff
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kind is the type of difference found for a key.
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Source opens a fresh reader over one of the files being compared, along
+// with a function that closes it. A source is opened a second time if the
+// files turn out not to be sorted by the key.
+type Source func() (*csv.Reader, func() error, error)
+
+// Options control how rows are matched.
+type Options struct {
+	// KeyColumns are the 1-based columns that identify a row.
+	KeyColumns []int
+	// Header is true when the first row of each file is a header row. Header
+	// rows are not compared; their names label the columns in changes.
+	Header bool
+	// Sorted is true when both files must be sorted by the key columns.
+	// Changes are then reported as soon as they are found, and a key that
+	// is out of order is an error instead of a reason to compare again.
+	Sorted bool
+	// MaxPending is the number of changes held in memory while it isn't yet
+	// known whether the files are sorted; the rest are kept in a temporary
+	// file. 0 or less uses DefaultMaxPending.
+	MaxPending int
+}
+
+// ColumnChange is one cell that differs between the old and new versions
+// of a row. Column is 1-based and Name is the column's header, if any.
+type ColumnChange struct {
+	Column int    `json:"column"`
+	Name   string `json:"name,omitempty"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// Change describes one row that was added, removed or changed. Old is nil for
+// added rows and New is nil for removed rows.
+type Change struct {
+	Kind    Kind           `json:"kind"`
+	Key     []string       `json:"key"`
+	Old     []string       `json:"old,omitempty"`
+	New     []string       `json:"new,omitempty"`
+	Columns []ColumnChange `json:"columns,omitempty"`
+}
+
+// Summary counts the rows in each category once a comparison is complete.
+// Streamed is true if the files were compared without hashing.
+type Summary struct {
+	Added     int  `json:"added"`
+	Removed   int  `json:"removed"`
+	Changed   int  `json:"changed"`
+	Unchanged int  `json:"unchanged"`
+	Streamed  bool `json:"streamed"`
+}
+
+// Differences returns the number of rows that differ.
+func (s Summary) Differences() int {
+	return s.Added + s.Removed + s.Changed
+}
+
+// Reporter receives the results of a comparison.
+type Reporter interface {
+	// Start is called once with the header of the new file, or nil if the
+	// files have no header.
+	Start(header []string) error
+	Report(change Change) error
+	Finish(summary Summary) error
+}
+
+type file struct {
+	name   string
+	source Source
+	reader *csv.Reader
+	close  func() error
+	header []string
+	rows   int
+	// previous is the last key read, used to check the order of sorted
+	// files.
+	previous []string
+}
+
+// Compare reports every row that differs between old and new.
+func Compare(oldSource, newSource Source, options Options, reporter Reporter) (Summary, error) {
+	if len(options.KeyColumns) == 0 {
+		return Summary{}, fmt.Errorf("at least one key column is required")
+	}
+	for _, c := range options.KeyColumns {
+		if c < 1 {
+			return Summary{}, fmt.Errorf("key columns start at 1, got %d", c)
+		}
+	}
+
+	c := &comparison{
+		options: options,
+		old:     &file{name: "old", source: oldSource},
+		new:     &file{name: "new", source: newSource},
+	}
+	for _, f := range []*file{c.old, c.new} {
+		if err := c.open(f); err != nil {
+			return Summary{}, err
+		}
+		defer func(f *file) { _ = f.close() }(f)
+	}
+	if err := reporter.Start(c.new.header); err != nil {
+		return Summary{}, err
+	}
+
+	var err error
+	if options.Sorted {
+		err = c.merge(reporter)
+	} else {
+		err = c.mergeOrHash(reporter)
+	}
+	if err != nil {
+		return c.summary, err
+	}
+	return c.summary, reporter.Finish(c.summary)
+}
+
+// mergeOrHash merges the files, holding the changes back until the end in
+// case a key is out of order. If one is, the files are opened again and
+// compared with hash instead.
+func (c *comparison) mergeOrHash(reporter Reporter) error {
+	held := newPending(c.options.MaxPending)
+	defer func() { _ = held.discard() }()
+
+	err := c.merge(held)
+	if _, ok := err.(*orderError); !ok {
+		if err != nil {
+			return err
+		}
+		return held.replay(reporter)
+	}
+
+	if err := held.discard(); err != nil {
+		return err
+	}
+	c.summary = Summary{}
+	for _, f := range []*file{c.old, c.new} {
+		if err := c.reopen(f); err != nil {
+			return err
+		}
+	}
+	return c.hash(reporter)
+}
+
+// orderError is returned by nextSorted when a file isn't sorted by the key.
+type orderError struct {
+	file     string
+	row      int
+	key      []string
+	previous []string
+}
+
+func (e *orderError) Error() string {
+	if compareKeys(e.key, e.previous) == 0 {
+		return fmt.Sprintf("duplicate key %q in %s file", e.key, e.file)
+	}
+	return fmt.Sprintf("%s file row %d: key %q comes before %q, so the file is not sorted by the key", e.file, e.row, e.key, e.previous)
+}
+
+// changeReporter is the part of a Reporter that comparisons report changes
+// to.
+type changeReporter interface {
+	Report(change Change) error
+}
+
+type comparison struct {
+	options Options
+	old     *file
+	new     *file
+	summary Summary
+}
+
+func (c *comparison) open(f *file) error {
+	reader, closer, err := f.source()
+	if err != nil {
+		return fmt.Errorf("unable to open %s file: %v", f.name, err)
+	}
+	reader.FieldsPerRecord = -1
+	f.reader, f.close, f.rows, f.previous = reader, closer, 0, nil
+	if c.options.Header {
+		header, err := reader.Read()
+		if err != nil && err != io.EOF {
+			_ = closer()
+			return fmt.Errorf("unable to read %s header: %v", f.name, err)
+		}
+		f.header = header
+		f.rows++
+	}
+	return nil
+}
+
+// reopen closes f and opens it again from the start.
+func (c *comparison) reopen(f *file) error {
+	if err := f.close(); err != nil {
+		return fmt.Errorf("unable to close %s file: %v", f.name, err)
+	}
+	f.close = func() error { return nil }
+	return c.open(f)
+}
+
+// next returns the next row and its key, or io.EOF.
+func (c *comparison) next(f *file) ([]string, []string, error) {
+	row, err := f.reader.Read()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("unable to read %s file: %v", f.name, err)
+		}
+		return nil, nil, err
+	}
+	f.rows++
+	key := make([]string, len(c.options.KeyColumns))
+	for i, column := range c.options.KeyColumns {
+		if column > len(row) {
+			return nil, nil, fmt.Errorf("%s file row %d has no key column %d", f.name, f.rows, column)
+		}
+		key[i] = row[column-1]
+	}
+	return row, key, nil
+}
+
+func compareKeys(a, b []string) int {
+	for i := range a {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// nextSorted is next for a file that should be sorted by the key. It
+// returns an error if a key is not strictly after the one before it.
+func (c *comparison) nextSorted(f *file) ([]string, []string, error) {
+	row, key, err := c.next(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.previous != nil && compareKeys(f.previous, key) >= 0 {
+		return nil, nil, &orderError{file: f.name, row: f.rows, key: key, previous: f.previous}
+	}
+	f.previous = key
+	return row, key, nil
+}
+
+// merge walks both sorted files together.
+func (c *comparison) merge(reporter changeReporter) error {
+	oldRow, oldKey, oldErr := c.nextSorted(c.old)
+	newRow, newKey, newErr := c.nextSorted(c.new)
+	for {
+		if oldErr != nil && oldErr != io.EOF {
+			return oldErr
+		}
+		if newErr != nil && newErr != io.EOF {
+			return newErr
+		}
+		if oldErr == io.EOF && newErr == io.EOF {
+			c.summary.Streamed = true
+			return nil
+		}
+
+		order := 0
+		switch {
+		case oldErr == io.EOF:
+			order = 1
+		case newErr == io.EOF:
+			order = -1
+		default:
+			order = compareKeys(oldKey, newKey)
+		}
+
+		var err error
+		switch {
+		case order < 0:
+			err = c.removed(reporter, oldKey, oldRow)
+			oldRow, oldKey, oldErr = c.nextSorted(c.old)
+		case order > 0:
+			err = c.added(reporter, newKey, newRow)
+			newRow, newKey, newErr = c.nextSorted(c.new)
+		default:
+			err = c.matched(reporter, newKey, oldRow, newRow)
+			oldRow, oldKey, oldErr = c.nextSorted(c.old)
+			newRow, newKey, newErr = c.nextSorted(c.new)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type keyedRow struct {
+	key []string
+	row []string
+}
+
+// hash loads the old file into memory and then streams the new file past
+// it. Changes are reported in the order of the new file, followed by the
+// removed rows in the order of the old file.
+func (c *comparison) hash(reporter Reporter) error {
+	index := make(map[string]int)
+	var oldRows []*keyedRow
+	for {
+		row, key, err := c.next(c.old)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		k := strings.Join(key, "\x00")
+		if _, ok := index[k]; ok {
+			return fmt.Errorf("duplicate key %q in old file", key)
+		}
+		index[k] = len(oldRows)
+		oldRows = append(oldRows, &keyedRow{key: key, row: row})
+	}
+
+	seen := make(map[string]bool)
+	for {
+		row, key, err := c.next(c.new)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		k := strings.Join(key, "\x00")
+		if seen[k] {
+			return fmt.Errorf("duplicate key %q in new file", key)
+		}
+		seen[k] = true
+
+		i, ok := index[k]
+		if !ok {
+			err = c.added(reporter, key, row)
+		} else {
+			err = c.matched(reporter, key, oldRows[i].row, row)
+			oldRows[i] = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, old := range oldRows {
+		if old == nil {
+			continue
+		}
+		if err := c.removed(reporter, old.key, old.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *comparison) added(reporter changeReporter, key, row []string) error {
+	c.summary.Added++
+	return reporter.Report(Change{Kind: Added, Key: key, New: row})
+}
+
+func (c *comparison) removed(reporter changeReporter, key, row []string) error {
+	c.summary.Removed++
+	return reporter.Report(Change{Kind: Removed, Key: key, Old: row})
+}
+
+func (c *comparison) matched(reporter changeReporter, key, oldRow, newRow []string) error {
+	columns := CompareRows(oldRow, newRow, c.new.header)
+	if len(columns) == 0 {
+		c.summary.Unchanged++
+		return nil
+	}
+	c.summary.Changed++
+	return reporter.Report(Change{Kind: Changed, Key: key, Old: oldRow, New: newRow, Columns: columns})
+}
+
+// CompareRows returns the cells that differ between two versions of a row.
+// A cell missing from one version is compared as empty. Column names are
+// taken from header when it has one for the column.
+func CompareRows(oldRow, newRow, header []string) []ColumnChange {
+	var changes []ColumnChange
+	for i := 0; i < len(oldRow) || i < len(newRow); i++ {
+		var o, n string
+		if i < len(oldRow) {
+			o = oldRow[i]
+		}
+		if i < len(newRow) {
+			n = newRow[i]
+		}
+		if o == n {
+			continue
+		}
+		change := ColumnChange{Column: i + 1, Old: o, New: n}
+		if i < len(header) {
+			change.Name = header[i]
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
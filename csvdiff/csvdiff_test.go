@@ -0,0 +1,256 @@
+package csvdiff
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func stringSource(data string) Source {
+	return func() (*csv.Reader, func() error, error) {
+		return csv.NewReader(strings.NewReader(data)), func() error { return nil }, nil
+	}
+}
+
+// useTempDir points the temporary directory at a fresh directory for the
+// test and returns it.
+func useTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old, had := os.LookupEnv("TMPDIR")
+	if err := os.Setenv("TMPDIR", dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if had {
+			_ = os.Setenv("TMPDIR", old)
+		} else {
+			_ = os.Unsetenv("TMPDIR")
+		}
+	})
+	return dir
+}
+
+type recorder struct {
+	header  []string
+	changes []Change
+	summary Summary
+}
+
+func (r *recorder) Start(header []string) error {
+	r.header = header
+	return nil
+}
+
+func (r *recorder) Report(change Change) error {
+	r.changes = append(r.changes, change)
+	return nil
+}
+
+func (r *recorder) Finish(summary Summary) error {
+	r.summary = summary
+	return nil
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		old         string
+		new         string
+		options     Options
+		wantChanges []Change
+		wantSummary Summary
+		wantErr     string
+	}{
+		{
+			name:    "sorted input is streamed",
+			old:     "id,name\n1,ann\n2,bob\n3,cy\n",
+			new:     "id,name\n1,ann\n3,cyd\n4,dee\n",
+			options: Options{KeyColumns: []int{1}, Header: true, Sorted: true},
+			wantChanges: []Change{
+				{Kind: Removed, Key: []string{"2"}, Old: []string{"2", "bob"}},
+				{Kind: Changed, Key: []string{"3"}, Old: []string{"3", "cy"}, New: []string{"3", "cyd"},
+					Columns: []ColumnChange{{Column: 2, Name: "name", Old: "cy", New: "cyd"}}},
+				{Kind: Added, Key: []string{"4"}, New: []string{"4", "dee"}},
+			},
+			wantSummary: Summary{Added: 1, Removed: 1, Changed: 1, Unchanged: 1, Streamed: true},
+		},
+		{
+			name:    "unsorted input is hashed",
+			old:     "3,cy\n1,ann\n2,bob\n",
+			new:     "4,dee\n3,cyd\n1,ann\n",
+			options: Options{KeyColumns: []int{1}},
+			wantChanges: []Change{
+				{Kind: Added, Key: []string{"4"}, New: []string{"4", "dee"}},
+				{Kind: Changed, Key: []string{"3"}, Old: []string{"3", "cy"}, New: []string{"3", "cyd"},
+					Columns: []ColumnChange{{Column: 2, Old: "cy", New: "cyd"}}},
+				{Kind: Removed, Key: []string{"2"}, Old: []string{"2", "bob"}},
+			},
+			wantSummary: Summary{Added: 1, Removed: 1, Changed: 1, Unchanged: 1},
+		},
+		{
+			name:    "composite key and ragged rows",
+			old:     "a,1,x\na,2,y\n",
+			new:     "a,1,x,extra\na,2,y\n",
+			options: Options{KeyColumns: []int{1, 2}},
+			wantChanges: []Change{
+				{Kind: Changed, Key: []string{"a", "1"}, Old: []string{"a", "1", "x"}, New: []string{"a", "1", "x", "extra"},
+					Columns: []ColumnChange{{Column: 4, Old: "", New: "extra"}}},
+			},
+			wantSummary: Summary{Changed: 1, Unchanged: 1, Streamed: true},
+		},
+		{
+			name:        "identical",
+			old:         "1\n2\n",
+			new:         "1\n2\n",
+			options:     Options{KeyColumns: []int{1}},
+			wantSummary: Summary{Unchanged: 2, Streamed: true},
+		},
+		{
+			name:    "input found to be unsorted after some changes is hashed",
+			old:     "1,a\n2,b\n4,d\n",
+			new:     "1,x\n3,c\n2,b\n",
+			options: Options{KeyColumns: []int{1}, MaxPending: 1},
+			wantChanges: []Change{
+				{Kind: Changed, Key: []string{"1"}, Old: []string{"1", "a"}, New: []string{"1", "x"},
+					Columns: []ColumnChange{{Column: 2, Old: "a", New: "x"}}},
+				{Kind: Added, Key: []string{"3"}, New: []string{"3", "c"}},
+				{Kind: Removed, Key: []string{"4"}, Old: []string{"4", "d"}},
+			},
+			wantSummary: Summary{Added: 1, Removed: 1, Changed: 1, Unchanged: 1},
+		},
+		{
+			name:    "changes past MaxPending are held in a temporary file",
+			old:     "1,a\n2,b\n3,c\n",
+			new:     "1,x\n2,y\n4,d\n",
+			options: Options{KeyColumns: []int{1}, MaxPending: 1},
+			wantChanges: []Change{
+				{Kind: Changed, Key: []string{"1"}, Old: []string{"1", "a"}, New: []string{"1", "x"},
+					Columns: []ColumnChange{{Column: 2, Old: "a", New: "x"}}},
+				{Kind: Changed, Key: []string{"2"}, Old: []string{"2", "b"}, New: []string{"2", "y"},
+					Columns: []ColumnChange{{Column: 2, Old: "b", New: "y"}}},
+				{Kind: Removed, Key: []string{"3"}, Old: []string{"3", "c"}},
+				{Kind: Added, Key: []string{"4"}, New: []string{"4", "d"}},
+			},
+			wantSummary: Summary{Added: 1, Removed: 1, Changed: 2, Streamed: true},
+		},
+		{
+			name:    "input that must be sorted is out of order",
+			old:     "1\n2\n",
+			new:     "1\n3\n2\n",
+			options: Options{KeyColumns: []int{1}, Sorted: true},
+			wantErr: `new file row 3: key ["2"] comes before ["3"], so the file is not sorted by the key`,
+		},
+		{
+			name:    "duplicate key in input that must be sorted",
+			old:     "1,a\n1,b\n",
+			new:     "1,a\n",
+			options: Options{KeyColumns: []int{1}, Sorted: true},
+			wantErr: `duplicate key ["1"] in old file`,
+		},
+		{
+			name:    "duplicate key",
+			old:     "1,a\n1,b\n",
+			new:     "1,a\n",
+			options: Options{KeyColumns: []int{1}},
+			wantErr: `duplicate key ["1"] in old file`,
+		},
+		{
+			name:    "missing key column",
+			old:     "1\n",
+			new:     "1\n",
+			options: Options{KeyColumns: []int{2}},
+			wantErr: "old file row 1 has no key column 2",
+		},
+		{
+			name:    "no key",
+			old:     "1\n",
+			new:     "1\n",
+			wantErr: "at least one key column is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTempDir(t)
+			r := &recorder{}
+			summary, err := Compare(stringSource(tt.old), stringSource(tt.new), tt.options, r)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Fatalf("Compare() error = %q, want %q", gotErr, tt.wantErr)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(r.changes, tt.wantChanges) {
+				t.Errorf("Compare() changes = %+v, want %+v", r.changes, tt.wantChanges)
+			}
+			if summary != tt.wantSummary || r.summary != tt.wantSummary {
+				t.Errorf("Compare() summary = %+v, want %+v", summary, tt.wantSummary)
+			}
+			if left, _ := ioutil.ReadDir(dir); len(left) != 0 {
+				t.Errorf("Compare() left %d temporary file(s) behind", len(left))
+			}
+		})
+	}
+}
+
+func TestReporters(t *testing.T) {
+	old := "id,name\n1,ann\n2,bob\n"
+	new := "id,name\n2,rob\n3,cy\n"
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "text",
+			want: "- [1] 1,ann\n" +
+				"~ [2]\n" +
+				"    column 2 (name): \"bob\" -> \"rob\"\n" +
+				"+ [3] 3,cy\n" +
+				"1 added, 1 removed, 1 changed, 0 unchanged\n",
+		},
+		{
+			format: "csv",
+			want: "change,key,column,name,old,new\n" +
+				"removed,1,1,id,1,\n" +
+				"removed,1,2,name,ann,\n" +
+				"changed,2,2,name,bob,rob\n" +
+				"added,3,1,id,,3\n" +
+				"added,3,2,name,,cy\n",
+		},
+		{
+			format: "json",
+			want: `{"header":["id","name"],"changes":[` + "\n" +
+				`{"kind":"removed","key":["1"],"old":["1","ann"]},` + "\n" +
+				`{"kind":"changed","key":["2"],"old":["2","bob"],"new":["2","rob"],"columns":[{"column":2,"name":"name","old":"bob","new":"rob"}]},` + "\n" +
+				`{"kind":"added","key":["3"],"new":["3","cy"]}` + "\n" +
+				`],"summary":{"added":1,"removed":1,"changed":1,"unchanged":0,"streamed":true}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			reporter, err := NewReporter(tt.format, &b, ',')
+			if err != nil {
+				t.Fatalf("NewReporter() unexpected error: %v", err)
+			}
+			if _, err := Compare(stringSource(old), stringSource(new), Options{KeyColumns: []int{1}, Header: true, Sorted: true}, reporter); err != nil {
+				t.Fatalf("Compare() unexpected error: %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("report =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewReporter("xml", &bytes.Buffer{}, ','); err == nil {
+		t.Errorf("NewReporter() expected an error for an unknown format")
+	}
+}
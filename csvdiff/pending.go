@@ -0,0 +1,88 @@
+package csvdiff
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// DefaultMaxPending is the number of changes held in memory while the files
+// are merged when no other limit is given.
+const DefaultMaxPending = 10000
+
+// pending holds the changes a merge finds until it is known that both files
+// are sorted. Past max changes they are written to a temporary file, so a
+// large difference between large files doesn't have to fit in memory.
+type pending struct {
+	max     int
+	changes []Change
+	spill   *os.File
+	encoder *json.Encoder
+}
+
+func newPending(max int) *pending {
+	if max <= 0 {
+		max = DefaultMaxPending
+	}
+	return &pending{max: max}
+}
+
+// Report holds on to a change.
+func (p *pending) Report(change Change) error {
+	if p.spill == nil && len(p.changes) < p.max {
+		p.changes = append(p.changes, change)
+		return nil
+	}
+	if p.spill == nil {
+		f, err := os.CreateTemp("", "csv-chef-diff-*.json")
+		if err != nil {
+			return err
+		}
+		p.spill, p.encoder = f, json.NewEncoder(f)
+	}
+	return p.encoder.Encode(change)
+}
+
+// replay passes the changes to reporter in the order they were found.
+func (p *pending) replay(reporter Reporter) error {
+	for _, change := range p.changes {
+		if err := reporter.Report(change); err != nil {
+			return err
+		}
+	}
+	if p.spill == nil {
+		return nil
+	}
+	if _, err := p.spill.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(p.spill)
+	for {
+		var change Change
+		err := decoder.Decode(&change)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := reporter.Report(change); err != nil {
+			return err
+		}
+	}
+}
+
+// discard drops the changes and removes the temporary file, if any.
+func (p *pending) discard() error {
+	p.changes = nil
+	if p.spill == nil {
+		return nil
+	}
+	name := p.spill.Name()
+	err := p.spill.Close()
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	p.spill, p.encoder = nil, nil
+	return err
+}
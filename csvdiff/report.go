@@ -0,0 +1,165 @@
+package csvdiff
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NewReporter returns the reporter for the named format: text, csv or json.
+// The comma is used as the delimiter for the csv format.
+func NewReporter(format string, w io.Writer, comma rune) (Reporter, error) {
+	switch format {
+	case "text":
+		return &TextReporter{w: w}, nil
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Comma = comma
+		return &CSVReporter{w: writer}, nil
+	case "json":
+		return &JSONReporter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected text, csv or json", format)
+}
+
+// TextReporter writes a human-readable report. Added rows are prefixed with
+// +, removed rows with - and changed rows with ~, followed by one line for
+// each changed column.
+type TextReporter struct {
+	w io.Writer
+}
+
+func (r *TextReporter) Start(header []string) error {
+	return nil
+}
+
+func formatKey(key []string) string {
+	return strings.Join(key, ", ")
+}
+
+func formatRow(row []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	_ = w.Write(row)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (r *TextReporter) Report(change Change) error {
+	var err error
+	switch change.Kind {
+	case Added:
+		_, err = fmt.Fprintf(r.w, "+ [%s] %s\n", formatKey(change.Key), formatRow(change.New))
+	case Removed:
+		_, err = fmt.Fprintf(r.w, "- [%s] %s\n", formatKey(change.Key), formatRow(change.Old))
+	case Changed:
+		if _, err = fmt.Fprintf(r.w, "~ [%s]\n", formatKey(change.Key)); err != nil {
+			return err
+		}
+		for _, column := range change.Columns {
+			name := "column " + strconv.Itoa(column.Column)
+			if column.Name != "" {
+				name = fmt.Sprintf("%s (%s)", name, column.Name)
+			}
+			if _, err = fmt.Fprintf(r.w, "    %s: %q -> %q\n", name, column.Old, column.New); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+func (r *TextReporter) Finish(summary Summary) error {
+	_, err := fmt.Fprintf(r.w, "%d added, %d removed, %d changed, %d unchanged\n",
+		summary.Added, summary.Removed, summary.Changed, summary.Unchanged)
+	return err
+}
+
+// CSVReporter writes one row per difference with the columns change, key,
+// column, name, old and new. Added and removed rows produce one row for each
+// of their columns. Composite keys are joined with a comma.
+type CSVReporter struct {
+	w      *csv.Writer
+	header []string
+}
+
+func (r *CSVReporter) Start(header []string) error {
+	r.header = header
+	return r.w.Write([]string{"change", "key", "column", "name", "old", "new"})
+}
+
+func (r *CSVReporter) Report(change Change) error {
+	key := strings.Join(change.Key, ",")
+	var columns []ColumnChange
+	switch change.Kind {
+	case Changed:
+		columns = change.Columns
+	case Added:
+		for i, value := range change.New {
+			columns = append(columns, ColumnChange{Column: i + 1, New: value})
+		}
+	case Removed:
+		for i, value := range change.Old {
+			columns = append(columns, ColumnChange{Column: i + 1, Old: value})
+		}
+	}
+	for _, column := range columns {
+		name := column.Name
+		if change.Kind != Changed && column.Column <= len(r.header) {
+			name = r.header[column.Column-1]
+		}
+		record := []string{string(change.Kind), key, strconv.Itoa(column.Column), name, column.Old, column.New}
+		if err := r.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CSVReporter) Finish(summary Summary) error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// JSONReporter writes a single JSON object with the header, a list of
+// changes and the summary. Changes are written as they are found so the
+// whole report is never held in memory.
+type JSONReporter struct {
+	w     io.Writer
+	count int
+}
+
+func (r *JSONReporter) Start(header []string) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.w, "{\"header\":%s,\"changes\":[", encoded)
+	return err
+}
+
+func (r *JSONReporter) Report(change Change) error {
+	encoded, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	separator := "\n"
+	if r.count > 0 {
+		separator = ",\n"
+	}
+	r.count++
+	_, err = fmt.Fprintf(r.w, "%s%s", separator, encoded)
+	return err
+}
+
+func (r *JSONReporter) Finish(summary Summary) error {
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.w, "\n],\"summary\":%s}\n", encoded)
+	return err
+}
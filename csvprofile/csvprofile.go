@@ -0,0 +1,330 @@
+// Package csvprofile summarizes the columns of a CSV file: how many values
+// each has, how many of them are distinct, how long they are, what type they
+// appear to be and which values are most common. It reads the file once and
+// keeps a bounded amount of memory per column, so the distinct counts and
+// top values of columns with very many different values are estimates.
+package csvprofile
+
+import (
+	"encoding/csv"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Type is the kind of value a column appears to hold.
+type Type string
+
+const (
+	Empty   Type = "empty"
+	Integer Type = "integer"
+	Decimal Type = "decimal"
+	Boolean Type = "boolean"
+	Date    Type = "date"
+	Email   Type = "email"
+	Phone   Type = "phone"
+	String  Type = "string"
+)
+
+// DateLayouts are the layouts tried when deciding whether a column holds
+// dates, in order of preference. A column is a date column when every value
+// parses with one of them.
+var DateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02",
+	"01/02/2006",
+	"02/01/2006",
+	"1/2/2006",
+	"2/1/2006",
+	"01/02/06",
+	"01-02-2006",
+	"02-01-2006",
+	"02.01.2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"02-Jan-2006",
+}
+
+// Options control how a file is profiled.
+type Options struct {
+	// Header is true when the first row names the columns.
+	Header bool
+	// TopValues is how many of the most common values to report for each
+	// column.
+	TopValues int
+	// Samples is how many example values to report for each column.
+	Samples int
+	// DistinctLimit is how many different values are counted exactly for
+	// each column. Past that, the distinct count is estimated and only the
+	// most common values are kept. Zero uses 10000.
+	DistinctLimit int
+}
+
+// ValueCount is a value and the number of times it was seen.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Column is the profile of one column. Column is 1-based. The lengths are
+// in characters and only count non-empty values.
+type Column struct {
+	Column              int          `json:"column"`
+	Header              string       `json:"header,omitempty"`
+	NonEmpty            int          `json:"nonEmpty"`
+	Distinct            int          `json:"distinct"`
+	DistinctApproximate bool         `json:"distinctApproximate,omitempty"`
+	MinLength           int          `json:"minLength"`
+	MaxLength           int          `json:"maxLength"`
+	Type                Type         `json:"type"`
+	DateLayout          string       `json:"dateLayout,omitempty"`
+	Top                 []ValueCount `json:"top"`
+	Samples             []string     `json:"samples"`
+}
+
+// Report is the profile of a whole file. Rows does not count the header.
+type Report struct {
+	Rows    int      `json:"rows"`
+	Columns []Column `json:"columns"`
+}
+
+// Profile reads every row from r and profiles each column.
+func Profile(r *csv.Reader, options Options) (*Report, error) {
+	if options.DistinctLimit <= 0 {
+		options.DistinctLimit = 10000
+	}
+	r.FieldsPerRecord = -1
+
+	var header []string
+	var columns []*columnProfile
+	report := &Report{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if options.Header && header == nil {
+			header = row
+			continue
+		}
+		for len(columns) < len(row) {
+			columns = append(columns, newColumnProfile(options))
+		}
+		for i, value := range row {
+			columns[i].add(value)
+		}
+		report.Rows++
+	}
+
+	for len(columns) < len(header) {
+		columns = append(columns, newColumnProfile(options))
+	}
+	for i, c := range columns {
+		column := c.column()
+		column.Column = i + 1
+		if i < len(header) {
+			column.Header = header[i]
+		}
+		report.Columns = append(report.Columns, column)
+	}
+	return report, nil
+}
+
+// typeGuess tracks whether every value seen so far could be of one type.
+type typeGuess struct {
+	integer, decimal, boolean, email, phone bool
+	// dateLayouts holds the layouts every value so far has parsed with.
+	dateLayouts []string
+}
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	phonePattern = regexp.MustCompile(`^\+?[0-9 ()\-.]+$`)
+	booleans     = map[string]bool{"true": true, "false": true, "yes": true, "no": true, "y": true, "n": true, "t": true, "f": true}
+)
+
+func (g *typeGuess) add(value string) {
+	if g.integer {
+		_, err := strconv.ParseInt(value, 10, 64)
+		g.integer = err == nil
+	}
+	if g.decimal {
+		_, err := strconv.ParseFloat(value, 64)
+		g.decimal = err == nil
+	}
+	if g.boolean {
+		g.boolean = booleans[strings.ToLower(value)]
+	}
+	if g.email {
+		g.email = emailPattern.MatchString(value)
+	}
+	if g.phone {
+		g.phone = phonePattern.MatchString(value) && countDigits(value) >= 7
+	}
+	if len(g.dateLayouts) > 0 {
+		var layouts []string
+		for _, layout := range g.dateLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				layouts = append(layouts, layout)
+			}
+		}
+		g.dateLayouts = layouts
+	}
+}
+
+// best returns the most specific type that fits every value.
+func (g *typeGuess) best() (Type, string) {
+	switch {
+	case g.integer:
+		return Integer, ""
+	case g.decimal:
+		return Decimal, ""
+	case g.boolean:
+		return Boolean, ""
+	case len(g.dateLayouts) > 0:
+		return Date, g.dateLayouts[0]
+	case g.email:
+		return Email, ""
+	case g.phone:
+		return Phone, ""
+	}
+	return String, ""
+}
+
+func countDigits(s string) int {
+	count := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			count++
+		}
+	}
+	return count
+}
+
+// columnProfile accumulates the values of one column.
+type columnProfile struct {
+	options   Options
+	nonEmpty  int
+	minLength int
+	maxLength int
+	guess     typeGuess
+	counts    map[string]int
+	pruned    bool
+	sketch    *distinctSketch
+	samples   []string
+}
+
+func newColumnProfile(options Options) *columnProfile {
+	return &columnProfile{
+		options: options,
+		guess: typeGuess{
+			integer:     true,
+			decimal:     true,
+			boolean:     true,
+			email:       true,
+			phone:       true,
+			dateLayouts: DateLayouts,
+		},
+		counts: make(map[string]int),
+	}
+}
+
+func (c *columnProfile) add(value string) {
+	if value == "" {
+		return
+	}
+	if _, seen := c.counts[value]; !seen && len(c.samples) < c.options.Samples {
+		c.samples = append(c.samples, value)
+	}
+	c.counts[value]++
+	if c.sketch != nil {
+		c.sketch.add(value)
+	}
+	if len(c.counts) > c.options.DistinctLimit {
+		c.prune()
+	}
+
+	length := utf8.RuneCountInString(value)
+	if c.nonEmpty == 0 || length < c.minLength {
+		c.minLength = length
+	}
+	if length > c.maxLength {
+		c.maxLength = length
+	}
+	c.nonEmpty++
+	c.guess.add(value)
+}
+
+// prune keeps the counts of the most common half of the values once there
+// are too many to count exactly, switching the distinct count to an
+// estimate. Values dropped here that turn up again start counting from one,
+// so top values seen after pruning are undercounted.
+func (c *columnProfile) prune() {
+	if c.sketch == nil {
+		c.sketch = newDistinctSketch(1024)
+		for value := range c.counts {
+			c.sketch.add(value)
+		}
+	}
+	c.pruned = true
+	keep := sortedCounts(c.counts)[:c.options.DistinctLimit/2]
+	c.counts = make(map[string]int, len(keep))
+	for _, vc := range keep {
+		c.counts[vc.Value] = vc.Count
+	}
+}
+
+func (c *columnProfile) column() Column {
+	column := Column{
+		NonEmpty:  c.nonEmpty,
+		MinLength: c.minLength,
+		MaxLength: c.maxLength,
+		Type:      Empty,
+		Top:       []ValueCount{},
+		Samples:   []string{},
+	}
+	if c.nonEmpty > 0 {
+		column.Type, column.DateLayout = c.guess.best()
+	}
+
+	column.Distinct = len(c.counts)
+	if c.pruned {
+		column.Distinct = c.sketch.estimate()
+		column.DistinctApproximate = true
+	}
+
+	for _, vc := range sortedCounts(c.counts) {
+		if len(column.Top) == c.options.TopValues {
+			break
+		}
+		column.Top = append(column.Top, vc)
+	}
+	column.Samples = append(column.Samples, c.samples...)
+	return column
+}
+
+// sortedCounts orders values by count, most common first, then by value.
+func sortedCounts(counts map[string]int) []ValueCount {
+	values := make([]ValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
+}
@@ -0,0 +1,120 @@
+package csvprofile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		options Options
+		want    *Report
+	}{
+		{
+			name:    "types",
+			data:    "id,price,paid,when,email,phone,note,blank\n1,1.50,true,01/31/2021,a@b.co,555-123-4567,hi,\n2,2,N,12/01/2020,c@d.org,+44 20 7946 0958,hi there,\n",
+			options: Options{Header: true, TopValues: 1, Samples: 1},
+			want: &Report{Rows: 2, Columns: []Column{
+				{Column: 1, Header: "id", NonEmpty: 2, Distinct: 2, MinLength: 1, MaxLength: 1, Type: Integer, Top: []ValueCount{{"1", 1}}, Samples: []string{"1"}},
+				{Column: 2, Header: "price", NonEmpty: 2, Distinct: 2, MinLength: 1, MaxLength: 4, Type: Decimal, Top: []ValueCount{{"1.50", 1}}, Samples: []string{"1.50"}},
+				{Column: 3, Header: "paid", NonEmpty: 2, Distinct: 2, MinLength: 1, MaxLength: 4, Type: Boolean, Top: []ValueCount{{"N", 1}}, Samples: []string{"true"}},
+				{Column: 4, Header: "when", NonEmpty: 2, Distinct: 2, MinLength: 10, MaxLength: 10, Type: Date, DateLayout: "01/02/2006", Top: []ValueCount{{"01/31/2021", 1}}, Samples: []string{"01/31/2021"}},
+				{Column: 5, Header: "email", NonEmpty: 2, Distinct: 2, MinLength: 6, MaxLength: 7, Type: Email, Top: []ValueCount{{"a@b.co", 1}}, Samples: []string{"a@b.co"}},
+				{Column: 6, Header: "phone", NonEmpty: 2, Distinct: 2, MinLength: 12, MaxLength: 16, Type: Phone, Top: []ValueCount{{"+44 20 7946 0958", 1}}, Samples: []string{"555-123-4567"}},
+				{Column: 7, Header: "note", NonEmpty: 2, Distinct: 2, MinLength: 2, MaxLength: 8, Type: String, Top: []ValueCount{{"hi", 1}}, Samples: []string{"hi"}},
+				{Column: 8, Header: "blank", Type: Empty, Top: []ValueCount{}, Samples: []string{}},
+			}},
+		},
+		{
+			name:    "day first dates",
+			data:    "31/01/2021\n01/12/2020\n",
+			options: Options{},
+			want: &Report{Rows: 2, Columns: []Column{
+				{Column: 1, NonEmpty: 2, Distinct: 2, MinLength: 10, MaxLength: 10, Type: Date, DateLayout: "02/01/2006", Top: []ValueCount{}, Samples: []string{}},
+			}},
+		},
+		{
+			name:    "top values and ragged rows",
+			data:    "name,city\nann,Paris\nbob\nann,Rome\ncat,Paris,extra\n",
+			options: Options{Header: true, TopValues: 2, Samples: 5},
+			want: &Report{Rows: 4, Columns: []Column{
+				{Column: 1, Header: "name", NonEmpty: 4, Distinct: 3, MinLength: 3, MaxLength: 3, Type: String, Top: []ValueCount{{"ann", 2}, {"bob", 1}}, Samples: []string{"ann", "bob", "cat"}},
+				{Column: 2, Header: "city", NonEmpty: 3, Distinct: 2, MinLength: 4, MaxLength: 5, Type: String, Top: []ValueCount{{"Paris", 2}, {"Rome", 1}}, Samples: []string{"Paris", "Rome"}},
+				{Column: 3, NonEmpty: 1, Distinct: 1, MinLength: 5, MaxLength: 5, Type: String, Top: []ValueCount{{"extra", 1}}, Samples: []string{"extra"}},
+			}},
+		},
+		{
+			name:    "header only",
+			data:    "a,b\n",
+			options: Options{Header: true},
+			want: &Report{Rows: 0, Columns: []Column{
+				{Column: 1, Header: "a", Type: Empty, Top: []ValueCount{}, Samples: []string{}},
+				{Column: 2, Header: "b", Type: Empty, Top: []ValueCount{}, Samples: []string{}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Profile(csv.NewReader(strings.NewReader(tt.data)), tt.options)
+			if err != nil {
+				t.Fatalf("Profile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Profile() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfile_DistinctLimit(t *testing.T) {
+	var data strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&data, "v%d\n", i)
+		if i%10 == 0 {
+			data.WriteString("common\n")
+		}
+	}
+	report, err := Profile(csv.NewReader(strings.NewReader(data.String())), Options{TopValues: 1, DistinctLimit: 1000})
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	column := report.Columns[0]
+	if !column.DistinctApproximate {
+		t.Errorf("Profile() distinct count should be approximate")
+	}
+	if column.Distinct < 18000 || column.Distinct > 22000 {
+		t.Errorf("Profile() distinct = %d, want about 20001", column.Distinct)
+	}
+	if len(column.Top) != 1 || column.Top[0].Value != "common" {
+		t.Errorf("Profile() top = %v, want common first", column.Top)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	report := &Report{Rows: 2, Columns: []Column{
+		{Column: 1, Header: "when", NonEmpty: 2, Distinct: 2, MinLength: 10, MaxLength: 10, Type: Date, DateLayout: "2006-01-02", Top: []ValueCount{{"2021-01-02", 1}}, Samples: []string{"2021-01-02"}},
+		{Column: 2, Header: "blank", Type: Empty, Distinct: 40, DistinctApproximate: true, Top: []ValueCount{}, Samples: []string{}},
+	}}
+	want := "2 rows, 2 columns\n\n" +
+		"#  HEADER  TYPE               NON-EMPTY  DISTINCT  LENGTH  TOP VALUES        SAMPLES\n" +
+		"1  when    date (2006-01-02)  2          2         10-10   \"2021-01-02\" (1)  \"2021-01-02\"\n" +
+		"2  blank   empty              0          ~40       -                         \n"
+
+	var b bytes.Buffer
+	if err := Write(&b, report, "table"); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	if err := Write(&b, report, "xml"); err == nil {
+		t.Errorf("Write() with unknown format should fail")
+	}
+}
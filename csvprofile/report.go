@@ -0,0 +1,58 @@
+package csvprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Write writes the report in the named format: table or json.
+func Write(w io.Writer, report *Report, format string) error {
+	switch format {
+	case "table":
+		return writeTable(w, report)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return fmt.Errorf("unknown format %q, expected table or json", format)
+}
+
+// writeTable writes one line per column. Approximate distinct counts are
+// prefixed with ~.
+func writeTable(w io.Writer, report *Report) error {
+	if _, err := fmt.Fprintf(w, "%d rows, %d columns\n\n", report.Rows, len(report.Columns)); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "#\tHEADER\tTYPE\tNON-EMPTY\tDISTINCT\tLENGTH\tTOP VALUES\tSAMPLES")
+	for _, c := range report.Columns {
+		columnType := string(c.Type)
+		if c.DateLayout != "" {
+			columnType += " (" + c.DateLayout + ")"
+		}
+		distinct := strconv.Itoa(c.Distinct)
+		if c.DistinctApproximate {
+			distinct = "~" + distinct
+		}
+		length := "-"
+		if c.NonEmpty > 0 {
+			length = fmt.Sprintf("%d-%d", c.MinLength, c.MaxLength)
+		}
+		top := make([]string, 0, len(c.Top))
+		for _, vc := range c.Top {
+			top = append(top, fmt.Sprintf("%q (%d)", vc.Value, vc.Count))
+		}
+		samples := make([]string, 0, len(c.Samples))
+		for _, s := range c.Samples {
+			samples = append(samples, strconv.Quote(s))
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			c.Column, c.Header, columnType, c.NonEmpty, distinct, length, strings.Join(top, ", "), strings.Join(samples, ", "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,75 @@
+package csvprofile
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"math"
+)
+
+// distinctSketch estimates the number of distinct values it has been given
+// by keeping the k smallest hashes seen (a "k minimum values" sketch). If
+// the hashes are spread evenly, the kth smallest of n distinct hashes is
+// about k/n of the way through the range of hashes.
+type distinctSketch struct {
+	k      int
+	hashes hashHeap
+	seen   map[uint64]bool
+}
+
+func newDistinctSketch(k int) *distinctSketch {
+	return &distinctSketch{k: k, seen: make(map[uint64]bool, k)}
+}
+
+func (s *distinctSketch) add(value string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	hash := mix(h.Sum64())
+	if s.seen[hash] {
+		return
+	}
+	if len(s.hashes) < s.k {
+		heap.Push(&s.hashes, hash)
+		s.seen[hash] = true
+		return
+	}
+	// the heap holds the largest of the k smallest hashes on top
+	if hash < s.hashes[0] {
+		delete(s.seen, s.hashes[0])
+		s.hashes[0] = hash
+		heap.Fix(&s.hashes, 0)
+		s.seen[hash] = true
+	}
+}
+
+// mix spreads the bits of an FNV hash, whose high bits vary little between
+// similar short strings, so the hashes are evenly distributed.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (s *distinctSketch) estimate() int {
+	if len(s.hashes) < s.k {
+		return len(s.hashes)
+	}
+	fraction := float64(s.hashes[0]) / math.MaxUint64
+	return int(float64(s.k-1) / fraction)
+}
+
+// hashHeap is a max-heap of hashes.
+type hashHeap []uint64
+
+func (h hashHeap) Len() int            { return len(h) }
+func (h hashHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h hashHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hashHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *hashHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
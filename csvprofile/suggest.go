@@ -0,0 +1,82 @@
+package csvprofile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cleanup is a recipe pipe suggested for a column, with the reason for
+// each step.
+type Cleanup struct {
+	Steps   []string
+	Reasons []string
+}
+
+// Pipe returns the steps joined into a recipe pipe, or "" if there are none.
+func (c Cleanup) Pipe() string {
+	return strings.Join(c.Steps, " -> ")
+}
+
+func (c *Cleanup) add(step string, reason string, args ...interface{}) {
+	c.Steps = append(c.Steps, step)
+	c.Reasons = append(c.Reasons, fmt.Sprintf(reason, args...))
+}
+
+// SuggestCleanup looks at sample values from a column and suggests recipe
+// functions that tidy them up: trim when values have stray whitespace,
+// rewriting dates that all share a layout as 2006-01-02, and trimZeros for
+// numbers padded with zeros after the decimal point. Values that are
+// already tidy get an empty Cleanup.
+func SuggestCleanup(values []string) Cleanup {
+	var cleanup Cleanup
+
+	var trimmed []string
+	padded := 0
+	for _, v := range values {
+		t := strings.TrimSpace(v)
+		if t != v {
+			padded++
+		}
+		trimmed = append(trimmed, t)
+	}
+	if padded > 0 {
+		cleanup.add("trim", "%d of %d values have stray whitespace", padded, len(values))
+	}
+
+	guess := typeGuess{integer: true, decimal: true, dateLayouts: DateLayouts}
+	nonEmpty, zeroPadded, example := 0, 0, ""
+	for _, v := range trimmed {
+		if v == "" {
+			continue
+		}
+		nonEmpty++
+		guess.add(v)
+		if strings.Contains(v, ".") && (strings.HasSuffix(v, "0") || strings.HasSuffix(v, ".")) {
+			zeroPadded++
+			if example == "" {
+				example = v
+			}
+		}
+	}
+	if nonEmpty == 0 {
+		return cleanup
+	}
+
+	columnType, layout := guess.best()
+	switch {
+	case columnType == Date && layout != "2006-01-02" && !strings.Contains(layout, "15"):
+		// layouts with a time of day are left alone rather than lose it
+		cleanup.add(
+			fmt.Sprintf("readDate(%s) -> formatDate(\"2006-01-02\")", strconv.Quote(layout)),
+			"dates look like %s", layout,
+		)
+	case columnType == Decimal && zeroPadded > 0 && nonEmpty == len(trimmed):
+		// trimZeros fails on empty values, so it's only suggested when
+		// there aren't any
+		number, _ := strconv.ParseFloat(example, 64)
+		cleanup.add("trimZeros", "%d of %d numbers have padded decimals, e.g. %s for %s",
+			zeroPadded, nonEmpty, example, strconv.FormatFloat(number, 'f', -1, 64))
+	}
+	return cleanup
+}
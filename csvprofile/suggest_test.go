@@ -0,0 +1,65 @@
+package csvprofile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestCleanup(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   Cleanup
+	}{
+		{
+			name:   "already tidy",
+			values: []string{"Ann", "Bob", ""},
+		},
+		{
+			name:   "stray whitespace",
+			values: []string{" Ann", "Bob", "Cy\t"},
+			want:   Cleanup{Steps: []string{"trim"}, Reasons: []string{"2 of 3 values have stray whitespace"}},
+		},
+		{
+			name:   "dates with a consistent layout",
+			values: []string{"01/31/1980", "", "12/01/1975"},
+			want:   Cleanup{Steps: []string{`readDate("01/02/2006") -> formatDate("2006-01-02")`}, Reasons: []string{"dates look like 01/02/2006"}},
+		},
+		{
+			name:   "padded dates are trimmed first",
+			values: []string{"31 Jan 2021 ", "1 Dec 2020"},
+			want: Cleanup{
+				Steps:   []string{"trim", `readDate("2 Jan 2006") -> formatDate("2006-01-02")`},
+				Reasons: []string{"1 of 2 values have stray whitespace", "dates look like 2 Jan 2006"},
+			},
+		},
+		{
+			name:   "dates already in the canonical layout",
+			values: []string{"2021-01-31", "2020-12-01"},
+		},
+		{
+			name:   "dates with times are left alone",
+			values: []string{"2021-01-31 10:00:00", "2020-12-01 23:59:59"},
+		},
+		{
+			name:   "numbers with padded decimals",
+			values: []string{"1.50", "2", "3.25"},
+			want:   Cleanup{Steps: []string{"trimZeros"}, Reasons: []string{"1 of 3 numbers have padded decimals, e.g. 1.50 for 1.5"}},
+		},
+		{
+			name:   "padded numbers with empty values",
+			values: []string{"1.50", ""},
+		},
+		{
+			name:   "integers keep leading zeros",
+			values: []string{"007", "010"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SuggestCleanup(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SuggestCleanup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
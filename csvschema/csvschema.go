@@ -0,0 +1,389 @@
+// Package csvschema checks CSV files against a declared contract: the names
+// and order of the columns and, for each column, whether it may be empty,
+// what type its values have, a pattern or list of values they must match,
+// the range they must fall in and whether they must be unique. Violations are
+// reported per row and column and counted per column and rule.
+package csvschema
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Column types.
+const (
+	String  = "string"
+	Integer = "integer"
+	Decimal = "decimal"
+	Boolean = "boolean"
+	Date    = "date"
+	Email   = "email"
+)
+
+var columnTypes = []string{String, Integer, Decimal, Boolean, Date, Email}
+
+// Rules a value or row can break.
+const (
+	RuleHeader   = "header"
+	RuleColumns  = "columns"
+	RuleRequired = "required"
+	RuleType     = "type"
+	RulePattern  = "pattern"
+	RuleValues   = "values"
+	RuleMin      = "min"
+	RuleMax      = "max"
+	RuleUnique   = "unique"
+)
+
+// Schema is the contract for a file: its columns, in order.
+type Schema struct {
+	Columns []Column `yaml:"columns"`
+}
+
+// Column is the contract for one column. Type defaults to string. Format is
+// the layout of date values, 2006-01-02 by default. Min and Max are numbers
+// for integer and decimal columns and dates in Format for date columns.
+type Column struct {
+	Name     string   `yaml:"name"`
+	Required bool     `yaml:"required"`
+	Type     string   `yaml:"type"`
+	Format   string   `yaml:"format"`
+	Pattern  string   `yaml:"pattern"`
+	Values   []string `yaml:"values"`
+	Min      string   `yaml:"min"`
+	Max      string   `yaml:"max"`
+	Unique   bool     `yaml:"unique"`
+}
+
+// LoadSchema reads a YAML or JSON schema and checks that it is valid.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := yaml.UnmarshalStrict(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if _, err := NewValidator(&schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Violation is one broken rule. Line is the 1-based line of the file and
+// Column the 1-based column, or 0 when the whole row is at fault.
+type Violation struct {
+	Line    int
+	Column  int
+	Name    string
+	Rule    string
+	Value   string
+	Message string
+}
+
+// String describes the violation on one line.
+func (v Violation) String() string {
+	if v.Column == 0 {
+		return fmt.Sprintf("line %d: %s: %s", v.Line, v.Rule, v.Message)
+	}
+	return fmt.Sprintf("line %d, column %d (%s): %s: %s", v.Line, v.Column, v.Name, v.Rule, v.Message)
+}
+
+// ViolationHeader is the header of a violations CSV; Record gives its rows.
+var ViolationHeader = []string{"line", "column", "name", "rule", "value", "message"}
+
+// Record returns the violation as a row of a violations CSV.
+func (v Violation) Record() []string {
+	column := ""
+	if v.Column > 0 {
+		column = strconv.Itoa(v.Column)
+	}
+	return []string{strconv.Itoa(v.Line), column, v.Name, v.Rule, v.Value, v.Message}
+}
+
+// Count is the number of times a rule was broken in a column.
+type Count struct {
+	Column int
+	Name   string
+	Rule   string
+	Count  int
+}
+
+// Validator checks the rows of one file against a schema and counts the
+// violations. To check uniqueness it remembers every value of unique
+// columns.
+type Validator struct {
+	schema  *Schema
+	columns []checker
+	counts  map[countKey]int
+	// Rows is the number of data rows checked and InvalidRows the number
+	// of them with at least one violation.
+	Rows        int
+	InvalidRows int
+}
+
+type countKey struct {
+	column int
+	rule   string
+}
+
+type checker struct {
+	Column
+	pattern  *regexp.Regexp
+	values   map[string]bool
+	min, max *float64
+	seen     map[string]int
+}
+
+// NewValidator returns a validator for schema, or an error if the schema is
+// not valid.
+func NewValidator(schema *Schema) (*Validator, error) {
+	if len(schema.Columns) == 0 {
+		return nil, errors.New("schema has no columns")
+	}
+	v := &Validator{schema: schema, counts: make(map[countKey]int)}
+	for i, c := range schema.Columns {
+		checker, err := newChecker(c)
+		if err != nil {
+			name := c.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i+1)
+			}
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+		v.columns = append(v.columns, checker)
+	}
+	return v, nil
+}
+
+func newChecker(c Column) (checker, error) {
+	if c.Type == "" {
+		c.Type = String
+	}
+	if c.Type == Date && c.Format == "" {
+		c.Format = "2006-01-02"
+	}
+	known := false
+	for _, t := range columnTypes {
+		if c.Type == t {
+			known = true
+		}
+	}
+	if !known {
+		return checker{}, fmt.Errorf("unknown type %q, expected one of %s", c.Type, strings.Join(columnTypes, ", "))
+	}
+
+	ch := checker{Column: c}
+	if c.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + c.Pattern + ")$")
+		if err != nil {
+			return checker{}, fmt.Errorf("pattern: %v", err)
+		}
+		ch.pattern = pattern
+	}
+	if len(c.Values) > 0 {
+		ch.values = make(map[string]bool)
+		for _, value := range c.Values {
+			ch.values[value] = true
+		}
+	}
+	if c.Min != "" || c.Max != "" {
+		if c.Type != Integer && c.Type != Decimal && c.Type != Date {
+			return checker{}, fmt.Errorf("min and max need an integer, decimal or date column, not %s", c.Type)
+		}
+		var err error
+		if ch.min, err = ch.bound("min", c.Min); err != nil {
+			return checker{}, err
+		}
+		if ch.max, err = ch.bound("max", c.Max); err != nil {
+			return checker{}, err
+		}
+		if ch.min != nil && ch.max != nil && *ch.min > *ch.max {
+			return checker{}, fmt.Errorf("min %s is greater than max %s", c.Min, c.Max)
+		}
+	}
+	if c.Unique {
+		ch.seen = make(map[string]int)
+	}
+	return ch, nil
+}
+
+// bound reads a min or max as a number, or as the Unix time of a date.
+func (c checker) bound(name, value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	number, ok := c.number(value)
+	if !ok {
+		if c.Type == Date {
+			return nil, fmt.Errorf("%s %q is not a date like %s", name, value, c.Format)
+		}
+		return nil, fmt.Errorf("%s %q is not a number", name, value)
+	}
+	return &number, nil
+}
+
+// number converts a value of a numeric or date column to a number that can
+// be compared with the column's bounds.
+func (c checker) number(value string) (float64, bool) {
+	if c.Type == Date {
+		date, err := time.Parse(c.Format, value)
+		if err != nil {
+			return 0, false
+		}
+		return float64(date.Unix()), true
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	return number, err == nil
+}
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	booleans     = map[string]bool{"true": true, "false": true, "yes": true, "no": true, "y": true, "n": true, "t": true, "f": true, "1": true, "0": true}
+)
+
+// hasType reports whether value is of the column's type.
+func (c checker) hasType(value string) bool {
+	switch c.Type {
+	case Integer:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case Decimal:
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case Boolean:
+		return booleans[strings.ToLower(value)]
+	case Date:
+		_, err := time.Parse(c.Format, value)
+		return err == nil
+	case Email:
+		return emailPattern.MatchString(value)
+	}
+	return true
+}
+
+// CheckHeader checks that the header names the schema's columns in order.
+// A byte order mark at the start of the header is ignored.
+func (v *Validator) CheckHeader(row []string) []Violation {
+	if len(row) > 0 && strings.HasPrefix(row[0], "\ufeff") {
+		row = append([]string{strings.TrimPrefix(row[0], "\ufeff")}, row[1:]...)
+	}
+	var violations []Violation
+	for i, c := range v.columns {
+		switch {
+		case i >= len(row):
+			violations = append(violations, Violation{Line: 1, Column: i + 1, Name: c.Name, Rule: RuleHeader, Message: fmt.Sprintf("column %q is missing", c.Name)})
+		case row[i] != c.Name:
+			violations = append(violations, Violation{Line: 1, Column: i + 1, Name: c.Name, Rule: RuleHeader, Value: row[i], Message: fmt.Sprintf("expected %q, found %q", c.Name, row[i])})
+		}
+	}
+	for i := len(v.columns); i < len(row); i++ {
+		violations = append(violations, Violation{Line: 1, Column: i + 1, Name: row[i], Rule: RuleHeader, Value: row[i], Message: fmt.Sprintf("unexpected column %q", row[i])})
+	}
+	v.count(violations)
+	return violations
+}
+
+// CheckRow checks the data row on the given line. Empty values only break
+// the required rule; the other rules apply to values that aren't empty.
+func (v *Validator) CheckRow(line int, row []string) []Violation {
+	var violations []Violation
+	if len(row) != len(v.columns) {
+		violations = append(violations, Violation{Line: line, Rule: RuleColumns, Message: fmt.Sprintf("expected %d columns, found %d", len(v.columns), len(row))})
+	}
+	for i := range v.columns {
+		c := &v.columns[i]
+		value := ""
+		if i < len(row) {
+			value = row[i]
+		}
+		add := func(rule, message string) {
+			violations = append(violations, Violation{Line: line, Column: i + 1, Name: c.Name, Rule: rule, Value: value, Message: message})
+		}
+
+		if value == "" {
+			if c.Required {
+				add(RuleRequired, "value is empty")
+			}
+			continue
+		}
+		if !c.hasType(value) {
+			if c.Type == Date {
+				add(RuleType, fmt.Sprintf("%q is not a date like %s", value, c.Format))
+			} else {
+				add(RuleType, fmt.Sprintf("%q is not %s %s", value, article(c.Type), c.Type))
+			}
+		} else if c.min != nil || c.max != nil {
+			number, _ := c.number(value)
+			if c.min != nil && number < *c.min {
+				add(RuleMin, fmt.Sprintf("%s is less than %s", value, c.Min))
+			}
+			if c.max != nil && number > *c.max {
+				add(RuleMax, fmt.Sprintf("%s is greater than %s", value, c.Max))
+			}
+		}
+		if c.pattern != nil && !c.pattern.MatchString(value) {
+			add(RulePattern, fmt.Sprintf("%q does not match %s", value, c.Pattern))
+		}
+		if c.values != nil && !c.values[value] {
+			add(RuleValues, fmt.Sprintf("%q is not one of %s", value, strings.Join(c.Values, ", ")))
+		}
+		if c.seen != nil {
+			if first, seen := c.seen[value]; seen {
+				add(RuleUnique, fmt.Sprintf("%q was already used on line %d", value, first))
+			} else {
+				c.seen[value] = line
+			}
+		}
+	}
+
+	v.Rows++
+	if len(violations) > 0 {
+		v.InvalidRows++
+	}
+	v.count(violations)
+	return violations
+}
+
+func article(word string) string {
+	if strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+func (v *Validator) count(violations []Violation) {
+	for _, violation := range violations {
+		v.counts[countKey{violation.Column, violation.Rule}]++
+	}
+}
+
+// Counts returns how many times each rule was broken in each column, in
+// column order. Row-wide violations come first, with column 0.
+func (v *Validator) Counts() []Count {
+	counts := make([]Count, 0, len(v.counts))
+	for key, count := range v.counts {
+		name := ""
+		if key.column > 0 && key.column <= len(v.columns) {
+			name = v.columns[key.column-1].Name
+		}
+		counts = append(counts, Count{Column: key.column, Name: name, Rule: key.rule, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Column != counts[j].Column {
+			return counts[i].Column < counts[j].Column
+		}
+		return counts[i].Rule < counts[j].Rule
+	})
+	return counts
+}
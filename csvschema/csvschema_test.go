@@ -0,0 +1,258 @@
+package csvschema
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name:   "yaml",
+			source: "columns:\n  - name: id\n    type: integer\n    min: 1\n  - name: when\n    type: date\n    format: 01/02/2006\n    max: 12/31/2021\n",
+		},
+		{
+			name:   "json",
+			source: `{"columns": [{"name": "plan", "values": ["free", "pro"], "required": true}]}`,
+		},
+		{
+			name:    "no columns",
+			source:  "columns: []\n",
+			wantErr: "schema has no columns",
+		},
+		{
+			name:    "unknown field",
+			source:  "columns:\n  - name: a\n    nullable: true\n",
+			wantErr: "field nullable not found",
+		},
+		{
+			name:    "unknown type",
+			source:  "columns:\n  - name: a\n    type: money\n",
+			wantErr: "column a: unknown type \"money\"",
+		},
+		{
+			name:    "bad pattern",
+			source:  "columns:\n  - type: string\n    pattern: '[a-'\n",
+			wantErr: "column #1: pattern: error parsing regexp",
+		},
+		{
+			name:    "min on a string column",
+			source:  "columns:\n  - name: a\n    min: 1\n",
+			wantErr: "column a: min and max need an integer, decimal or date column, not string",
+		},
+		{
+			name:    "bad date bound",
+			source:  "columns:\n  - name: d\n    type: date\n    min: 01/02/2021\n",
+			wantErr: "column d: min \"01/02/2021\" is not a date like 2006-01-02",
+		},
+		{
+			name:    "min above max",
+			source:  "columns:\n  - name: n\n    type: decimal\n    min: 5\n    max: 1.5\n",
+			wantErr: "column n: min 5 is greater than max 1.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSchema(strings.NewReader(tt.source))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("LoadSchema() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadSchema() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_CheckHeader(t *testing.T) {
+	schema := &Schema{Columns: []Column{{Name: "id"}, {Name: "name"}, {Name: "email"}}}
+	tests := []struct {
+		name   string
+		header []string
+		want   []Violation
+	}{
+		{
+			name:   "matches",
+			header: []string{"id", "name", "email"},
+		},
+		{
+			name:   "byte order mark",
+			header: []string{"\ufeffid", "name", "email"},
+		},
+		{
+			name:   "out of order and missing",
+			header: []string{"id", "email"},
+			want: []Violation{
+				{Line: 1, Column: 2, Name: "name", Rule: RuleHeader, Value: "email", Message: "expected \"name\", found \"email\""},
+				{Line: 1, Column: 3, Name: "email", Rule: RuleHeader, Message: "column \"email\" is missing"},
+			},
+		},
+		{
+			name:   "extra column",
+			header: []string{"id", "name", "email", "phone"},
+			want: []Violation{
+				{Line: 1, Column: 4, Name: "phone", Rule: RuleHeader, Value: "phone", Message: "unexpected column \"phone\""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewValidator(schema)
+			if err != nil {
+				t.Fatalf("NewValidator() error = %v", err)
+			}
+			if got := v.CheckHeader(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckHeader() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidator_CheckRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		column Column
+		rows   []string
+		want   []Violation
+	}{
+		{
+			name:   "required",
+			column: Column{Name: "a", Required: true, Type: Integer},
+			rows:   []string{"1", ""},
+			want:   []Violation{{Line: 3, Column: 1, Name: "a", Rule: RuleRequired, Message: "value is empty"}},
+		},
+		{
+			name:   "empty values are only checked when required",
+			column: Column{Name: "a", Type: Integer, Pattern: "9+", Values: []string{"9"}, Unique: true},
+			rows:   []string{"", ""},
+		},
+		{
+			name:   "types",
+			column: Column{Name: "a", Type: Decimal},
+			rows:   []string{"1.5", "-2", "1,5"},
+			want:   []Violation{{Line: 4, Column: 1, Name: "a", Rule: RuleType, Value: "1,5", Message: "\"1,5\" is not a decimal"}},
+		},
+		{
+			name:   "booleans",
+			column: Column{Name: "a", Type: Boolean},
+			rows:   []string{"Yes", "0", "maybe"},
+			want:   []Violation{{Line: 4, Column: 1, Name: "a", Rule: RuleType, Value: "maybe", Message: "\"maybe\" is not a boolean"}},
+		},
+		{
+			name:   "numeric range",
+			column: Column{Name: "n", Type: Integer, Min: "1", Max: "10"},
+			rows:   []string{"0", "10", "11", "x"},
+			want: []Violation{
+				{Line: 2, Column: 1, Name: "n", Rule: RuleMin, Value: "0", Message: "0 is less than 1"},
+				{Line: 4, Column: 1, Name: "n", Rule: RuleMax, Value: "11", Message: "11 is greater than 10"},
+				{Line: 5, Column: 1, Name: "n", Rule: RuleType, Value: "x", Message: "\"x\" is not an integer"},
+			},
+		},
+		{
+			name:   "date range",
+			column: Column{Name: "d", Type: Date, Format: "01/02/2006", Min: "01/01/2021"},
+			rows:   []string{"12/31/2020", "01/01/2021", "2021-01-01"},
+			want: []Violation{
+				{Line: 2, Column: 1, Name: "d", Rule: RuleMin, Value: "12/31/2020", Message: "12/31/2020 is less than 01/01/2021"},
+				{Line: 4, Column: 1, Name: "d", Rule: RuleType, Value: "2021-01-01", Message: "\"2021-01-01\" is not a date like 01/02/2006"},
+			},
+		},
+		{
+			name:   "pattern must match the whole value",
+			column: Column{Name: "zip", Pattern: `\d{5}`},
+			rows:   []string{"12345", "123456"},
+			want:   []Violation{{Line: 3, Column: 1, Name: "zip", Rule: RulePattern, Value: "123456", Message: "\"123456\" does not match \\d{5}"}},
+		},
+		{
+			name:   "values",
+			column: Column{Name: "plan", Values: []string{"free", "pro"}},
+			rows:   []string{"pro", "Pro"},
+			want:   []Violation{{Line: 3, Column: 1, Name: "plan", Rule: RuleValues, Value: "Pro", Message: "\"Pro\" is not one of free, pro"}},
+		},
+		{
+			name:   "unique",
+			column: Column{Name: "id", Unique: true},
+			rows:   []string{"a", "b", "a", "a"},
+			want: []Violation{
+				{Line: 4, Column: 1, Name: "id", Rule: RuleUnique, Value: "a", Message: "\"a\" was already used on line 2"},
+				{Line: 5, Column: 1, Name: "id", Rule: RuleUnique, Value: "a", Message: "\"a\" was already used on line 2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewValidator(&Schema{Columns: []Column{tt.column}})
+			if err != nil {
+				t.Fatalf("NewValidator() error = %v", err)
+			}
+			var got []Violation
+			for i, value := range tt.rows {
+				got = append(got, v.CheckRow(i+2, []string{value})...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckRow() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidator_Counts(t *testing.T) {
+	v, err := NewValidator(&Schema{Columns: []Column{{Name: "id", Required: true}, {Name: "n", Type: Integer}}})
+	if err != nil {
+		t.Fatalf("NewValidator() error = %v", err)
+	}
+	v.CheckRow(2, []string{"1", "2"})
+	v.CheckRow(3, []string{"", "x"})
+	v.CheckRow(4, []string{""})
+
+	want := []Count{
+		{Column: 0, Rule: RuleColumns, Count: 1},
+		{Column: 1, Name: "id", Rule: RuleRequired, Count: 2},
+		{Column: 2, Name: "n", Rule: RuleType, Count: 1},
+	}
+	if got := v.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+	if v.Rows != 3 || v.InvalidRows != 2 {
+		t.Errorf("Rows, InvalidRows = %d, %d, want 3, 2", v.Rows, v.InvalidRows)
+	}
+
+	wantSummary := "3 rows checked, 2 invalid\n\n" +
+		"COLUMN  NAME  RULE      VIOLATIONS\n" +
+		"-             columns   1\n" +
+		"1       id    required  2\n" +
+		"2       n     type      1\n"
+	var b bytes.Buffer
+	if err := WriteSummary(&b, v); err != nil {
+		t.Fatalf("WriteSummary() error = %v", err)
+	}
+	if b.String() != wantSummary {
+		t.Errorf("WriteSummary() =\n%s\nwant\n%s", b.String(), wantSummary)
+	}
+}
+
+func TestViolation(t *testing.T) {
+	v := Violation{Line: 3, Column: 2, Name: "n", Rule: RuleType, Value: "x", Message: "\"x\" is not an integer"}
+	if got, want := v.String(), "line 3, column 2 (n): type: \"x\" is not an integer"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, want := v.Record(), []string{"3", "2", "n", "type", "x", "\"x\" is not an integer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Record() = %q, want %q", got, want)
+	}
+	row := Violation{Line: 4, Rule: RuleColumns, Message: "expected 2 columns, found 1"}
+	if got, want := row.String(), "line 4: columns: expected 2 columns, found 1"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, want := row.Record(), []string{"4", "", "", "columns", "", "expected 2 columns, found 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Record() = %q, want %q", got, want)
+	}
+}
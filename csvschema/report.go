@@ -0,0 +1,36 @@
+package csvschema
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteSummary writes how many rows were checked and a table of the
+// violations counted by v.
+func WriteSummary(w io.Writer, v *Validator) error {
+	rows := "rows"
+	if v.Rows == 1 {
+		rows = "row"
+	}
+	if _, err := fmt.Fprintf(w, "%d %s checked, %d invalid\n", v.Rows, rows, v.InvalidRows); err != nil {
+		return err
+	}
+	counts := v.Counts()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "COLUMN\tNAME\tRULE\tVIOLATIONS")
+	for _, c := range counts {
+		column := "-"
+		if c.Column > 0 {
+			column = strconv.Itoa(c.Column)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", column, c.Name, c.Rule, c.Count)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,339 @@
+// Package csvview displays CSV rows for people to read, either as an
+// aligned table or, for rows too wide for a table, as one record at a time
+// with a line per column.
+package csvview
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options control which rows and columns are shown and how.
+type Options struct {
+	// Header is true when the first row names the columns. The header is
+	// always shown and isn't counted by Skip, Head or Tail.
+	Header bool
+	// Columns selects the columns to show, in order. Each is a 1-based
+	// column number, a range of numbers such as 2-4 or a header name.
+	// Empty shows every column.
+	Columns []string
+	// Skip is the number of rows to skip before showing any.
+	Skip int
+	// Head shows only the first rows after those skipped; Tail shows only
+	// the last rows. Zero shows them all.
+	Head int
+	Tail int
+	// MaxWidth truncates longer values. Zero never truncates.
+	MaxWidth int
+	// RowNumbers adds each row's 1-based position in the file, not
+	// counting the header.
+	RowNumbers bool
+	// Vertical shows each row as a record with a line per column.
+	Vertical bool
+	// SampleRows is the number of rows the table's columns are sized from
+	// when neither Head nor Tail is given. A later value too wide for its
+	// column is shown whole, pushing the rest of its row out of line. Zero
+	// uses DefaultSampleRows.
+	SampleRows int
+}
+
+// DefaultSampleRows is the number of rows a table's columns are sized from
+// when no other number is given.
+const DefaultSampleRows = 1000
+
+// row is a row to show along with its position in the file.
+type row struct {
+	number int
+	values []string
+}
+
+// View reads the rows from r and writes the ones selected by options to w.
+// With Head or Tail only those rows are held in memory. Otherwise the
+// columns are sized from the first SampleRows rows and the rest are written
+// as they are read.
+func View(r *csv.Reader, w io.Writer, options Options) error {
+	if options.Head > 0 && options.Tail > 0 {
+		return errors.New("head and tail can't be used together")
+	}
+	if options.SampleRows <= 0 {
+		options.SampleRows = DefaultSampleRows
+	}
+	r.FieldsPerRecord = -1
+
+	var header []string
+	var rows []row
+	var v *viewer
+	number := 0
+	for {
+		values, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if options.Header && header == nil {
+			header = values
+			continue
+		}
+		number++
+		if number <= options.Skip {
+			continue
+		}
+		current := row{number: number, values: values}
+		if v != nil {
+			if err := v.write(current); err != nil {
+				return err
+			}
+			continue
+		}
+		if options.Head > 0 && len(rows) == options.Head {
+			break
+		}
+		rows = append(rows, current)
+		if options.Tail > 0 && len(rows) > options.Tail {
+			rows = rows[1:]
+		}
+		if options.Head == 0 && options.Tail == 0 && len(rows) == options.SampleRows {
+			if v, err = newViewer(w, header, rows, options); err != nil {
+				return err
+			}
+			rows = nil
+		}
+	}
+
+	if v == nil {
+		var err error
+		if v, err = newViewer(w, header, rows, options); err != nil {
+			return err
+		}
+	}
+	return v.finish()
+}
+
+// viewer writes rows as a table or as records.
+type viewer struct {
+	out     *bufio.Writer
+	options Options
+	columns []int
+	names   []string
+	// widths are the widths of the table's columns, including the row
+	// numbers when they are shown
+	widths []int
+	// nameWidth is the width of the column names in records
+	nameWidth int
+	count     int
+}
+
+// newViewer picks the columns to show, sizes them from sample and writes
+// the sample rows.
+func newViewer(w io.Writer, header []string, sample []row, options Options) (*viewer, error) {
+	width := len(header)
+	for _, r := range sample {
+		if len(r.values) > width {
+			width = len(r.values)
+		}
+	}
+	columns, err := selectColumns(options.Columns, header, width)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &viewer{out: bufio.NewWriter(w), options: options, columns: columns}
+	v.names = make([]string, len(columns))
+	for i, c := range columns {
+		v.names[i] = columnName(header, c)
+	}
+
+	for _, name := range v.names {
+		if n := utf8.RuneCountInString(name); n > v.nameWidth {
+			v.nameWidth = n
+		}
+	}
+	if !options.Vertical {
+		names := v.names
+		if options.RowNumbers {
+			names = append([]string{"#"}, names...)
+		}
+		v.widths = make([]int, len(names))
+		for i, name := range names {
+			v.widths[i] = utf8.RuneCountInString(name)
+		}
+		for _, r := range sample {
+			for i, cell := range v.line(r) {
+				if n := utf8.RuneCountInString(cell); n > v.widths[i] {
+					v.widths[i] = n
+				}
+			}
+		}
+		separators := make([]string, len(v.widths))
+		for i, width := range v.widths {
+			separators[i] = strings.Repeat("-", width+2)
+		}
+		v.writeLine(names)
+		_, _ = v.out.WriteString(strings.Join(separators, "+") + "\n")
+	}
+
+	for _, r := range sample {
+		if err := v.write(r); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// cells returns the values of the selected columns of r as they are shown.
+func (v *viewer) cells(r row) []string {
+	cells := make([]string, len(v.columns))
+	for i, c := range v.columns {
+		if c < len(r.values) {
+			cells[i] = displayValue(r.values[c], v.options.MaxWidth)
+		}
+	}
+	return cells
+}
+
+// line returns a table line for r.
+func (v *viewer) line(r row) []string {
+	if v.options.RowNumbers {
+		return append([]string{strconv.Itoa(r.number)}, v.cells(r)...)
+	}
+	return v.cells(r)
+}
+
+func (v *viewer) write(r row) error {
+	v.count++
+	if v.options.Vertical {
+		return v.writeRecord(r)
+	}
+	return v.writeLine(v.line(r))
+}
+
+func (v *viewer) writeLine(values []string) error {
+	padded := make([]string, len(values))
+	for i, value := range values {
+		padded[i] = pad(value, v.widths[i])
+	}
+	_, err := v.out.WriteString(strings.TrimRight(" "+strings.Join(padded, " | "), " ") + "\n")
+	return err
+}
+
+func (v *viewer) writeRecord(r row) error {
+	title := fmt.Sprintf("-[ RECORD %d ]", v.count)
+	if v.options.RowNumbers {
+		title = fmt.Sprintf("-[ ROW %d ]", r.number)
+	}
+	_, err := v.out.WriteString(title + strings.Repeat("-", 20) + "\n")
+	for i, cell := range v.cells(r) {
+		_, err = v.out.WriteString(strings.TrimRight(pad(v.names[i], v.nameWidth)+" | "+cell, " ") + "\n")
+	}
+	return err
+}
+
+// finish writes the number of rows shown.
+func (v *viewer) finish() error {
+	_, _ = v.out.WriteString(rowCount(v.count))
+	return v.out.Flush()
+}
+
+// selectColumns turns column specs into 0-based column indexes.
+func selectColumns(specs []string, header []string, width int) ([]int, error) {
+	var columns []int
+	if len(specs) == 0 {
+		for c := 0; c < width; c++ {
+			columns = append(columns, c)
+		}
+		return columns, nil
+	}
+	for _, spec := range specs {
+		if n, err := strconv.Atoi(spec); err == nil {
+			if n < 1 {
+				return nil, fmt.Errorf("column numbers start at 1, got %d", n)
+			}
+			columns = append(columns, n-1)
+			continue
+		}
+		if dash := strings.Index(spec, "-"); dash > 0 {
+			from, fromErr := strconv.Atoi(spec[:dash])
+			to, toErr := strconv.Atoi(spec[dash+1:])
+			if fromErr == nil && toErr == nil {
+				if from < 1 || to < from {
+					return nil, fmt.Errorf("invalid column range %q", spec)
+				}
+				for n := from; n <= to; n++ {
+					columns = append(columns, n-1)
+				}
+				continue
+			}
+		}
+		c, ok := findColumn(spec, header)
+		if !ok {
+			return nil, fmt.Errorf("no column named %q", spec)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// findColumn finds a column by its header name, preferring an exact match
+// to one that ignores case.
+func findColumn(name string, header []string) (int, bool) {
+	for i, h := range header {
+		if trimBom(h) == name {
+			return i, true
+		}
+	}
+	for i, h := range header {
+		if strings.EqualFold(trimBom(h), name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func trimBom(s string) string {
+	return strings.TrimPrefix(s, "\ufeff")
+}
+
+func columnName(header []string, c int) string {
+	if c < len(header) {
+		return trimBom(header[c])
+	}
+	return strconv.Itoa(c + 1)
+}
+
+// displayValue puts a value on one line and truncates it to maxWidth
+// characters, marking the truncation with an ellipsis.
+func displayValue(value string, maxWidth int) string {
+	value = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value)
+	if maxWidth <= 0 || utf8.RuneCountInString(value) <= maxWidth {
+		return value
+	}
+	if maxWidth == 1 {
+		return "…"
+	}
+	return string([]rune(value)[:maxWidth-1]) + "…"
+}
+
+// pad pads s with spaces to width characters. Longer values are left as
+// they are.
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+func rowCount(n int) string {
+	if n == 1 {
+		return "(1 row)\n"
+	}
+	return fmt.Sprintf("(%d rows)\n", n)
+}
@@ -0,0 +1,157 @@
+package csvview
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+const people = "id,name,city\n1,Ann,Paris\n2,Bob,\"New\nYork\"\n3,Carla Mendoza,Rome\n"
+
+func TestView(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		options Options
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "table",
+			data:    people,
+			options: Options{Header: true, RowNumbers: true},
+			want: "" +
+				" # | id | name          | city\n" +
+				"---+----+---------------+-----------\n" +
+				" 1 | 1  | Ann           | Paris\n" +
+				" 2 | 2  | Bob           | New\\nYork\n" +
+				" 3 | 3  | Carla Mendoza | Rome\n" +
+				"(3 rows)\n",
+		},
+		{
+			name:    "columns by name, number and range with truncation",
+			data:    people,
+			options: Options{Header: true, Columns: []string{"CITY", "1-2"}, MaxWidth: 5},
+			want: "" +
+				" city  | id | name\n" +
+				"-------+----+-------\n" +
+				" Paris | 1  | Ann\n" +
+				" New\\… | 2  | Bob\n" +
+				" Rome  | 3  | Carl…\n" +
+				"(3 rows)\n",
+		},
+		{
+			name:    "skip and head",
+			data:    people,
+			options: Options{Header: true, Skip: 1, Head: 1, RowNumbers: true, Columns: []string{"name"}},
+			want: "" +
+				" # | name\n" +
+				"---+------\n" +
+				" 2 | Bob\n" +
+				"(1 row)\n",
+		},
+		{
+			name:    "tail",
+			data:    people,
+			options: Options{Header: true, Tail: 2, RowNumbers: true, Columns: []string{"1"}},
+			want: "" +
+				" # | id\n" +
+				"---+----\n" +
+				" 2 | 2\n" +
+				" 3 | 3\n" +
+				"(2 rows)\n",
+		},
+		{
+			name:    "no header and ragged rows",
+			data:    "a\nb,c\n",
+			options: Options{},
+			want: "" +
+				" 1 | 2\n" +
+				"---+---\n" +
+				" a |\n" +
+				" b | c\n" +
+				"(2 rows)\n",
+		},
+		{
+			name:    "vertical",
+			data:    people,
+			options: Options{Header: true, Head: 2, RowNumbers: true, Vertical: true, Columns: []string{"name", "city"}},
+			want: "" +
+				"-[ ROW 1 ]--------------------\n" +
+				"name | Ann\n" +
+				"city | Paris\n" +
+				"-[ ROW 2 ]--------------------\n" +
+				"name | Bob\n" +
+				"city | New\\nYork\n" +
+				"(2 rows)\n",
+		},
+		{
+			name:    "columns are sized from the sample",
+			data:    "id,name\n1,Al\n2,Bo\n10,Carla Mendoza\n",
+			options: Options{Header: true, RowNumbers: true, MaxWidth: 5, SampleRows: 2},
+			want: "" +
+				" # | id | name\n" +
+				"---+----+------\n" +
+				" 1 | 1  | Al\n" +
+				" 2 | 2  | Bo\n" +
+				" 3 | 10 | Carl…\n" +
+				"(3 rows)\n",
+		},
+		{
+			name:    "later values wider than their column are shown whole",
+			data:    "a\nbb\nccc\n",
+			options: Options{SampleRows: 1},
+			want: "" +
+				" 1\n" +
+				"---\n" +
+				" a\n" +
+				" bb\n" +
+				" ccc\n" +
+				"(3 rows)\n",
+		},
+		{
+			name:    "vertical past the sample",
+			data:    "a\nb\nc\n",
+			options: Options{Vertical: true, SampleRows: 1},
+			want: "" +
+				"-[ RECORD 1 ]--------------------\n" +
+				"1 | a\n" +
+				"-[ RECORD 2 ]--------------------\n" +
+				"1 | b\n" +
+				"-[ RECORD 3 ]--------------------\n" +
+				"1 | c\n" +
+				"(3 rows)\n",
+		},
+		{
+			name:    "unknown column",
+			data:    people,
+			options: Options{Header: true, Columns: []string{"zip"}},
+			wantErr: `no column named "zip"`,
+		},
+		{
+			name:    "head and tail",
+			data:    people,
+			options: Options{Head: 1, Tail: 1},
+			wantErr: "head and tail can't be used together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := View(csv.NewReader(strings.NewReader(tt.data)), &b, tt.options)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("View() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("View() error = %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("View() =\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
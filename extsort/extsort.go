@@ -0,0 +1,334 @@
+// Package extsort sorts CSV-style records that may not fit in memory. Records
+// are buffered and, once the buffer is full, sorted and spilled to a
+// temporary file. Sorting merges the spilled runs with whatever is still in
+// memory. When there are too many runs to open at once, they are first
+// merged a group at a time into fewer, longer runs.
+package extsort
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultMaxInMemory is the number of records buffered before a run is
+// spilled to disk when no other limit is given.
+const DefaultMaxInMemory = 100000
+
+// MaxOpenRuns is the most spilled runs that are open at the same time while
+// merging, which keeps a large sort well under the limit on open files.
+const MaxOpenRuns = 64
+
+// LessFunc reports whether record a sorts before record b.
+type LessFunc func(a, b []string) bool
+
+// Sorter collects records and returns them in sorted order. The sort is
+// stable: records that compare equal come back in the order they were added.
+type Sorter struct {
+	less        LessFunc
+	maxInMemory int
+	dir         string
+	buffer      [][]string
+	runs        []string
+	// maxOpenRuns is the most runs merged at once
+	maxOpenRuns int
+	// sorted is set once Sort has handed the runs to an Iterator
+	sorted bool
+}
+
+// New returns a Sorter that keeps at most maxInMemory records in memory
+// before spilling to a temporary file in dir. A maxInMemory of 0 or less uses
+// DefaultMaxInMemory and an empty dir uses the system temporary directory.
+func New(less LessFunc, maxInMemory int, dir string) *Sorter {
+	if maxInMemory <= 0 {
+		maxInMemory = DefaultMaxInMemory
+	}
+	return &Sorter{
+		less:        less,
+		maxInMemory: maxInMemory,
+		dir:         dir,
+		maxOpenRuns: MaxOpenRuns,
+	}
+}
+
+// Add adds a record to be sorted, spilling to disk if the buffer is full.
+func (s *Sorter) Add(record []string) error {
+	s.buffer = append(s.buffer, record)
+	if len(s.buffer) >= s.maxInMemory {
+		return s.spill()
+	}
+	return nil
+}
+
+// Spilled reports how many runs have been written to disk so far.
+func (s *Sorter) Spilled() int {
+	return len(s.runs)
+}
+
+func (s *Sorter) spill() error {
+	sort.SliceStable(s.buffer, func(i, j int) bool { return s.less(s.buffer[i], s.buffer[j]) })
+
+	name, err := s.writeRun(each(s.buffer))
+	if name != "" {
+		s.runs = append(s.runs, name)
+	}
+	if err != nil {
+		return err
+	}
+	s.buffer = nil
+	return nil
+}
+
+// each returns a function that returns the records one at a time and then
+// io.EOF.
+func each(records [][]string) func() ([]string, error) {
+	pos := 0
+	return func() ([]string, error) {
+		if pos >= len(records) {
+			return nil, io.EOF
+		}
+		pos++
+		return records[pos-1], nil
+	}
+}
+
+// writeRun writes the records returned by next, until io.EOF, to a new
+// temporary file and returns its name. The name is returned even on error
+// if the file was created, so that it can be removed.
+func (s *Sorter) writeRun(next func() ([]string, error)) (string, error) {
+	f, err := os.CreateTemp(s.dir, "csv-chef-sort-*.csv")
+	if err != nil {
+		return "", err
+	}
+
+	w := csv.NewWriter(f)
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = w.Write(record)
+		}
+		if err != nil {
+			_ = f.Close()
+			return f.Name(), err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return f.Name(), err
+	}
+	return f.Name(), f.Close()
+}
+
+// compact merges the runs a group at a time until there are no more than
+// maxOpenRuns of them. Each group is made of neighbouring runs and replaced
+// by the merged run in the same place, so the sort stays stable.
+func (s *Sorter) compact() error {
+	for len(s.runs) > s.maxOpenRuns {
+		var merged []string
+		for start := 0; start < len(s.runs); start += s.maxOpenRuns {
+			end := start + s.maxOpenRuns
+			if end > len(s.runs) {
+				end = len(s.runs)
+			}
+			group := s.runs[start:end]
+			if len(group) == 1 {
+				merged = append(merged, group[0])
+				continue
+			}
+			name, err := s.mergeRuns(group)
+			if name != "" {
+				merged = append(merged, name)
+			}
+			if err != nil {
+				s.runs = append(merged, s.runs[end:]...)
+				return err
+			}
+		}
+		s.runs = merged
+	}
+	return nil
+}
+
+// mergeRuns merges runs into a new run, removing them, and returns the name
+// of the new run. As with writeRun, the name is returned even on error.
+func (s *Sorter) mergeRuns(runs []string) (string, error) {
+	it := &Iterator{runs: runs}
+	if err := it.open(runs); err != nil {
+		_ = it.Close()
+		return "", err
+	}
+	it.heap.less = s.less
+	heap.Init(&it.heap)
+
+	name, err := s.writeRun(it.Next)
+	if closeErr := it.Close(); err == nil {
+		err = closeErr
+	}
+	return name, err
+}
+
+// Sort returns an Iterator over every record added so far in sorted order.
+// The Sorter should not be used after Sort is called. The Iterator must be
+// closed to remove any temporary files.
+func (s *Sorter) Sort() (*Iterator, error) {
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	it := &Iterator{runs: s.runs}
+	s.sorted = true
+	sort.SliceStable(s.buffer, func(i, j int) bool { return s.less(s.buffer[i], s.buffer[j]) })
+
+	if err := it.open(s.runs); err != nil {
+		_ = it.Close()
+		return nil, err
+	}
+
+	// The in-memory records were added last, so they lose ties to every run.
+	memory := &source{order: len(s.runs), next: each(s.buffer)}
+	s.buffer = nil
+	if err := it.push(memory); err != nil {
+		_ = it.Close()
+		return nil, err
+	}
+	it.heap.less = s.less
+	heap.Init(&it.heap)
+
+	return it, nil
+}
+
+// Discard drops the records added so far and removes any temporary files,
+// for when the sorted records are no longer wanted. It does nothing after
+// Sort, whose Iterator removes the files instead, so it can be deferred.
+func (s *Sorter) Discard() error {
+	if s.sorted {
+		return nil
+	}
+	var firstErr error
+	for _, name := range s.runs {
+		if err := os.Remove(name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.runs = nil
+	s.buffer = nil
+	return firstErr
+}
+
+// Iterator walks sorted records.
+type Iterator struct {
+	heap  sourceHeap
+	runs  []string
+	files []*os.File
+}
+
+// Next returns the next record, or io.EOF when all records have been read.
+func (it *Iterator) Next() ([]string, error) {
+	if len(it.heap.sources) == 0 {
+		return nil, io.EOF
+	}
+	top := it.heap.sources[0]
+	record := top.current
+
+	next, err := top.next()
+	if err == io.EOF {
+		heap.Pop(&it.heap)
+	} else if err != nil {
+		return nil, err
+	} else {
+		top.current = next
+		heap.Fix(&it.heap, 0)
+	}
+
+	return record, nil
+}
+
+// Close releases and removes any temporary files.
+func (it *Iterator) Close() error {
+	var firstErr error
+	for _, f := range it.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, name := range it.runs {
+		if err := os.Remove(name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	it.files = nil
+	it.runs = nil
+	return firstErr
+}
+
+// open adds the runs to the merge. Runs are numbered in the order they were
+// written so ties are broken in favor of earlier records.
+func (it *Iterator) open(runs []string) error {
+	for i, name := range runs {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		it.files = append(it.files, f)
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		if err := it.push(&source{order: i, next: r.Read}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (it *Iterator) push(src *source) error {
+	record, err := src.next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	src.current = record
+	it.heap.sources = append(it.heap.sources, src)
+	return nil
+}
+
+type source struct {
+	order   int
+	current []string
+	next    func() ([]string, error)
+}
+
+type sourceHeap struct {
+	sources []*source
+	less    LessFunc
+}
+
+func (h sourceHeap) Len() int { return len(h.sources) }
+
+func (h sourceHeap) Less(i, j int) bool {
+	a, b := h.sources[i], h.sources[j]
+	if h.less(a.current, b.current) {
+		return true
+	}
+	if h.less(b.current, a.current) {
+		return false
+	}
+	return a.order < b.order
+}
+
+func (h sourceHeap) Swap(i, j int) { h.sources[i], h.sources[j] = h.sources[j], h.sources[i] }
+
+func (h *sourceHeap) Push(x interface{}) { h.sources = append(h.sources, x.(*source)) }
+
+func (h *sourceHeap) Pop() interface{} {
+	old := h.sources
+	n := len(old)
+	item := old[n-1]
+	h.sources = old[:n-1]
+	return item
+}
@@ -0,0 +1,151 @@
+package extsort
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func byFirstField(a, b []string) bool {
+	return a[0] < b[0]
+}
+
+func collect(t *testing.T, it *Iterator) [][]string {
+	t.Helper()
+	var got [][]string
+	for {
+		record, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() unexpected error: %v", err)
+		}
+		got = append(got, record)
+	}
+	return got
+}
+
+func TestSorter_Sort(t *testing.T) {
+	input := [][]string{
+		{"d", "1"},
+		{"b", "2"},
+		{"a", "3"},
+		{"b", "4"},
+		{"c", "5", "extra"},
+		{"a", "6"},
+		{"b", "7"},
+	}
+	want := [][]string{
+		{"a", "3"},
+		{"a", "6"},
+		{"b", "2"},
+		{"b", "4"},
+		{"b", "7"},
+		{"c", "5", "extra"},
+		{"d", "1"},
+	}
+
+	tests := []struct {
+		name        string
+		maxInMemory int
+		maxOpenRuns int
+		wantSpilled int
+	}{
+		{name: "all in memory", maxInMemory: 100, wantSpilled: 0},
+		{name: "spills several runs", maxInMemory: 2, wantSpilled: 3},
+		{name: "spills every record", maxInMemory: 1, wantSpilled: 7},
+		{name: "merges runs two at a time", maxInMemory: 1, maxOpenRuns: 2, wantSpilled: 7},
+		{name: "merges runs three at a time", maxInMemory: 1, maxOpenRuns: 3, wantSpilled: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := New(byFirstField, tt.maxInMemory, dir)
+			if tt.maxOpenRuns > 0 {
+				s.maxOpenRuns = tt.maxOpenRuns
+			}
+			for _, record := range input {
+				if err := s.Add(record); err != nil {
+					t.Fatalf("Add() unexpected error: %v", err)
+				}
+			}
+			if s.Spilled() != tt.wantSpilled {
+				t.Errorf("Spilled() = %d, want %d", s.Spilled(), tt.wantSpilled)
+			}
+			it, err := s.Sort()
+			if err != nil {
+				t.Fatalf("Sort() unexpected error: %v", err)
+			}
+			if len(it.files) > s.maxOpenRuns {
+				t.Errorf("Sort() opened %d runs at once, want at most %d", len(it.files), s.maxOpenRuns)
+			}
+			got := collect(t, it)
+			if err := it.Close(); err != nil {
+				t.Fatalf("Close() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Sort() = %v, want %v", got, want)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 0 {
+				t.Errorf("expected temporary files to be removed, found %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestSorter_SortEmpty(t *testing.T) {
+	it, err := New(byFirstField, 0, "").Sort()
+	if err != nil {
+		t.Fatalf("Sort() unexpected error: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+	if _, err := it.Next(); err != io.EOF {
+		t.Errorf("Next() error = %v, want io.EOF", err)
+	}
+}
+
+func TestSorter_Discard(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted bool
+	}{
+		{name: "before sort"},
+		{name: "after sort", sorted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := New(byFirstField, 1, dir)
+			for _, record := range [][]string{{"b"}, {"a"}, {"c"}} {
+				if err := s.Add(record); err != nil {
+					t.Fatalf("Add() unexpected error: %v", err)
+				}
+			}
+			var it *Iterator
+			if tt.sorted {
+				var err error
+				if it, err = s.Sort(); err != nil {
+					t.Fatalf("Sort() unexpected error: %v", err)
+				}
+			}
+			if err := s.Discard(); err != nil {
+				t.Fatalf("Discard() unexpected error: %v", err)
+			}
+			if it != nil {
+				if got := collect(t, it); !reflect.DeepEqual(got, [][]string{{"a"}, {"b"}, {"c"}}) {
+					t.Errorf("Sort() after Discard() = %v", got)
+				}
+				if err := it.Close(); err != nil {
+					t.Fatalf("Close() unexpected error: %v", err)
+				}
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 0 {
+				t.Errorf("expected temporary files to be removed, found %d", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package fakedata
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    *Schema
+		wantErr string
+	}{
+		{
+			name:   "yaml",
+			source: "columns:\n  - name: plan\n    type: enum\n    values: [free, {value: pro, weight: 3}]\n  - name: id\n    type: sequence\n    start: 10\n    nulls: 5\n",
+			want: &Schema{Columns: []Column{
+				{Name: "plan", Type: Enum, Values: []Choice{{Value: "free", Weight: 1}, {Value: "pro", Weight: 3}}},
+				{Name: "id", Type: Sequence, Start: intPointer(10), Null: 5},
+			}},
+		},
+		{
+			name:   "json",
+			source: `{"columns": [{"name": "n", "type": "integer", "min": 1, "max": 9}]}`,
+			want:   &Schema{Columns: []Column{{Name: "n", Type: Integer, Min: 1, Max: 9}}},
+		},
+		{
+			name:    "no columns",
+			source:  "columns: []\n",
+			wantErr: "schema has no columns",
+		},
+		{
+			name:    "unknown field",
+			source:  "columns:\n  - name: a\n    type: name\n    colour: red\n",
+			wantErr: "field colour not found",
+		},
+		{
+			name:    "unknown type",
+			source:  "columns:\n  - name: a\n    type: colour\n",
+			wantErr: "column a: unknown type \"colour\"",
+		},
+		{
+			name:    "bad date",
+			source:  "columns:\n  - type: date\n    from: 01/02/2020\n",
+			wantErr: "column #1: from: expected a date like 2006-01-02",
+		},
+		{
+			name:    "dates out of order",
+			source:  "columns:\n  - name: d\n    type: date\n    from: 2021-01-01\n    to: 2020-01-01\n",
+			wantErr: "column d: from 2021-01-01 is after to 2020-01-01",
+		},
+		{
+			name:    "enum without values",
+			source:  "columns:\n  - name: e\n    type: enum\n",
+			wantErr: "column e: enum needs values",
+		},
+		{
+			name:    "enum weights are zero",
+			source:  "columns:\n  - name: e\n    type: enum\n    values: [{value: a, weight: 0}]\n",
+			wantErr: "column e: enum weights add up to zero",
+		},
+		{
+			name:    "nulls out of range",
+			source:  "columns:\n  - name: n\n    type: uuid\n    nulls: 150\n",
+			wantErr: "column n: nulls must be a percentage from 0 to 100, got 150",
+		},
+		{
+			name:    "min above max",
+			source:  "columns:\n  - name: n\n    type: decimal\n    min: 5\n    max: 1\n",
+			wantErr: "column n: min 5 is greater than max 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadSchema(strings.NewReader(tt.source))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadSchema() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSchema() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadSchema() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_Row(t *testing.T) {
+	now := time.Date(2021, time.March, 15, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		column Column
+		check  func(values []string) string
+	}{
+		{
+			name:   "sequence",
+			column: Column{Type: Sequence, Start: intPointer(5), Step: intPointer(-2)},
+			check: func(values []string) string {
+				if values[0] != "5" || values[1] != "3" || values[2] != "1" {
+					return "want 5, 3, 1, ..."
+				}
+				return ""
+			},
+		},
+		{
+			name:   "integer range",
+			column: Column{Type: Integer, Min: 3, Max: 4},
+			check:  allMatch(`^[34]$`),
+		},
+		{
+			name:   "decimal places",
+			column: Column{Type: Decimal, Min: 0, Max: 1, Decimals: 2},
+			check:  allMatch(`^(0\.\d\d|1\.00)$`),
+		},
+		{
+			name:   "weighted enum",
+			column: Column{Type: Enum, Values: []Choice{{Value: "never", Weight: 0}, {Value: "always", Weight: 2}}},
+			check:  allMatch(`^always$`),
+		},
+		{
+			name:   "uuid",
+			column: Column{Type: UUID},
+			check:  allMatch(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		},
+		{
+			name:   "relative dates",
+			column: Column{Type: Date, From: "-1d", To: "today", Format: "01/02/2006"},
+			check:  allMatch(`^03/1[45]/2021$`),
+		},
+		{
+			name:   "all nulls",
+			column: Column{Type: Name, Null: 100},
+			check:  allMatch(`^$`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewGenerator(&Schema{Columns: []Column{tt.column}}, 1, now)
+			if err != nil {
+				t.Fatalf("NewGenerator() error = %v", err)
+			}
+			var values []string
+			for i := 0; i < 50; i++ {
+				values = append(values, generator.Row()[0])
+			}
+			if problem := tt.check(values); problem != "" {
+				t.Errorf("Row() values %v: %s", values, problem)
+			}
+		})
+	}
+}
+
+func TestGenerator_Seed(t *testing.T) {
+	schema := &Schema{Columns: []Column{
+		{Name: "name", Type: Name},
+		{Name: "email", Type: Email},
+		{Name: "amount", Type: Decimal, Max: 100, Decimals: 2, Null: 50},
+		{Name: "when", Type: Date, From: "-1y"},
+		{Name: "id", Type: UUID},
+	}}
+	now := time.Date(2021, time.March, 15, 0, 0, 0, 0, time.UTC)
+	write := func(seed int64, now time.Time) []byte {
+		generator, err := NewGenerator(schema, seed, now)
+		if err != nil {
+			t.Fatalf("NewGenerator() error = %v", err)
+		}
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		_ = w.Write(generator.Header())
+		for i := 0; i < 20; i++ {
+			_ = w.Write(generator.Row())
+		}
+		w.Flush()
+		return b.Bytes()
+	}
+
+	first := write(42, now)
+	if !strings.HasPrefix(string(first), "name,email,amount,when,id\n") {
+		t.Errorf("Header() = %q", strings.SplitN(string(first), "\n", 2)[0])
+	}
+	if !bytes.Equal(first, write(42, now)) {
+		t.Errorf("output differs between runs with the same seed and time")
+	}
+	if bytes.Equal(first, write(43, now)) {
+		t.Errorf("output is the same for different seeds")
+	}
+	if bytes.Equal(first, write(42, now.AddDate(0, 0, 1))) {
+		t.Errorf("relative dates don't depend on now")
+	}
+}
+
+func allMatch(pattern string) func([]string) string {
+	re := regexp.MustCompile(pattern)
+	return func(values []string) string {
+		for _, v := range values {
+			if !re.MatchString(v) {
+				return "want every value to match " + pattern
+			}
+		}
+		return ""
+	}
+}
+
+func intPointer(i int) *int {
+	return &i
+}
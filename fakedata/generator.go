@@ -0,0 +1,142 @@
+package fakedata
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"syreclabs.com/go/faker"
+)
+
+// Generator produces rows for a schema.
+type Generator struct {
+	schema *Schema
+	rand   *rand.Rand
+	now    time.Time
+	row    int
+}
+
+// NewGenerator returns a generator for schema. Rows depend only on the
+// seed and on now, which relative date bounds are measured from. The names,
+// addresses and other values made by faker come from its shared random
+// source, which is reseeded here, so only one generator should be used at a
+// time.
+func NewGenerator(schema *Schema, seed int64, now time.Time) (*Generator, error) {
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	faker.Seed(seed)
+	return &Generator{schema: schema, rand: rand.New(rand.NewSource(seed)), now: now}, nil
+}
+
+// Header returns the column names.
+func (g *Generator) Header() []string {
+	header := make([]string, len(g.schema.Columns))
+	for i, c := range g.schema.Columns {
+		header[i] = c.Name
+	}
+	return header
+}
+
+// Row returns the next row of values.
+func (g *Generator) Row() []string {
+	g.row++
+	row := make([]string, len(g.schema.Columns))
+	for i, c := range g.schema.Columns {
+		value := g.value(c)
+		// the chance of a null is drawn for every value, even when null is
+		// zero, so that adding nulls to one column doesn't change the
+		// values of the others
+		if g.rand.Float64()*100 < c.Null {
+			value = ""
+		}
+		row[i] = value
+	}
+	return row
+}
+
+func (g *Generator) value(c Column) string {
+	switch c.Type {
+	case Name:
+		return faker.Name().Name()
+	case FirstName:
+		return faker.Name().FirstName()
+	case LastName:
+		return faker.Name().LastName()
+	case Address:
+		return faker.Address().StreetAddress()
+	case City:
+		return faker.Address().City()
+	case State:
+		return faker.Address().State()
+	case Zipcode:
+		return faker.Address().ZipCode()
+	case Email:
+		return faker.Internet().Email()
+	case Phone:
+		return faker.PhoneNumber().PhoneNumber()
+	case Date:
+		return g.date(c)
+	case Integer:
+		low, high := int64(c.Min), int64(c.Max)
+		return strconv.FormatInt(low+g.rand.Int63n(high-low+1), 10)
+	case Decimal:
+		value := c.Min + g.rand.Float64()*(c.Max-c.Min)
+		return strconv.FormatFloat(value, 'f', c.Decimals, 64)
+	case Enum:
+		return g.choose(c.Values)
+	case UUID:
+		return g.uuid()
+	case Sequence:
+		start, step := 1, 1
+		if c.Start != nil {
+			start = *c.Start
+		}
+		if c.Step != nil {
+			step = *c.Step
+		}
+		return strconv.Itoa(start + (g.row-1)*step)
+	}
+	return ""
+}
+
+func (g *Generator) date(c Column) string {
+	// the bounds were checked by Validate
+	from, _ := parseDate(c.From, g.now)
+	to, _ := parseDate(c.To, g.now)
+	if to.Before(from) {
+		from, to = to, from
+	}
+	days := int(to.Sub(from).Hours() / 24)
+	date := from.AddDate(0, 0, g.rand.Intn(days+1))
+	format := c.Format
+	if format == "" {
+		format = "2006-01-02"
+	}
+	return date.Format(format)
+}
+
+func (g *Generator) choose(choices []Choice) string {
+	total := 0.0
+	for _, c := range choices {
+		total += c.Weight
+	}
+	pick := g.rand.Float64() * total
+	for _, c := range choices {
+		if pick < c.Weight {
+			return c.Value
+		}
+		pick -= c.Weight
+	}
+	return choices[len(choices)-1].Value
+}
+
+// uuid returns a random version 4 UUID.
+func (g *Generator) uuid() string {
+	b := make([]byte, 16)
+	_, _ = g.rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
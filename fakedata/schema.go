@@ -0,0 +1,209 @@
+// Package fakedata generates CSV rows of realistic looking fake data from a
+// schema that lists the columns and how to generate each one. Given the same
+// schema, seed and current time it generates the same rows, so it can be
+// used to build test fixtures of any size.
+package fakedata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Generator types.
+const (
+	Name      = "name"
+	FirstName = "first_name"
+	LastName  = "last_name"
+	Address   = "address"
+	City      = "city"
+	State     = "state"
+	Zipcode   = "zipcode"
+	Email     = "email"
+	Phone     = "phone"
+	Date      = "date"
+	Integer   = "integer"
+	Decimal   = "decimal"
+	Enum      = "enum"
+	UUID      = "uuid"
+	Sequence  = "sequence"
+)
+
+var generatorTypes = []string{Name, FirstName, LastName, Address, City, State, Zipcode, Email, Phone, Date, Integer, Decimal, Enum, UUID, Sequence}
+
+// Schema describes the columns of the file to generate.
+type Schema struct {
+	Columns []Column `yaml:"columns"`
+}
+
+// Column describes one column: its header Name, the Type of generator that
+// fills it and the options for that generator. Null is the percentage of
+// values left empty.
+type Column struct {
+	Name string  `yaml:"name"`
+	Type string  `yaml:"type"`
+	Null float64 `yaml:"nulls"`
+
+	// Min and Max bound integer and decimal values; Decimals is the number
+	// of decimal places for decimal values.
+	Min      float64 `yaml:"min"`
+	Max      float64 `yaml:"max"`
+	Decimals int     `yaml:"decimals"`
+
+	// From and To bound date values. Each is a date in 2006-01-02 form,
+	// "today", or an offset from today such as -18y, +6m or -10d. Format
+	// is the layout dates are written in, 2006-01-02 by default.
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Format string `yaml:"format"`
+
+	// Values are the choices for an enum.
+	Values []Choice `yaml:"values"`
+
+	// Start and Step control a sequence. Start defaults to 1 and Step to 1.
+	Start *int `yaml:"start"`
+	Step  *int `yaml:"step"`
+}
+
+// Choice is one value of an enum. Values with a higher weight are chosen
+// more often; the weight defaults to 1. In a schema a choice can be given
+// as just the value or as a value and weight.
+type Choice struct {
+	Value  string  `yaml:"value"`
+	Weight float64 `yaml:"weight"`
+}
+
+// UnmarshalYAML accepts either a plain value or a map with value and weight.
+func (c *Choice) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*c = Choice{Value: value, Weight: 1}
+		return nil
+	}
+	type choice Choice
+	parsed := choice{Weight: 1}
+	if err := unmarshal(&parsed); err != nil {
+		return err
+	}
+	*c = Choice(parsed)
+	return nil
+}
+
+// LoadSchema reads a YAML or JSON schema and checks that it is valid.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := yaml.UnmarshalStrict(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Validate checks that every column has a known type and sensible options.
+func (s *Schema) Validate() error {
+	if len(s.Columns) == 0 {
+		return errors.New("schema has no columns")
+	}
+	for i, c := range s.Columns {
+		if err := c.validate(); err != nil {
+			name := c.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i+1)
+			}
+			return fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c Column) validate() error {
+	known := false
+	for _, t := range generatorTypes {
+		if c.Type == t {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown type %q, expected one of %s", c.Type, strings.Join(generatorTypes, ", "))
+	}
+	if c.Null < 0 || c.Null > 100 {
+		return fmt.Errorf("nulls must be a percentage from 0 to 100, got %v", c.Null)
+	}
+
+	switch c.Type {
+	case Integer, Decimal:
+		if c.Min > c.Max {
+			return fmt.Errorf("min %v is greater than max %v", c.Min, c.Max)
+		}
+	case Date:
+		now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+		from, err := parseDate(c.From, now)
+		if err != nil {
+			return fmt.Errorf("from: %v", err)
+		}
+		to, err := parseDate(c.To, now)
+		if err != nil {
+			return fmt.Errorf("to: %v", err)
+		}
+		if !isRelative(c.From) && !isRelative(c.To) && from.After(to) {
+			return fmt.Errorf("from %s is after to %s", c.From, c.To)
+		}
+	case Enum:
+		if len(c.Values) == 0 {
+			return errors.New("enum needs values")
+		}
+		total := 0.0
+		for _, v := range c.Values {
+			if v.Weight < 0 {
+				return fmt.Errorf("weight of %q is negative", v.Value)
+			}
+			total += v.Weight
+		}
+		if total == 0 {
+			return errors.New("enum weights add up to zero")
+		}
+	}
+	return nil
+}
+
+var offsetPattern = regexp.MustCompile(`^([+-]\d+)([dmy])$`)
+
+func isRelative(value string) bool {
+	return value == "" || value == "today" || offsetPattern.MatchString(value)
+}
+
+// parseDate reads a date bound relative to now. An empty bound is today.
+func parseDate(value string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if value == "" || value == "today" {
+		return today, nil
+	}
+	if m := offsetPattern.FindStringSubmatch(value); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return today.AddDate(0, 0, n), nil
+		case "m":
+			return today.AddDate(0, n, 0), nil
+		}
+		return today.AddDate(n, 0, 0), nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date like 2006-01-02, today or an offset like -10d, got %q", value)
+	}
+	return date, nil
+}
@@ -6,7 +6,9 @@ require (
 	github.com/carmo-evan/strtotime v0.0.0-20200108203155-3136cf889e3b
 	github.com/google/martian v2.1.0+incompatible
 	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.8.1
 	golang.org/x/text v0.3.8 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 	syreclabs.com/go/faker v1.2.3
 )
@@ -0,0 +1,176 @@
+// Package headermap matches the columns of one CSV header to the columns of
+// another by name, so a recipe can be written that converts a file from one
+// layout to the other. Names match exactly, ignoring case and punctuation, as
+// synonyms, or as near misspellings, in that order of preference.
+package headermap
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind says how a target column was matched to an input column.
+type Kind string
+
+const (
+	Exact      Kind = "exact"
+	Normalized Kind = "normalized"
+	Synonym    Kind = "synonym"
+	Fuzzy      Kind = "fuzzy"
+)
+
+// Match pairs a target column with the input column that fills it. Columns
+// are 1-based; Input is 0 when no input column matched.
+type Match struct {
+	Target     int
+	TargetName string
+	Input      int
+	InputName  string
+	Kind       Kind
+}
+
+// Synonyms are groups of normalized column names that mean the same thing.
+// Only true aliases share a group: names that are merely related, such as
+// price and total, would fill a column with the wrong data.
+var Synonyms = [][]string{
+	{"id", "identifier"},
+	{"firstname", "first", "fname", "givenname", "forename"},
+	{"lastname", "last", "lname", "surname", "familyname"},
+	{"name", "fullname"},
+	{"email", "emailaddress", "mail", "eaddress"},
+	{"phone", "phonenumber", "telephone", "tel"},
+	{"mobile", "mobilephone", "cell", "cellphone"},
+	{"address", "address1", "addressline1", "street", "streetaddress", "addr"},
+	{"city", "town"},
+	{"state", "province"},
+	{"zip", "zipcode", "postalcode", "postcode", "postal"},
+	{"country", "nation"},
+	{"birthdate", "dob", "dateofbirth", "birthday"},
+	{"company", "organization", "organisation", "employer"},
+	{"amount", "amt"},
+	{"price", "unitprice"},
+}
+
+// Normalize lower-cases a column name and removes everything that isn't a
+// letter or digit, along with any byte order mark.
+func Normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimPrefix(name, "\ufeff") {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// synonymGroup returns the index of the synonym group a normalized name
+// belongs to, or -1.
+func synonymGroup(name string) int {
+	for i, group := range Synonyms {
+		for _, s := range group {
+			if s == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Columns matches each target column to at most one input column, and each
+// input column to at most one target. Stronger kinds of match are made
+// first, so an exact match is never taken by an earlier fuzzy one.
+func Columns(input []string, target []string) []Match {
+	matches := make([]Match, len(target))
+	for i, name := range target {
+		matches[i] = Match{Target: i + 1, TargetName: name}
+	}
+	used := make([]bool, len(input))
+
+	pass := func(kind Kind, score func(in, out string) int) {
+		for i := range matches {
+			if matches[i].Input != 0 {
+				continue
+			}
+			best, bestScore := -1, 0
+			for j, name := range input {
+				if used[j] {
+					continue
+				}
+				s := score(name, matches[i].TargetName)
+				if s > 0 && (best == -1 || s > bestScore) {
+					best, bestScore = j, s
+				}
+			}
+			if best >= 0 {
+				used[best] = true
+				matches[i].Input = best + 1
+				matches[i].InputName = input[best]
+				matches[i].Kind = kind
+			}
+		}
+	}
+
+	pass(Exact, func(in, out string) int {
+		if strings.TrimPrefix(in, "\ufeff") == out {
+			return 1
+		}
+		return 0
+	})
+	pass(Normalized, func(in, out string) int {
+		if n := Normalize(in); n != "" && n == Normalize(out) {
+			return 1
+		}
+		return 0
+	})
+	pass(Synonym, func(in, out string) int {
+		if g := synonymGroup(Normalize(in)); g >= 0 && g == synonymGroup(Normalize(out)) {
+			return 1
+		}
+		return 0
+	})
+	pass(Fuzzy, func(in, out string) int {
+		a, b := Normalize(in), Normalize(out)
+		// allow about one typo for every four letters
+		limit := len([]rune(b)) / 4
+		distance := editDistance(a, b)
+		if limit == 0 || distance > limit {
+			return 0
+		}
+		return limit - distance + 1
+	})
+	return matches
+}
+
+// editDistance returns the number of single character edits needed to turn
+// a into b, counting the swap of two neighbouring characters as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package headermap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []string
+		target []string
+		want   []Match
+	}{
+		{
+			name:   "every kind of match",
+			input:  []string{"\ufeffFirst Name", "SURNAME", "Zip Code", "emial", "id"},
+			target: []string{"id", "first_name", "last_name", "postal_code", "email", "phone"},
+			want: []Match{
+				{Target: 1, TargetName: "id", Input: 5, InputName: "id", Kind: Exact},
+				{Target: 2, TargetName: "first_name", Input: 1, InputName: "\ufeffFirst Name", Kind: Normalized},
+				{Target: 3, TargetName: "last_name", Input: 2, InputName: "SURNAME", Kind: Synonym},
+				{Target: 4, TargetName: "postal_code", Input: 3, InputName: "Zip Code", Kind: Synonym},
+				{Target: 5, TargetName: "email", Input: 4, InputName: "emial", Kind: Fuzzy},
+				{Target: 6, TargetName: "phone"},
+			},
+		},
+		{
+			name:   "stronger matches are made first",
+			input:  []string{"e-mail", "email"},
+			target: []string{"E-Mail", "email"},
+			want: []Match{
+				{Target: 1, TargetName: "E-Mail", Input: 1, InputName: "e-mail", Kind: Normalized},
+				{Target: 2, TargetName: "email", Input: 2, InputName: "email", Kind: Exact},
+			},
+		},
+		{
+			name:   "an input column fills only one target",
+			input:  []string{"name"},
+			target: []string{"name", "name"},
+			want: []Match{
+				{Target: 1, TargetName: "name", Input: 1, InputName: "name", Kind: Exact},
+				{Target: 2, TargetName: "name"},
+			},
+		},
+		{
+			name:   "related names are not synonyms",
+			input:  []string{"price", "amount", "region", "mobile"},
+			target: []string{"total", "state", "phone", "unit_price"},
+			want: []Match{
+				{Target: 1, TargetName: "total"},
+				{Target: 2, TargetName: "state"},
+				{Target: 3, TargetName: "phone"},
+				{Target: 4, TargetName: "unit_price", Input: 1, InputName: "price", Kind: Synonym},
+			},
+		},
+		{
+			name:   "short names must match closely",
+			input:  []string{"ab", "city"},
+			target: []string{"id", "cty"},
+			want: []Match{
+				{Target: 1, TargetName: "id"},
+				{Target: 2, TargetName: "cty"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Columns(tt.input, tt.target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Columns() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("\ufeffZip-Code (5)"); got != "zipcode5" {
+		t.Errorf("Normalize() = %q, want %q", got, "zipcode5")
+	}
+}
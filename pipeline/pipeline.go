@@ -0,0 +1,270 @@
+// Package pipeline runs a list of bake steps described in a YAML or JSON
+// file. A step reads its input from a file or streams it from the output of
+// an earlier step, without a temporary file. Steps that don't depend on each
+// other run in parallel, and each step's outcome is reported separately.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Pipeline is a list of bake steps.
+type Pipeline struct {
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is one bake. It reads Input, or the output of the step named in
+// From, and writes Output, which may be left out when other steps read the
+// output with From. After lists steps that must finish before this one
+// starts; a step that reads a file another step writes waits for it without
+// being told. The remaining fields are the bake options of the same names.
+type Step struct {
+	Name   string   `yaml:"name"`
+	Input  string   `yaml:"input"`
+	From   string   `yaml:"from"`
+	Output string   `yaml:"output"`
+	Recipe string   `yaml:"recipe"`
+	After  []string `yaml:"after"`
+
+	Force             bool              `yaml:"force"`
+	Lines             int               `yaml:"lines"`
+	NoHeader          bool              `yaml:"no_header"`
+	ParseErrorIsError bool              `yaml:"parse_error_is_error"`
+	Sanitize          bool              `yaml:"sanitize"`
+	Delimiter         string            `yaml:"delimiter"`
+	InputDelimiter    string            `yaml:"input_delimiter"`
+	OutputDelimiter   string            `yaml:"output_delimiter"`
+	Set               map[string]string `yaml:"set"`
+	MaxGroups         int               `yaml:"max_groups"`
+	DedupeKey         string            `yaml:"dedupe_key"`
+	DedupeKeep        string            `yaml:"dedupe_keep"`
+	SortBy            []string          `yaml:"sort_by"`
+	Schema            string            `yaml:"schema"`
+	Violations        string            `yaml:"violations"`
+	FailFast          bool              `yaml:"fail_fast"`
+}
+
+// Load reads a YAML or JSON pipeline and checks that it can be run.
+func Load(r io.Reader) (*Pipeline, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var p Pipeline
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid pipeline: %v", err)
+	}
+	if _, err := p.plan(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ResolvePaths makes the relative file paths of every step relative to dir,
+// usually the directory of the pipeline file.
+func (p *Pipeline) ResolvePaths(dir string) {
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		for _, path := range []*string{&s.Input, &s.Output, &s.Recipe, &s.Schema, &s.Violations} {
+			resolve(path)
+		}
+	}
+}
+
+// plan groups the steps that stream into each other, which have to run at
+// the same time, and works out which groups each group waits for.
+type plan struct {
+	// groups lists the steps of each group, by index.
+	groups [][]int
+	// groupOf is the group of each step.
+	groupOf []int
+	// waitsFor lists the groups each group waits for, by index.
+	waitsFor [][]int
+	// waitsOn lists the steps each group waits for, by index: those named
+	// in after and those writing a file the group reads.
+	waitsOn [][]int
+}
+
+func (p *Pipeline) plan() (*plan, error) {
+	if len(p.Steps) == 0 {
+		return nil, errors.New("pipeline has no steps")
+	}
+
+	index := make(map[string]int)
+	for i, s := range p.Steps {
+		if s.Name == "" {
+			return nil, fmt.Errorf("step %d has no name", i+1)
+		}
+		if _, ok := index[s.Name]; ok {
+			return nil, fmt.Errorf("step name %s is used twice", s.Name)
+		}
+		index[s.Name] = i
+	}
+
+	writers := make(map[string]int)
+	read := make(map[string]bool)
+	for i, s := range p.Steps {
+		if err := s.check(index); err != nil {
+			return nil, fmt.Errorf("step %s: %v", s.Name, err)
+		}
+		if s.Output != "" {
+			output := filepath.Clean(s.Output)
+			if other, ok := writers[output]; ok {
+				return nil, fmt.Errorf("steps %s and %s both write %s", p.Steps[other].Name, s.Name, s.Output)
+			}
+			writers[output] = i
+		}
+		read[s.From] = true
+	}
+	for _, s := range p.Steps {
+		if s.Output == "" && !read[s.Name] {
+			return nil, fmt.Errorf("step %s: no output, and no step reads from it", s.Name)
+		}
+	}
+
+	// steps joined by from make up a group
+	pl := &plan{groupOf: make([]int, len(p.Steps))}
+	for i := range p.Steps {
+		root := i
+		for n := 0; p.Steps[root].From != ""; n++ {
+			if n == len(p.Steps) {
+				return nil, fmt.Errorf("step %s: steps read from each other in a loop", p.Steps[i].Name)
+			}
+			root = index[p.Steps[root].From]
+		}
+		pl.groupOf[i] = -1 - root
+	}
+	roots := make(map[int]int)
+	for i, g := range pl.groupOf {
+		group, ok := roots[g]
+		if !ok {
+			group = len(pl.groups)
+			roots[g] = group
+			pl.groups = append(pl.groups, nil)
+		}
+		pl.groupOf[i] = group
+		pl.groups[group] = append(pl.groups[group], i)
+	}
+
+	pl.waitsFor = make([][]int, len(pl.groups))
+	pl.waitsOn = make([][]int, len(pl.groups))
+	for g, steps := range pl.groups {
+		waits := make(map[int]bool)
+		waitOn := func(step int) {
+			waits[pl.groupOf[step]] = true
+			pl.waitsOn[g] = append(pl.waitsOn[g], step)
+		}
+		for _, i := range steps {
+			s := p.Steps[i]
+			for _, after := range s.After {
+				if pl.groupOf[index[after]] == g {
+					return nil, fmt.Errorf("step %s can't run after step %s because they stream into each other", s.Name, after)
+				}
+				waitOn(index[after])
+			}
+			if writer, ok := writers[filepath.Clean(s.Input)]; ok && s.Input != "" {
+				if pl.groupOf[writer] == g {
+					return nil, fmt.Errorf("step %s reads %s, which step %s writes while they stream into each other", s.Name, s.Input, p.Steps[writer].Name)
+				}
+				waitOn(writer)
+			}
+		}
+		for w := range waits {
+			pl.waitsFor[g] = append(pl.waitsFor[g], w)
+		}
+		sort.Ints(pl.waitsFor[g])
+	}
+
+	if cycle := pl.cycle(); cycle != nil {
+		var names []string
+		for _, g := range cycle {
+			names = append(names, p.Steps[pl.groups[g][0]].Name)
+		}
+		return nil, fmt.Errorf("steps wait for each other: %s", strings.Join(names, " -> "))
+	}
+	return pl, nil
+}
+
+// check checks a step on its own. index maps step names to their index.
+func (s Step) check(index map[string]int) error {
+	if s.Recipe == "" {
+		return errors.New("no recipe")
+	}
+	if (s.Input == "") == (s.From == "") {
+		return errors.New("needs exactly one of input and from")
+	}
+	if s.Input == "-" || s.Output == "-" || s.Violations == "-" {
+		return errors.New("standard input and output can't be used in a pipeline")
+	}
+	if s.From != "" {
+		if _, ok := index[s.From]; !ok {
+			return fmt.Errorf("from names unknown step %s", s.From)
+		}
+		if s.From == s.Name {
+			return errors.New("can't read from itself")
+		}
+	}
+	for _, after := range s.After {
+		if _, ok := index[after]; !ok {
+			return fmt.Errorf("after names unknown step %s", after)
+		}
+		if after == s.Name {
+			return errors.New("can't run after itself")
+		}
+	}
+	return nil
+}
+
+// cycle returns groups that wait for each other in a loop, or nil.
+func (pl *plan) cycle() []int {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(pl.groups))
+	var path []int
+	var visit func(g int) []int
+	visit = func(g int) []int {
+		state[g] = visiting
+		path = append(path, g)
+		for _, w := range pl.waitsFor[g] {
+			switch state[w] {
+			case visiting:
+				for i, p := range path {
+					if p == w {
+						return append(append([]int{}, path[i:]...), w)
+					}
+				}
+			case unvisited:
+				if cycle := visit(w); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[g] = done
+		return nil
+	}
+	for g := range pl.groups {
+		if state[g] == unvisited {
+			if cycle := visit(g); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
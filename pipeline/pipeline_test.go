@@ -0,0 +1,384 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			source: `steps:
+  - name: clean
+    input: in.csv
+    recipe: clean.txt
+  - name: upper
+    from: clean
+    output: upper.csv
+    recipe: upper.txt
+    set:
+      region: eu
+  - name: count
+    input: upper.csv
+    output: count.csv
+    recipe: count.txt
+    sort_by: [name]
+`,
+		},
+		{
+			name:   "json",
+			source: `{"steps": [{"name": "a", "input": "in.csv", "output": "out.csv", "recipe": "r.txt"}]}`,
+		},
+		{
+			name:    "no steps",
+			source:  "steps: []\n",
+			wantErr: "pipeline has no steps",
+		},
+		{
+			name:    "unknown field",
+			source:  "steps:\n  - name: a\n    inputs: in.csv\n",
+			wantErr: "field inputs not found",
+		},
+		{
+			name:    "no name",
+			source:  "steps:\n  - input: in.csv\n    output: out.csv\n    recipe: r.txt\n",
+			wantErr: "step 1 has no name",
+		},
+		{
+			name:    "duplicate name",
+			source:  "steps:\n  - {name: a, input: in.csv, output: a.csv, recipe: r.txt}\n  - {name: a, input: in.csv, output: b.csv, recipe: r.txt}\n",
+			wantErr: "step name a is used twice",
+		},
+		{
+			name:    "no recipe",
+			source:  "steps:\n  - {name: a, input: in.csv, output: out.csv}\n",
+			wantErr: "step a: no recipe",
+		},
+		{
+			name:    "input and from",
+			source:  "steps:\n  - {name: a, input: in.csv, recipe: r.txt}\n  - {name: b, input: in.csv, from: a, output: b.csv, recipe: r.txt}\n",
+			wantErr: "step b: needs exactly one of input and from",
+		},
+		{
+			name:    "standard output",
+			source:  "steps:\n  - {name: a, input: in.csv, output: '-', recipe: r.txt}\n",
+			wantErr: "step a: standard input and output can't be used in a pipeline",
+		},
+		{
+			name:    "unknown from",
+			source:  "steps:\n  - {name: a, from: b, output: a.csv, recipe: r.txt}\n",
+			wantErr: "step a: from names unknown step b",
+		},
+		{
+			name:    "unknown after",
+			source:  "steps:\n  - {name: a, input: in.csv, output: a.csv, recipe: r.txt, after: [b]}\n",
+			wantErr: "step a: after names unknown step b",
+		},
+		{
+			name:    "same output",
+			source:  "steps:\n  - {name: a, input: in.csv, output: out.csv, recipe: r.txt}\n  - {name: b, input: in.csv, output: ./out.csv, recipe: r.txt}\n",
+			wantErr: "steps a and b both write ./out.csv",
+		},
+		{
+			name:    "no output",
+			source:  "steps:\n  - {name: a, input: in.csv, recipe: r.txt}\n",
+			wantErr: "step a: no output, and no step reads from it",
+		},
+		{
+			name:    "from loop",
+			source:  "steps:\n  - {name: a, from: b, output: a.csv, recipe: r.txt}\n  - {name: b, from: a, output: b.csv, recipe: r.txt}\n",
+			wantErr: "step a: steps read from each other in a loop",
+		},
+		{
+			name:    "after a step it streams from",
+			source:  "steps:\n  - {name: a, input: in.csv, recipe: r.txt}\n  - {name: b, from: a, output: b.csv, recipe: r.txt, after: [a]}\n",
+			wantErr: "step b can't run after step a because they stream into each other",
+		},
+		{
+			name:    "waiting in a cycle",
+			source:  "steps:\n  - {name: a, input: b.csv, output: a.csv, recipe: r.txt}\n  - {name: b, input: in.csv, output: b.csv, recipe: r.txt, after: [a]}\n",
+			wantErr: "steps wait for each other: a -> b -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(tt.source))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPipeline_ResolvePaths(t *testing.T) {
+	abs := filepath.Join(string(filepath.Separator), "data", "in.csv")
+	p := &Pipeline{Steps: []Step{
+		{Name: "a", Input: abs, Output: "out/a.csv", Recipe: "a.txt", Schema: "a.yaml"},
+		{Name: "b", From: "a", Output: "b.csv", Recipe: "b.txt"},
+	}}
+	p.ResolvePaths("jobs")
+
+	want := []Step{
+		{Name: "a", Input: abs, Output: filepath.Join("jobs", "out", "a.csv"), Recipe: filepath.Join("jobs", "a.txt"), Schema: filepath.Join("jobs", "a.yaml")},
+		{Name: "b", From: "a", Output: filepath.Join("jobs", "b.csv"), Recipe: filepath.Join("jobs", "b.txt")},
+	}
+	if !reflect.DeepEqual(p.Steps, want) {
+		t.Errorf("ResolvePaths() got %+v, want %+v", p.Steps, want)
+	}
+}
+
+// upper is a StepFunc that copies its input in upper case, or fails when the
+// step's recipe is "fail". A "head" recipe copies only the first line.
+func upper(step Step, in io.Reader, out io.Writer) (string, error) {
+	switch filepath.Base(step.Recipe) {
+	case "fail":
+		return "", errors.New("recipe failed")
+	case "head":
+		line, err := firstLine(in)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.WriteString(out, line)
+		return "head", err
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	if _, err := out.Write(bytes.ToUpper(data)); err != nil {
+		return "", err
+	}
+	return "copied", nil
+}
+
+// firstLine reads the first line of r a byte at a time, so the rest stays
+// unread.
+func firstLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := r.Read(b); err != nil {
+			return "", err
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []Step
+		files       map[string]string
+		wantFiles   map[string]string
+		wantMissing []string
+		wantResults []Result
+	}{
+		{
+			name: "chained through from",
+			steps: []Step{
+				{Name: "a", Input: "in.csv", Recipe: "copy"},
+				{Name: "b", From: "a", Output: "b.csv", Recipe: "copy"},
+			},
+			files:     map[string]string{"in.csv": "id,name\n1,ann\n"},
+			wantFiles: map[string]string{"b.csv": "ID,NAME\n1,ANN\n"},
+			wantResults: []Result{
+				{Step: "a", Summary: "copied"},
+				{Step: "b", Summary: "copied"},
+			},
+		},
+		{
+			name: "fan out to a file and two readers",
+			steps: []Step{
+				{Name: "a", Input: "in.csv", Output: "a.csv", Recipe: "copy"},
+				{Name: "b", From: "a", Output: "b.csv", Recipe: "copy"},
+				{Name: "c", From: "a", Output: "c.csv", Recipe: "head"},
+			},
+			files: map[string]string{"in.csv": "id\n1\n2\n"},
+			wantFiles: map[string]string{
+				"a.csv": "ID\n1\n2\n",
+				"b.csv": "ID\n1\n2\n",
+				"c.csv": "ID\n",
+			},
+			wantResults: []Result{
+				{Step: "a", Summary: "copied"},
+				{Step: "b", Summary: "copied"},
+				{Step: "c", Summary: "head"},
+			},
+		},
+		{
+			name: "waits for the file it reads",
+			steps: []Step{
+				{Name: "second", Input: "first.csv", Output: "second.csv", Recipe: "head"},
+				{Name: "first", Input: "in.csv", Output: "first.csv", Recipe: "copy"},
+			},
+			files: map[string]string{"in.csv": "id\n1\n"},
+			wantFiles: map[string]string{
+				"first.csv":  "ID\n1\n",
+				"second.csv": "ID\n",
+			},
+			wantResults: []Result{
+				{Step: "second", Summary: "head"},
+				{Step: "first", Summary: "copied"},
+			},
+		},
+		{
+			name: "failure skips the steps after it",
+			steps: []Step{
+				{Name: "a", Input: "in.csv", Output: "a.csv", Recipe: "fail"},
+				{Name: "b", Input: "in.csv", Output: "b.csv", Recipe: "copy", After: []string{"a"}},
+				{Name: "c", Input: "b.csv", Output: "c.csv", Recipe: "copy"},
+				{Name: "d", Input: "in.csv", Output: "d.csv", Recipe: "copy"},
+			},
+			files:       map[string]string{"in.csv": "id\n"},
+			wantFiles:   map[string]string{"d.csv": "ID\n"},
+			wantMissing: []string{"a.csv", "b.csv", "c.csv"},
+			wantResults: []Result{
+				{Step: "a", Err: errors.New("recipe failed")},
+				{Step: "b", Err: errors.New("step a failed"), Skipped: true},
+				{Step: "c", Err: errors.New("step b failed"), Skipped: true},
+				{Step: "d", Summary: "copied"},
+			},
+		},
+		{
+			name: "failed stream fails its readers",
+			steps: []Step{
+				{Name: "a", Input: "in.csv", Recipe: "fail"},
+				{Name: "b", From: "a", Output: "b.csv", Recipe: "copy"},
+				{Name: "c", Input: "b.csv", Output: "c.csv", Recipe: "copy"},
+			},
+			files:       map[string]string{"in.csv": "id\n"},
+			wantMissing: []string{"b.csv", "c.csv"},
+			wantResults: []Result{
+				{Step: "a", Err: errors.New("recipe failed")},
+				{Step: "b", Err: errors.New("step a failed")},
+				{Step: "c", Err: errors.New("step b failed"), Skipped: true},
+			},
+		},
+		{
+			name: "existing output",
+			steps: []Step{
+				{Name: "a", Input: "in.csv", Output: "a.csv", Recipe: "copy"},
+				{Name: "b", Input: "in.csv", Output: "b.csv", Recipe: "copy", Force: true},
+			},
+			files: map[string]string{"in.csv": "id\n", "a.csv": "old\n", "b.csv": "old\n"},
+			wantFiles: map[string]string{
+				"a.csv": "old\n",
+				"b.csv": "ID\n",
+			},
+			wantResults: []Result{
+				{Step: "a", Err: errors.New("output file already exists: a.csv")},
+				{Step: "b", Summary: "copied"},
+			},
+		},
+		{
+			name: "missing input",
+			steps: []Step{
+				{Name: "a", Input: "missing.csv", Output: "a.csv", Recipe: "copy"},
+			},
+			wantResults: []Result{
+				{Step: "a", Err: errors.New("error opening input file: open missing.csv: no such file or directory")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p := &Pipeline{Steps: tt.steps}
+			p.ResolvePaths(dir)
+
+			got, err := p.Run(upper, 2)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(got) != len(tt.wantResults) {
+				t.Fatalf("Run() got %d results, want %d", len(got), len(tt.wantResults))
+			}
+			for i, want := range tt.wantResults {
+				r := got[i]
+				gotErr, wantErr := "", ""
+				if r.Err != nil {
+					gotErr = strings.Replace(r.Err.Error(), dir+string(filepath.Separator), "", -1)
+				}
+				if want.Err != nil {
+					wantErr = want.Err.Error()
+				}
+				if r.Step != want.Step || r.Summary != want.Summary || r.Skipped != want.Skipped || gotErr != wantErr {
+					t.Errorf("Run() result %d = {%s %q %q %v}, want {%s %q %q %v}", i,
+						r.Step, r.Summary, gotErr, r.Skipped, want.Step, want.Summary, wantErr, want.Skipped)
+				}
+			}
+			for name, want := range tt.wantFiles {
+				data, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Errorf("Run() didn't write %s: %v", name, err)
+					continue
+				}
+				if string(data) != want {
+					t.Errorf("Run() wrote %s = %q, want %q", name, data, want)
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("Run() left %s behind", name)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	results := []Result{
+		{Step: "clean", Summary: "read 3 lines, wrote 3", Duration: 1500 * time.Microsecond},
+		{Step: "counts", Err: errors.New("recipe failed"), Duration: 2 * time.Second},
+		{Step: "totals", Err: errors.New("step counts failed"), Skipped: true},
+		{Step: "broken", Err: errors.New("Error processing your recipe: \nbad.txt:1:6: unrecognized function nosuch\n    1 <- nosuch\n         ^\n")},
+	}
+	var out bytes.Buffer
+	if err := WriteReport(&out, results); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	want := `STEP    STATUS   TIME  DETAILS
+clean   ok       2ms   read 3 lines, wrote 3
+counts  FAILED   2s    recipe failed
+totals  skipped  -     step counts failed
+broken  FAILED   0s    Error processing your recipe: bad.txt:1:6: unrecognized function nosuch
+
+1 ok, 2 failed, 1 skipped
+
+broken:
+Error processing your recipe: 
+bad.txt:1:6: unrecognized function nosuch
+    1 <- nosuch
+         ^
+`
+	if out.String() != want {
+		t.Errorf("WriteReport() got\n%s\nwant\n%s", out.String(), want)
+	}
+}
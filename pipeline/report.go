@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteReport writes a table of the results followed by a count of the
+// steps that succeeded, failed and were skipped. Errors that take more than
+// one line are summed up in the table and written in full after it.
+func WriteReport(w io.Writer, results []Result) error {
+	var ok, failed, skipped int
+	var details []Result
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tTIME\tDETAILS")
+	for _, r := range results {
+		status, took, summary := "ok", r.Duration.Round(time.Millisecond).String(), r.Summary
+		switch {
+		case r.Skipped:
+			status, took, summary = "skipped", "-", r.Err.Error()
+			skipped++
+		case r.Err != nil:
+			status, summary = "FAILED", summarize(r.Err)
+			if summary != r.Err.Error() {
+				details = append(details, r)
+			}
+			failed++
+		default:
+			ok++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Step, status, took, summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "\n%d ok, %d failed, %d skipped\n", ok, failed, skipped); err != nil {
+		return err
+	}
+	for _, r := range details {
+		if _, err := fmt.Fprintf(w, "\n%s:\n%s\n", r.Step, strings.TrimRight(r.Err.Error(), "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarize returns the first line of an error for the report table. A line
+// ending with a colon introduces the next, so the two are joined.
+func summarize(err error) string {
+	var lines []string
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	switch {
+	case len(lines) == 0:
+		return ""
+	case len(lines) > 1 && strings.HasSuffix(lines[0], ":"):
+		return lines[0] + " " + lines[1]
+	}
+	return lines[0]
+}
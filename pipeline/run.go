@@ -0,0 +1,200 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// StepFunc bakes one step, reading the input CSV from in and writing the
+// output CSV to out. It returns a short summary of what it did.
+type StepFunc func(step Step, in io.Reader, out io.Writer) (string, error)
+
+// Result is the outcome of one step. A skipped step didn't run because a
+// step it waits for failed; Err says which.
+type Result struct {
+	Step     string
+	Summary  string
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// errStopped closes a stream whose reader has finished, so the step writing
+// it stops sending it rows.
+var errStopped = errors.New("reader finished")
+
+// Run runs every step with run and returns their results in step order.
+// Steps that stream into each other run together as a group, and at most
+// parallel groups run at once; zero or less means no limit. A group whose
+// steps wait for a step that failed is skipped.
+func (p *Pipeline) Run(run StepFunc, parallel int) ([]Result, error) {
+	pl, err := p.plan()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, len(p.Steps))
+	for i, s := range p.Steps {
+		results[i].Step = s.Name
+	}
+
+	var slots chan struct{}
+	if parallel > 0 {
+		slots = make(chan struct{}, parallel)
+	}
+	done := make([]chan struct{}, len(pl.groups))
+	for g := range done {
+		done[g] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for g := range pl.groups {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer close(done[g])
+
+			for _, w := range pl.waitsFor[g] {
+				<-done[w]
+			}
+			if failed := p.failedStep(pl.waitsOn[g], results); failed != "" {
+				for _, i := range pl.groups[g] {
+					results[i].Skipped = true
+					results[i].Err = fmt.Errorf("step %s failed", failed)
+				}
+				return
+			}
+
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			p.runGroup(pl.groups[g], run, results)
+		}(g)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// failedStep returns the name of the first of the given steps that failed
+// or was skipped, or "" if they all succeeded.
+func (p *Pipeline) failedStep(steps []int, results []Result) string {
+	for _, i := range steps {
+		if results[i].Err != nil {
+			return p.Steps[i].Name
+		}
+	}
+	return ""
+}
+
+// runGroup runs the steps of one group at the same time, connecting each
+// step that reads from another with a pipe.
+func (p *Pipeline) runGroup(group []int, run StepFunc, results []Result) {
+	readers := make(map[int]*io.PipeReader)
+	writers := make(map[int][]*io.PipeWriter)
+	for _, i := range group {
+		if from := p.Steps[i].From; from != "" {
+			for _, j := range group {
+				if p.Steps[j].Name == from {
+					pr, pw := io.Pipe()
+					readers[i] = pr
+					writers[j] = append(writers[j], pw)
+				}
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, i := range group {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			var in io.Reader
+			if pr := readers[i]; pr != nil {
+				in = pr
+			}
+			summary, err := p.runStep(p.Steps[i], in, writers[i], run)
+			results[i].Summary, results[i].Err, results[i].Duration = summary, err, time.Since(start)
+
+			for _, pw := range writers[i] {
+				if err != nil {
+					_ = pw.CloseWithError(fmt.Errorf("step %s failed", p.Steps[i].Name))
+				} else {
+					_ = pw.Close()
+				}
+			}
+			if pr := readers[i]; pr != nil {
+				_ = pr.CloseWithError(errStopped)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// runStep opens the input and output of a step and runs it. in is the
+// stream it reads from, if any, and streams are the steps reading from it.
+// The output file is removed if the step fails.
+func (p *Pipeline) runStep(step Step, in io.Reader, streams []*io.PipeWriter, run StepFunc) (string, error) {
+	if in == nil {
+		file, err := os.Open(step.Input)
+		if err != nil {
+			return "", fmt.Errorf("error opening input file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+	}
+
+	out := &fanOut{streams: streams}
+	var file *os.File
+	if step.Output != "" {
+		if _, err := os.Stat(step.Output); err == nil && !step.Force {
+			return "", fmt.Errorf("output file already exists: %s", step.Output)
+		}
+		var err error
+		if file, err = os.Create(step.Output); err != nil {
+			return "", fmt.Errorf("error creating output file: %v", err)
+		}
+		out.file = file
+	}
+
+	summary, err := run(step, in, out)
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("error writing output file: %v", closeErr)
+		}
+		if err != nil {
+			// don't leave a partial output behind to block the next run
+			_ = os.Remove(step.Output)
+		}
+	}
+	return summary, err
+}
+
+// fanOut writes a step's output to its file and to the steps streaming it.
+// A stream whose reader has stopped, whether it finished early or failed,
+// is dropped so the other readers and the file still get every row.
+type fanOut struct {
+	file    io.Writer
+	streams []*io.PipeWriter
+}
+
+func (f *fanOut) Write(b []byte) (int, error) {
+	if f.file != nil {
+		if n, err := f.file.Write(b); err != nil {
+			return n, err
+		}
+	}
+	streams := f.streams[:0]
+	for _, pw := range f.streams {
+		if _, err := pw.Write(b); err == nil {
+			streams = append(streams, pw)
+		}
+	}
+	f.streams = streams
+	return len(b), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/csv-chef/config"
+	"github.com/dstockto/csv-chef/recipe"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// readmeBlock returns the first fenced block of the given language after
+// the README heading.
+func readmeBlock(t *testing.T, heading, language string) string {
+	t.Helper()
+	readme, err := ioutil.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := strings.Index(string(readme), heading)
+	if section < 0 {
+		t.Fatalf("README has no %q section", heading)
+	}
+	text := string(readme[section:])
+	fence := "```" + language + "\n"
+	start := strings.Index(text, fence)
+	if start < 0 {
+		t.Fatalf("README %q section has no %s example", heading, language)
+	}
+	text = text[start+len(fence):]
+	end := strings.Index(text, "```\n")
+	if end < 0 {
+		t.Fatalf("README %q example is not closed", heading)
+	}
+	return text[:end]
+}
+
+// TestReadmeConfigExample loads the example config file from the README, so
+// the example keeps working when the settings change.
+func TestReadmeConfigExample(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(readmeBlock(t, "Config file and profiles\n--\n", "yaml")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig() error = %v", err)
+	}
+	c, err := config.New(v, "vendorX")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	flags := pflag.NewFlagSet("lint", pflag.ContinueOnError)
+	disabled := flags.StringSlice("disable", nil, "")
+	if _, err := c.Apply("lint", flags, []config.Setting{{Key: "disable", Flag: "disable"}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(*disabled) == 0 {
+		t.Error("README example disables no lint checks")
+	}
+	for _, code := range *disabled {
+		if _, ok := recipe.LookupLintCheck(code); !ok {
+			t.Errorf("README example disables unknown lint check %q", code)
+		}
+	}
+}
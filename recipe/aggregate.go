@@ -0,0 +1,420 @@
+package recipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dstockto/csv-chef/extsort"
+)
+
+// aggregateFuncs are the functions that turn a recipe into a group-by
+// recipe. When one ends a column recipe, that column is aggregated and every
+// column without one becomes part of the group key.
+var aggregateFuncs = map[string]bool{
+	"count":         true,
+	"sum":           true,
+	"min":           true,
+	"max":           true,
+	"avg":           true,
+	"first":         true,
+	"last":          true,
+	"countdistinct": true,
+}
+
+// IsAggregate reports whether any column recipe ends in an aggregate
+// function.
+func (t *Transformation) IsAggregate() bool {
+	for _, c := range t.Columns {
+		if aggregateFunction(c) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateFunction returns the lower-cased aggregate function that ends the
+// recipe, or an empty string if there isn't one.
+func aggregateFunction(r Recipe) string {
+	if len(r.Pipe) == 0 {
+		return ""
+	}
+	name := strings.ToLower(r.Pipe[len(r.Pipe)-1].Name)
+	if aggregateFuncs[name] {
+		return name
+	}
+	return ""
+}
+
+// aggregateDiagnostics ensures aggregate functions only appear as the last
+// step of a column recipe.
+func (t *Transformation) aggregateDiagnostics() []Diagnostic {
+	var diagnostics []Diagnostic
+	check := func(kind string, r Recipe, allowLast bool) {
+		for i, o := range r.Pipe {
+			name := strings.ToLower(o.Name)
+			if !aggregateFuncs[name] {
+				continue
+			}
+			if !allowLast {
+				diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "aggregate function %s can only be used in column recipes, found in %s %s", o.Name, kind, r.Output.Value))
+			} else if i != len(r.Pipe)-1 {
+				diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "aggregate function %s must be the last step of column %s", o.Name, r.Output.Value))
+			}
+		}
+	}
+
+	for _, name := range t.VariableOrder {
+		check("variable", t.Variables[name], false)
+	}
+	for c := 1; c <= len(t.Columns); c++ {
+		check("column", t.Columns[c], true)
+	}
+	for h := 1; h <= len(t.Columns); h++ {
+		if header, ok := t.Headers[h]; ok {
+			check("header", header, false)
+		}
+	}
+	if t.Explode != nil {
+		check("explode", t.Explode.Recipe, false)
+	}
+	return diagnostics
+}
+
+// accumulator holds the running result of one aggregate function for one
+// group. It is exported to JSON when groups are spilled to disk.
+type accumulator struct {
+	Count    int64    `json:"count"`
+	Values   int64    `json:"values"`
+	Sum      float64  `json:"sum"`
+	Min      string   `json:"min"`
+	Max      string   `json:"max"`
+	First    string   `json:"first"`
+	FirstSeq int64    `json:"first_seq"`
+	Last     string   `json:"last"`
+	LastSeq  int64    `json:"last_seq"`
+	Distinct []string `json:"distinct,omitempty"`
+
+	distinct map[string]struct{}
+}
+
+// compareValues compares numerically when both values are numbers and as
+// strings otherwise.
+func compareValues(a, b string) int {
+	af, aErr := strconv.ParseFloat(a, 64)
+	bf, bErr := strconv.ParseFloat(b, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func (a *accumulator) add(function string, value string, seq int64) error {
+	a.Count++
+	if a.Count == 1 {
+		a.First, a.FirstSeq = value, seq
+	}
+	a.Last, a.LastSeq = value, seq
+
+	// empty values only count towards count, first and last
+	if value == "" {
+		return nil
+	}
+	switch function {
+	case "sum", "avg":
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("input is not numeric: got '%s'", value)
+		}
+		a.Sum += num
+	case "countdistinct":
+		if a.distinct == nil {
+			a.distinct = make(map[string]struct{})
+		}
+		a.distinct[value] = struct{}{}
+	}
+	if a.Values == 0 || compareValues(value, a.Min) < 0 {
+		a.Min = value
+	}
+	if a.Values == 0 || compareValues(value, a.Max) > 0 {
+		a.Max = value
+	}
+	a.Values++
+	return nil
+}
+
+func (a *accumulator) merge(other *accumulator) {
+	if other.Count == 0 {
+		return
+	}
+	if a.Count == 0 || other.FirstSeq < a.FirstSeq {
+		a.First, a.FirstSeq = other.First, other.FirstSeq
+	}
+	if a.Count == 0 || other.LastSeq > a.LastSeq {
+		a.Last, a.LastSeq = other.Last, other.LastSeq
+	}
+	if other.Values > 0 {
+		if a.Values == 0 || compareValues(other.Min, a.Min) < 0 {
+			a.Min = other.Min
+		}
+		if a.Values == 0 || compareValues(other.Max, a.Max) > 0 {
+			a.Max = other.Max
+		}
+	}
+	a.Count += other.Count
+	a.Values += other.Values
+	a.Sum += other.Sum
+	for value := range other.distinct {
+		if a.distinct == nil {
+			a.distinct = make(map[string]struct{})
+		}
+		a.distinct[value] = struct{}{}
+	}
+}
+
+func (a *accumulator) result(function string) string {
+	switch function {
+	case "count":
+		return strconv.FormatInt(a.Count, 10)
+	case "sum":
+		return strconv.FormatFloat(a.Sum, 'f', -1, 64)
+	case "avg":
+		if a.Values == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(a.Sum/float64(a.Values), 'f', -1, 64)
+	case "min":
+		return a.Min
+	case "max":
+		return a.Max
+	case "first":
+		return a.First
+	case "last":
+		return a.Last
+	case "countdistinct":
+		return strconv.Itoa(len(a.distinct))
+	}
+	return ""
+}
+
+func (a *accumulator) encode() (string, error) {
+	a.Distinct = a.Distinct[:0]
+	for value := range a.distinct {
+		a.Distinct = append(a.Distinct, value)
+	}
+	sort.Strings(a.Distinct)
+	encoded, err := json.Marshal(a)
+	return string(encoded), err
+}
+
+func decodeAccumulator(encoded string) (*accumulator, error) {
+	var a accumulator
+	if err := json.Unmarshal([]byte(encoded), &a); err != nil {
+		return nil, err
+	}
+	if len(a.Distinct) > 0 {
+		a.distinct = make(map[string]struct{}, len(a.Distinct))
+		for _, value := range a.Distinct {
+			a.distinct[value] = struct{}{}
+		}
+		a.Distinct = nil
+	}
+	return &a, nil
+}
+
+type group struct {
+	keys []string
+	accs []*accumulator
+}
+
+// aggregator groups output rows by their key columns. Groups are kept in
+// memory until there are more than maxGroups of them, at which point they
+// are spilled to disk as partial results and merged again at the end.
+type aggregator struct {
+	numColumns int
+	keyColumns []int
+	aggColumns []int
+	functions  []string
+	groups     map[string]*group
+	maxGroups  int
+	seq        int64
+	sorter     *extsort.Sorter
+}
+
+func (t *Transformation) newAggregator() *aggregator {
+	agg := &aggregator{
+		numColumns: len(t.Columns),
+		groups:     make(map[string]*group),
+		maxGroups:  t.MaxGroupsInMemory,
+	}
+	if agg.maxGroups <= 0 {
+		agg.maxGroups = extsort.DefaultMaxInMemory
+	}
+	for c := 1; c <= len(t.Columns); c++ {
+		if function := aggregateFunction(t.Columns[c]); function != "" {
+			agg.aggColumns = append(agg.aggColumns, c)
+			agg.functions = append(agg.functions, function)
+		} else {
+			agg.keyColumns = append(agg.keyColumns, c)
+		}
+	}
+	return agg
+}
+
+func (agg *aggregator) add(lineNo int, output map[int]string) error {
+	keys := make([]string, len(agg.keyColumns))
+	for i, c := range agg.keyColumns {
+		keys[i] = output[c]
+	}
+	encodedKey, _ := json.Marshal(keys)
+
+	g, ok := agg.groups[string(encodedKey)]
+	if !ok {
+		if len(agg.groups) >= agg.maxGroups {
+			if err := agg.spill(); err != nil {
+				return err
+			}
+		}
+		g = &group{keys: keys, accs: make([]*accumulator, len(agg.aggColumns))}
+		for i := range g.accs {
+			g.accs[i] = &accumulator{}
+		}
+		agg.groups[string(encodedKey)] = g
+	}
+
+	agg.seq++
+	for i, c := range agg.aggColumns {
+		if err := g.accs[i].add(agg.functions[i], output[c], agg.seq); err != nil {
+			return fmt.Errorf("line %d / column %d: %s(): %v", lineNo, c, agg.functions[i], err)
+		}
+	}
+	return nil
+}
+
+func (agg *aggregator) compareKeys(a, b []string) bool {
+	for i := range agg.keyColumns {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+func (agg *aggregator) spill() error {
+	if agg.sorter == nil {
+		agg.sorter = extsort.New(agg.compareKeys, agg.maxGroups, "")
+	}
+	for _, g := range agg.groups {
+		record := append([]string{}, g.keys...)
+		for _, acc := range g.accs {
+			encoded, err := acc.encode()
+			if err != nil {
+				return err
+			}
+			record = append(record, encoded)
+		}
+		if err := agg.sorter.Add(record); err != nil {
+			return err
+		}
+	}
+	agg.groups = make(map[string]*group)
+	return nil
+}
+
+// discard removes any partial results spilled to disk when the bake stops
+// before finish. It does nothing once finish has sorted them.
+func (agg *aggregator) discard() error {
+	if agg.sorter == nil {
+		return nil
+	}
+	return agg.sorter.Discard()
+}
+
+// finish writes one row per group, ordered by the group key, and returns the
+// number of groups written.
+func (agg *aggregator) finish(emit func(map[int]string) error) (int, error) {
+	if agg.sorter == nil {
+		sorted := make([]*group, 0, len(agg.groups))
+		for _, g := range agg.groups {
+			sorted = append(sorted, g)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return agg.compareKeys(sorted[i].keys, sorted[j].keys) })
+		for _, g := range sorted {
+			if err := emit(agg.row(g)); err != nil {
+				return 0, err
+			}
+		}
+		return len(sorted), nil
+	}
+
+	if err := agg.spill(); err != nil {
+		return 0, err
+	}
+	it, err := agg.sorter.Sort()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = it.Close() }()
+
+	var current *group
+	written := 0
+	for {
+		record, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		next := &group{keys: record[:len(agg.keyColumns)]}
+		for _, encoded := range record[len(agg.keyColumns):] {
+			acc, err := decodeAccumulator(encoded)
+			if err != nil {
+				return written, err
+			}
+			next.accs = append(next.accs, acc)
+		}
+
+		if current != nil && !agg.compareKeys(current.keys, next.keys) {
+			// same key as the group being built, so combine the partial results
+			for i, acc := range next.accs {
+				current.accs[i].merge(acc)
+			}
+			continue
+		}
+		if current != nil {
+			if err := emit(agg.row(current)); err != nil {
+				return written, err
+			}
+			written++
+		}
+		current = next
+	}
+	if current != nil {
+		if err := emit(agg.row(current)); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+func (agg *aggregator) row(g *group) map[int]string {
+	output := make(map[int]string, agg.numColumns)
+	for i, c := range agg.keyColumns {
+		output[c] = g.keys[i]
+	}
+	for i, c := range agg.aggColumns {
+		output[c] = g.accs[i].result(agg.functions[i])
+	}
+	return output
+}
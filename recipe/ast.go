@@ -0,0 +1,244 @@
+package recipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ASTVersion is the version of the AST written by ParseAST. It is increased
+// whenever a field is renamed, removed or changes meaning, so tools reading
+// the AST can tell whether they understand it. Adding a field does not
+// change the version.
+const ASTVersion = 1
+
+// Position is a place in a recipe source. Line and Column are both 1-based
+// and Column counts characters, not bytes.
+type Position struct {
+	Line   int `json:"line" yaml:"line"`
+	Column int `json:"column" yaml:"column"`
+}
+
+// Comment is a comment on a line of its own.
+type Comment struct {
+	Text     string   `json:"text" yaml:"text"`
+	Position Position `json:"position" yaml:"position"`
+}
+
+// StatementKind tells the kinds of recipe lines apart.
+type StatementKind string
+
+const (
+	// RecipeStatement assigns a pipe to a column, header, variable,
+	// persistent variable or explode.
+	RecipeStatement StatementKind = "recipe"
+	// ParameterStatement declares a parameter, with or without a default.
+	ParameterStatement StatementKind = "parameter"
+	// InitStatement sets the starting value of a persistent variable.
+	InitStatement StatementKind = "init"
+)
+
+// statementSource records where a statement and its operations were found
+// while parsing.
+type statementSource struct {
+	kind       StatementKind
+	output     Output
+	position   Position
+	operations []Position
+	// implicit records, for each operation, whether the parser added a
+	// placeholder argument the recipe doesn't show.
+	implicit []bool
+	comment  string
+}
+
+// sourceMap collects positions while parsing. A nil sourceMap records
+// nothing, which is what Parse uses.
+type sourceMap struct {
+	lines      []string
+	statements []statementSource
+	comments   []Comment
+}
+
+// find returns the last statement that assigned to output.
+func (m *sourceMap) find(output Output) (statementSource, bool) {
+	if m == nil {
+		return statementSource{}, false
+	}
+	for i := len(m.statements) - 1; i >= 0; i-- {
+		s := m.statements[i]
+		if s.kind == RecipeStatement && s.output == output {
+			return s, true
+		}
+	}
+	return statementSource{}, false
+}
+
+// implicitPlaceholder reports whether the parser added a placeholder the
+// recipe doesn't show to the arguments of the given step of the recipe for
+// output. It is false when the source isn't known.
+func (m *sourceMap) implicitPlaceholder(output Output, step int) bool {
+	statement, ok := m.find(output)
+	return ok && step < len(statement.implicit) && statement.implicit[step]
+}
+
+// line returns the text of a 1-based source line.
+func (m *sourceMap) line(lineNo int) string {
+	if m == nil || lineNo < 1 || lineNo > len(m.lines) {
+		return ""
+	}
+	return m.lines[lineNo-1]
+}
+
+func (m *sourceMap) addStatement(s statementSource) {
+	if m == nil {
+		return
+	}
+	m.statements = append(m.statements, s)
+}
+
+func (m *sourceMap) addComment(c Comment) {
+	if m == nil {
+		return
+	}
+	m.comments = append(m.comments, c)
+}
+
+// ASTArgument is an argument to an operation. Type is the name of its
+// DataType.
+type ASTArgument struct {
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// ASTOperation is one step of a pipe. Joins created by + are operations
+// named join positioned at the +.
+type ASTOperation struct {
+	Name      string        `json:"name" yaml:"name"`
+	Position  Position      `json:"position" yaml:"position"`
+	Arguments []ASTArgument `json:"arguments" yaml:"arguments"`
+}
+
+// ASTOutput is what a statement assigns to. Type is the name of its
+// DataType.
+type ASTOutput struct {
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// ASTStatement is one recipe line. Value holds a parameter's default or a
+// persistent variable's starting value and is nil when there is none.
+// Delimiter is only set for explode and Type only for columns with a type
+// annotation.
+type ASTStatement struct {
+	Kind      StatementKind  `json:"kind" yaml:"kind"`
+	Position  Position       `json:"position" yaml:"position"`
+	Output    ASTOutput      `json:"output" yaml:"output"`
+	Type      string         `json:"type,omitempty" yaml:"type,omitempty"`
+	Delimiter string         `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
+	Value     *string        `json:"value,omitempty" yaml:"value,omitempty"`
+	Pipe      []ASTOperation `json:"pipe,omitempty" yaml:"pipe,omitempty"`
+	Comment   string         `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// AST is a recipe as it was written: its statements in source order, the
+// comments on lines of their own and the order variables are evaluated in.
+type AST struct {
+	Version       int            `json:"version" yaml:"version"`
+	Statements    []ASTStatement `json:"statements" yaml:"statements"`
+	Comments      []Comment      `json:"comments" yaml:"comments"`
+	VariableOrder []string       `json:"variableOrder" yaml:"variableOrder"`
+}
+
+func astOperations(pipe []Operation, positions []Position) []ASTOperation {
+	operations := make([]ASTOperation, len(pipe))
+	for i, o := range pipe {
+		operations[i] = ASTOperation{Name: o.Name, Arguments: []ASTArgument{}}
+		if i < len(positions) {
+			operations[i].Position = positions[i]
+		}
+		for _, a := range o.Arguments {
+			operations[i].Arguments = append(operations[i].Arguments, ASTArgument{Type: a.Type.String(), Value: a.Value})
+		}
+	}
+	return operations
+}
+
+// ParseAST parses a recipe and returns its AST.
+func ParseAST(source io.Reader) (*AST, error) {
+	m := &sourceMap{}
+	t, err := parse(source, m)
+	if err != nil {
+		return nil, err
+	}
+
+	ast := &AST{
+		Version:       ASTVersion,
+		Statements:    []ASTStatement{},
+		Comments:      []Comment{},
+		VariableOrder: []string{},
+	}
+	ast.Comments = append(ast.Comments, m.comments...)
+	ast.VariableOrder = append(ast.VariableOrder, t.VariableOrder...)
+
+	for _, s := range m.statements {
+		statement := ASTStatement{
+			Kind:     s.kind,
+			Position: s.position,
+			Output:   ASTOutput{Type: s.output.Type.String(), Value: s.output.Value},
+			Comment:  s.comment,
+		}
+		switch s.kind {
+		case ParameterStatement:
+			if spec := t.Parameters[s.output.Value]; spec.HasDefault {
+				value := spec.Default
+				statement.Value = &value
+			}
+		case InitStatement:
+			value := t.PersistentInit[s.output.Value]
+			statement.Value = &value
+		case RecipeStatement:
+			var r Recipe
+			switch s.output.Type {
+			case Column:
+				c, _ := strconv.Atoi(s.output.Value)
+				r = t.Columns[c]
+			case Header:
+				h, _ := strconv.Atoi(s.output.Value)
+				r = t.Headers[h]
+			case Variable, Persistent:
+				r = t.Variables[s.output.Value]
+			case Explode:
+				r = t.Explode.Recipe
+				statement.Delimiter = t.Explode.Delimiter
+			}
+			if r.Type != nil {
+				statement.Type = r.Type.String()
+			}
+			statement.Pipe = astOperations(r.Pipe, s.operations)
+			statement.Comment = r.Comment
+		}
+		ast.Statements = append(ast.Statements, statement)
+	}
+	return ast, nil
+}
+
+// WriteAST writes an AST to w as "json" or "yaml".
+func WriteAST(w io.Writer, ast *AST, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ast)
+	case "yaml":
+		out, err := yaml.Marshal(ast)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+	return fmt.Errorf("unknown AST format %q, expected json or yaml", format)
+}
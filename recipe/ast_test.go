@@ -0,0 +1,244 @@
+package recipe
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAST(t *testing.T) {
+	acme, zero := "acme", "0"
+	tests := []struct {
+		name    string
+		source  string
+		want    *AST
+		wantErr bool
+	}{
+		{
+			name:   "pipe with joins and functions",
+			source: "1 <- 2 + \" \" + 3 -> uppercase # name\n",
+			want: &AST{
+				Version: ASTVersion,
+				Statements: []ASTStatement{
+					{
+						Kind:     RecipeStatement,
+						Position: Position{Line: 1, Column: 1},
+						Output:   ASTOutput{Type: "Column", Value: "1"},
+						Pipe: []ASTOperation{
+							{Name: "value", Position: Position{Line: 1, Column: 6}, Arguments: []ASTArgument{{Type: "Column", Value: "2"}}},
+							{Name: "join", Position: Position{Line: 1, Column: 8}, Arguments: []ASTArgument{{Type: "Placeholder", Value: "?"}}},
+							{Name: "value", Position: Position{Line: 1, Column: 10}, Arguments: []ASTArgument{{Type: "Literal", Value: " "}}},
+							{Name: "join", Position: Position{Line: 1, Column: 14}, Arguments: []ASTArgument{{Type: "Placeholder", Value: "?"}}},
+							{Name: "value", Position: Position{Line: 1, Column: 16}, Arguments: []ASTArgument{{Type: "Column", Value: "3"}}},
+							{Name: "uppercase", Position: Position{Line: 1, Column: 21}, Arguments: []ASTArgument{{Type: "Placeholder", Value: "?"}}},
+						},
+						Comment: "name",
+					},
+				},
+				Comments:      []Comment{},
+				VariableOrder: []string{},
+			},
+		},
+		{
+			name:   "declarations, comments and variable order",
+			source: "# setup\n%client = \"acme\" # who\n\n@n = \"0\"\n  $b <- %client\n@n <- add(@n, \"1\")->trim\n!1 <- \"Id\"\n",
+			want: &AST{
+				Version: ASTVersion,
+				Statements: []ASTStatement{
+					{Kind: ParameterStatement, Position: Position{Line: 2, Column: 1}, Output: ASTOutput{Type: "Parameter", Value: "%client"}, Value: &acme, Comment: "who"},
+					{Kind: InitStatement, Position: Position{Line: 4, Column: 1}, Output: ASTOutput{Type: "Persistent", Value: "@n"}, Value: &zero},
+					{
+						Kind:     RecipeStatement,
+						Position: Position{Line: 5, Column: 3},
+						Output:   ASTOutput{Type: "Variable", Value: "$b"},
+						Pipe: []ASTOperation{
+							{Name: "value", Position: Position{Line: 5, Column: 9}, Arguments: []ASTArgument{{Type: "Parameter", Value: "%client"}}},
+						},
+					},
+					{
+						Kind:     RecipeStatement,
+						Position: Position{Line: 6, Column: 1},
+						Output:   ASTOutput{Type: "Persistent", Value: "@n"},
+						Pipe: []ASTOperation{
+							{Name: "add", Position: Position{Line: 6, Column: 7}, Arguments: []ASTArgument{{Type: "Persistent", Value: "@n"}, {Type: "Literal", Value: "1"}, {Type: "Placeholder", Value: "?"}}},
+							{Name: "trim", Position: Position{Line: 6, Column: 21}, Arguments: []ASTArgument{{Type: "Placeholder", Value: "?"}}},
+						},
+					},
+					{
+						Kind:     RecipeStatement,
+						Position: Position{Line: 7, Column: 1},
+						Output:   ASTOutput{Type: "Header", Value: "1"},
+						Pipe: []ASTOperation{
+							{Name: "value", Position: Position{Line: 7, Column: 7}, Arguments: []ASTArgument{{Type: "Literal", Value: "Id"}}},
+						},
+					},
+				},
+				Comments:      []Comment{{Text: "setup", Position: Position{Line: 1, Column: 1}}},
+				VariableOrder: []string{"$b", "@n"},
+			},
+		},
+		{
+			name:   "explode and required parameter",
+			source: "%p\nexplode(\";\") <- 1\n",
+			want: &AST{
+				Version: ASTVersion,
+				Statements: []ASTStatement{
+					{Kind: ParameterStatement, Position: Position{Line: 1, Column: 1}, Output: ASTOutput{Type: "Parameter", Value: "%p"}},
+					{
+						Kind:      RecipeStatement,
+						Position:  Position{Line: 2, Column: 1},
+						Output:    ASTOutput{Type: "Explode", Value: "explode"},
+						Delimiter: ";",
+						Pipe: []ASTOperation{
+							{Name: "value", Position: Position{Line: 2, Column: 17}, Arguments: []ASTArgument{{Type: "Column", Value: "1"}}},
+						},
+					},
+				},
+				Comments:      []Comment{},
+				VariableOrder: []string{},
+			},
+		},
+		{
+			name:    "invalid recipe",
+			source:  "1 <- nosuchfunction\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAST(strings.NewReader(tt.source))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAST() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAST() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAST(t *testing.T) {
+	ast, err := ParseAST(strings.NewReader("$a <- 1 # one\n"))
+	if err != nil {
+		t.Fatalf("ParseAST() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "json",
+			format: "json",
+			want: `{
+  "version": 1,
+  "statements": [
+    {
+      "kind": "recipe",
+      "position": {
+        "line": 1,
+        "column": 1
+      },
+      "output": {
+        "type": "Variable",
+        "value": "$a"
+      },
+      "pipe": [
+        {
+          "name": "value",
+          "position": {
+            "line": 1,
+            "column": 7
+          },
+          "arguments": [
+            {
+              "type": "Column",
+              "value": "1"
+            }
+          ]
+        }
+      ],
+      "comment": "one"
+    }
+  ],
+  "comments": [],
+  "variableOrder": [
+    "$a"
+  ]
+}
+`,
+		},
+		{
+			name:   "yaml",
+			format: "yaml",
+			want: `version: 1
+statements:
+- kind: recipe
+  position:
+    line: 1
+    column: 1
+  output:
+    type: Variable
+    value: $a
+  pipe:
+  - name: value
+    position:
+      line: 1
+      column: 7
+    arguments:
+    - type: Column
+      value: "1"
+  comment: one
+comments: []
+variableOrder:
+- $a
+`,
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := WriteAST(&b, ast, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteAST() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("WriteAST() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformation_Dump_Deterministic(t *testing.T) {
+	source := "%b = \"2\"\n%a\n@y = \"1\"\n@x = \"0\"\n$z <- 1\n$m <- 2\n$a <- 3\n3 <- $a\n1 <- $z\n2 <- $m\n!2 <- \"B\"\n!1 <- \"A\"\n"
+	want := ""
+	for i := 0; i < 20; i++ {
+		transformation, err := Parse(strings.NewReader(source))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		var b bytes.Buffer
+		transformation.Dump(&b)
+		if i == 0 {
+			want = b.String()
+			for _, ordered := range [][]string{{"Header: 1", "Header: 2"}, {"Param: %a", "Param: %b"}, {"@x = 0", "@y = 1"}, {"Var: $z", "Var: $m", "Var: $a"}, {"Column: 1", "Column: 2", "Column: 3"}} {
+				for j := 1; j < len(ordered); j++ {
+					if strings.Index(want, ordered[j-1]) > strings.Index(want, ordered[j]) {
+						t.Errorf("Dump() wrote %q after %q", ordered[j-1], ordered[j])
+					}
+				}
+			}
+			continue
+		}
+		if b.String() != want {
+			t.Fatalf("Dump() changed between runs:\n%s\nwant\n%s", b.String(), want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package recipe
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Column type names used in annotations such as `3:int <- 2`.
+const (
+	TypeInt     = "int"
+	TypeDecimal = "decimal"
+	TypeBool    = "bool"
+	TypeDate    = "date"
+)
+
+var columnTypeDescriptions = map[string]string{
+	TypeInt:     "an int",
+	TypeDecimal: "a decimal",
+	TypeBool:    "a bool",
+}
+
+// DefaultDateLayout is the layout of a date annotation given without one.
+const DefaultDateLayout = "2006-01-02"
+
+// ColumnType is the type an annotated column promises its values have.
+// Layout is the date layout of date columns.
+type ColumnType struct {
+	Name   string
+	Layout string
+}
+
+// parseColumnType reads the type name of an annotation and, for dates, its
+// optional layout.
+func parseColumnType(name string, layout *string) (ColumnType, error) {
+	switch name {
+	case TypeInt, TypeDecimal, TypeBool:
+		if layout != nil {
+			return ColumnType{}, fmt.Errorf("type %s does not take a layout", name)
+		}
+		return ColumnType{Name: name}, nil
+	case TypeDate:
+		if layout == nil {
+			return ColumnType{Name: name, Layout: DefaultDateLayout}, nil
+		}
+		if *layout == "" {
+			return ColumnType{}, fmt.Errorf("date layout is empty")
+		}
+		return ColumnType{Name: name, Layout: *layout}, nil
+	}
+	return ColumnType{}, fmt.Errorf("unknown column type %q, expected int, decimal, bool or date", name)
+}
+
+// String returns the annotation as it is written in a recipe.
+func (c ColumnType) String() string {
+	if c.Name == TypeDate && c.Layout != DefaultDateLayout {
+		return c.Name + "(" + formatLiteral(c.Layout) + ")"
+	}
+	return c.Name
+}
+
+// Check returns an error if value is not of the type. Empty values are
+// allowed, standing for a missing value.
+func (c ColumnType) Check(value string) error {
+	if value == "" {
+		return nil
+	}
+	var err error
+	switch c.Name {
+	case TypeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeDecimal:
+		_, err = strconv.ParseFloat(value, 64)
+	case TypeBool:
+		_, err = strconv.ParseBool(value)
+	case TypeDate:
+		if _, err = time.Parse(c.Layout, value); err != nil {
+			return fmt.Errorf("value %q is not a date like %s", value, c.Layout)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("value %q is not %s", value, columnTypeDescriptions[c.Name])
+	}
+	return nil
+}
+
+// checkColumnTypes checks the values of annotated columns in an output row.
+// lineNo is the input line the row came from, or 0 for aggregate groups.
+func (t *Transformation) checkColumnTypes(lineNo int, output map[int]string) error {
+	for _, c := range sortedRecipeKeys(t.Columns) {
+		columnType := t.Columns[c].Type
+		if columnType == nil {
+			continue
+		}
+		if err := columnType.Check(output[c]); err != nil {
+			if lineNo == 0 {
+				return fmt.Errorf("column %d: %v", c, err)
+			}
+			return fmt.Errorf("line %d / column %d: %v", lineNo, c, err)
+		}
+	}
+	return nil
+}
+
+// checkRowTypes checks the output rows made from one input line, so a line
+// that explodes into several rows is kept or left out as a whole.
+func (t *Transformation) checkRowTypes(lineNo int, rows []map[int]string) error {
+	for _, output := range rows {
+		if err := t.checkColumnTypes(lineNo, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportSkipped tells the TypeErrorLog about a row left out for breaking a
+// column type.
+func (t *Transformation) reportSkipped(err error) {
+	if t.TypeErrorLog != nil {
+		_, _ = fmt.Fprintf(t.TypeErrorLog, "Skipped row: %v\n", err)
+	}
+}
@@ -0,0 +1,217 @@
+package recipe
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_ColumnType(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		want       *ColumnType
+		wantColumn int
+		wantErr    string
+	}{
+		{
+			name:   "no annotation",
+			source: "3 <- 2",
+		},
+		{
+			name:   "int",
+			source: "3:int <- 2 -> onlyDigits",
+			want:   &ColumnType{Name: TypeInt},
+		},
+		{
+			name:   "type names ignore case",
+			source: "3:Decimal <- 2",
+			want:   &ColumnType{Name: TypeDecimal},
+		},
+		{
+			name:   "date with a layout",
+			source: "3:date(\"01/02/2006\") <- 2",
+			want:   &ColumnType{Name: TypeDate, Layout: "01/02/2006"},
+		},
+		{
+			name:   "date without a layout",
+			source: "3:date <- today()",
+			want:   &ColumnType{Name: TypeDate, Layout: DefaultDateLayout},
+		},
+		{
+			name:       "unknown type",
+			source:     "3:money <- 2",
+			wantColumn: 3,
+			wantErr:    "unknown column type \"money\", expected int, decimal, bool or date",
+		},
+		{
+			name:       "layout on a type that doesn't take one",
+			source:     "3:int(\"x\") <- 2",
+			wantColumn: 3,
+			wantErr:    "type int does not take a layout",
+		},
+		{
+			name:       "layout that isn't quoted",
+			source:     "3:date(2006) <- 2",
+			wantColumn: 8,
+			wantErr:    "expected a quoted layout for date but found [2006]",
+		},
+		{
+			name:       "missing type",
+			source:     "3: <- 2",
+			wantColumn: 3,
+			wantErr:    "expected a type after : but found [ ]",
+		},
+		{
+			name:       "annotated header",
+			source:     "!3:int <- 2",
+			wantColumn: 3,
+			wantErr:    "only columns can have a type annotation",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, err := Parse(strings.NewReader(tt.source))
+			if tt.wantErr != "" {
+				parseErr, ok := err.(*ParseError)
+				if !ok {
+					t.Fatalf("Parse() error = %#v, want a *ParseError", err)
+				}
+				if parseErr.Column != tt.wantColumn || parseErr.Message != tt.wantErr {
+					t.Errorf("Parse() error at column %d: %q, want column %d: %q", parseErr.Column, parseErr.Message, tt.wantColumn, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := transformation.Columns[3].Type; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() type = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnType_Check(t *testing.T) {
+	tests := []struct {
+		columnType ColumnType
+		value      string
+		wantErr    string
+	}{
+		{ColumnType{Name: TypeInt}, "-12", ""},
+		{ColumnType{Name: TypeInt}, "", ""},
+		{ColumnType{Name: TypeInt}, "1.5", "value \"1.5\" is not an int"},
+		{ColumnType{Name: TypeDecimal}, "1.5", ""},
+		{ColumnType{Name: TypeDecimal}, "1,5", "value \"1,5\" is not a decimal"},
+		{ColumnType{Name: TypeBool}, "TRUE", ""},
+		{ColumnType{Name: TypeBool}, "yes", "value \"yes\" is not a bool"},
+		{ColumnType{Name: TypeDate, Layout: "01/02/2006"}, "12/31/2021", ""},
+		{ColumnType{Name: TypeDate, Layout: "01/02/2006"}, "2021-12-31", "value \"2021-12-31\" is not a date like 01/02/2006"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.columnType.String()+" "+tt.value, func(t *testing.T) {
+			err := tt.columnType.Check(tt.value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransformation_Execute_ColumnTypes(t *testing.T) {
+	tests := []struct {
+		name           string
+		recipe         string
+		input          string
+		parseErrIsErr  bool
+		want           string
+		wantLog        string
+		wantSkipped    int
+		wantTypeErrors int
+		wantErr        string
+	}{
+		{
+			name:   "values match their types",
+			recipe: "1:int <- 1 -> onlyDigits\n2:date(\"Jan 2 2006\") <- 2 -> readDate(\"01/02/2006\") -> formatDate(\"Jan 2 2006\")\n",
+			input:  "a1,01/31/2021\n,12/01/2020\n",
+			want:   "1,Jan 31 2021\n,Dec 1 2020\n",
+		},
+		{
+			name:           "a value of the wrong type skips the row",
+			recipe:         "1 <- 1\n2:decimal <- 2\n",
+			input:          "a,1.5\nb,n/a\nc,2\n",
+			want:           "a,1.5\nc,2\n",
+			wantLog:        "Skipped row: line 2 / column 2: value \"n/a\" is not a decimal\n",
+			wantSkipped:    1,
+			wantTypeErrors: 1,
+		},
+		{
+			name:           "a skipped row leaves running totals and persistent variables alone",
+			recipe:         "@total = \"0\"\n@total <- add(@total, 2) -> trimZeros\n1:int <- 2\n2 <- runningSum(2) -> trimZeros\n3 <- @total\n4 <- prev(1) + counter(\"k\") + fillDown(3)\n",
+			input:          "a,1,x\nb,1.5,y\nc,2,\n",
+			want:           "1,1,1,1x\n2,3,3,a2x\n",
+			wantLog:        "Skipped row: line 2 / column 1: value \"1.5\" is not an int\n",
+			wantSkipped:    1,
+			wantTypeErrors: 1,
+		},
+		{
+			name:          "a value of the wrong type stops the bake when parse errors are errors",
+			recipe:        "1 <- 1\n2:decimal <- 2\n",
+			input:         "a,1.5\nb,n/a\n",
+			parseErrIsErr: true,
+			wantErr:       "line 2 / column 2: value \"n/a\" is not a decimal",
+		},
+		{
+			name:           "aggregate groups are checked",
+			recipe:         "1 <- 1\n2:int <- sum(2)\n",
+			input:          "a,1\na,1.5\nb,2\n",
+			want:           "b,2\n",
+			wantLog:        "Skipped row: column 2: value \"2.5\" is not an int\n",
+			wantTypeErrors: 1,
+		},
+		{
+			name:          "aggregate groups stop the bake when parse errors are errors",
+			recipe:        "1 <- 1\n2:int <- sum(2)\n",
+			input:         "a,1\na,1.5\n",
+			parseErrIsErr: true,
+			wantErr:       "column 2: value \"2.5\" is not an int",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, err := Parse(strings.NewReader(tt.recipe))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var b, log bytes.Buffer
+			transformation.TypeErrorLog = &log
+			result, err := transformation.Execute(csv.NewReader(strings.NewReader(tt.input)), csv.NewWriter(&b), false, -1, tt.parseErrIsErr)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Execute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Execute() = %q, want %q", b.String(), tt.want)
+			}
+			if log.String() != tt.wantLog {
+				t.Errorf("Execute() logged %q, want %q", log.String(), tt.wantLog)
+			}
+			if result.SkippedLines != tt.wantSkipped || result.TypeErrors != tt.wantTypeErrors {
+				t.Errorf("Execute() skipped %d lines with %d type errors, want %d with %d", result.SkippedLines, result.TypeErrors, tt.wantSkipped, tt.wantTypeErrors)
+			}
+		})
+	}
+}
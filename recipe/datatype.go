@@ -10,4 +10,7 @@ const (
 	Literal
 	Placeholder
 	Header
+	Parameter
+	Persistent
+	Explode
 )
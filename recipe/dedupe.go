@@ -0,0 +1,150 @@
+package recipe
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dstockto/csv-chef/extsort"
+)
+
+// Deduper is a RowWriter that drops output rows whose key has already been
+// seen. Rows are held back until Close is called, then the surviving rows are
+// written to the underlying writer in their original order. Rows are sorted
+// on disk rather than kept in memory, so files of any size can be
+// de-duplicated.
+type Deduper struct {
+	writer      RowWriter
+	key         *Expression
+	keepLast    bool
+	header      bool
+	maxInMemory int
+	sorter      *extsort.Sorter
+	seq         int
+	dropped     int
+}
+
+// NewDeduper returns a Deduper writing to writer. When keepLast is false the
+// first row with each key is kept, otherwise the last one is. If header is
+// true the first row written is passed through untouched. maxInMemory limits
+// how many rows are buffered before spilling to disk; zero uses the default.
+func NewDeduper(writer RowWriter, key *Expression, keepLast bool, header bool, maxInMemory int) *Deduper {
+	return &Deduper{
+		writer:      writer,
+		key:         key,
+		keepLast:    keepLast,
+		header:      header,
+		maxInMemory: maxInMemory,
+		sorter:      extsort.New(compareKeyThenSeq, maxInMemory, ""),
+	}
+}
+
+// sequence numbers are zero padded so that they sort as strings
+func formatSeq(seq int) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// records are [key, seq, row...]
+func compareKeyThenSeq(a, b []string) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
+}
+
+// records are [seq, row...]
+func compareSeq(a, b []string) bool {
+	return a[0] < b[0]
+}
+
+// Write records a row, or writes it straight through if it is the header.
+func (d *Deduper) Write(record []string) error {
+	if d.header {
+		d.header = false
+		return d.writer.Write(record)
+	}
+	d.seq++
+	key, err := d.key.Evaluate(record, d.seq)
+	if err != nil {
+		return fmt.Errorf("dedupe key: %v", err)
+	}
+	return d.sorter.Add(append([]string{key, formatSeq(d.seq)}, record...))
+}
+
+// Flush flushes the underlying writer. Rows held for de-duplication are not
+// written until Close.
+func (d *Deduper) Flush() {
+	d.writer.Flush()
+}
+
+// Dropped returns the number of duplicate rows that were dropped.
+func (d *Deduper) Dropped() int {
+	return d.dropped
+}
+
+// Discard drops the held rows without writing them and removes their
+// temporary files. It does nothing after Close, so it can be deferred to
+// clean up when the bake fails.
+func (d *Deduper) Discard() error {
+	return d.sorter.Discard()
+}
+
+// Close works out which rows survive and writes them to the underlying
+// writer in their original order.
+func (d *Deduper) Close() error {
+	byKey, err := d.sorter.Sort()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = byKey.Close() }()
+
+	survivors := extsort.New(compareSeq, d.maxInMemory, "")
+	defer func() { _ = survivors.Discard() }()
+	var kept []string
+	for {
+		record, err := byKey.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if kept != nil && kept[0] == record[0] {
+			d.dropped++
+			if d.keepLast {
+				kept = record
+			}
+			continue
+		}
+		if kept != nil {
+			if err := survivors.Add(kept[1:]); err != nil {
+				return err
+			}
+		}
+		kept = record
+	}
+	if kept != nil {
+		if err := survivors.Add(kept[1:]); err != nil {
+			return err
+		}
+	}
+
+	inOrder, err := survivors.Sort()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = inOrder.Close() }()
+	for {
+		record, err := inOrder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.writer.Write(record[1:]); err != nil {
+			return err
+		}
+	}
+	d.writer.Flush()
+	return nil
+}
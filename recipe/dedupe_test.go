@@ -0,0 +1,136 @@
+package recipe
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeduper(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		keepLast    bool
+		header      bool
+		maxInMemory int
+		input       string
+		want        string
+		wantDropped int
+	}{
+		{
+			name:        "keep first by column",
+			key:         "1",
+			input:       "1,a\n2,b\n1,c\n3,d\n2,e\n",
+			want:        "1,a\n2,b\n3,d\n",
+			wantDropped: 2,
+		},
+		{
+			name:        "keep last by column keeps original order of survivors",
+			key:         "1",
+			keepLast:    true,
+			input:       "1,a\n2,b\n1,c\n3,d\n2,e\n",
+			want:        "1,c\n3,d\n2,e\n",
+			wantDropped: 2,
+		},
+		{
+			name:        "key expression and header pass through",
+			key:         "2 -> trim -> lowercase",
+			header:      true,
+			input:       "id,email\n1,A@x.com\n2, a@x.com\n3,b@x.com\n",
+			want:        "id,email\n1,A@x.com\n3,b@x.com\n",
+			wantDropped: 1,
+		},
+		{
+			name:        "spills to disk",
+			key:         "1",
+			keepLast:    true,
+			maxInMemory: 1,
+			input:       "b,1\na,2\nb,3\nc,4\na,5\nb,6\n",
+			want:        "c,4\na,5\nb,6\n",
+			wantDropped: 3,
+		},
+		{
+			name:  "no rows",
+			key:   "1",
+			input: "",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewExpression(tt.key)
+			if err != nil {
+				t.Fatalf("NewExpression() unexpected error: %v", err)
+			}
+			var b bytes.Buffer
+			d := NewDeduper(csv.NewWriter(&b), key, tt.keepLast, tt.header, tt.maxInMemory)
+
+			rows, err := csv.NewReader(strings.NewReader(tt.input)).ReadAll()
+			if err != nil {
+				t.Fatalf("unexpected input error: %v", err)
+			}
+			for _, row := range rows {
+				if err := d.Write(row); err != nil {
+					t.Fatalf("Write() unexpected error: %v", err)
+				}
+			}
+			if err := d.Close(); err != nil {
+				t.Fatalf("Close() unexpected error: %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Deduper wrote %q, want %q", got, tt.want)
+			}
+			if d.Dropped() != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", d.Dropped(), tt.wantDropped)
+			}
+		})
+	}
+}
+
+// useTempDir points the system temporary directory, where spilled rows are
+// written, at a directory of the test's own and returns it.
+func useTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old, had := os.LookupEnv("TMPDIR")
+	if err := os.Setenv("TMPDIR", dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if had {
+			_ = os.Setenv("TMPDIR", old)
+		} else {
+			_ = os.Unsetenv("TMPDIR")
+		}
+	})
+	return dir
+}
+
+func TestDeduper_Discard(t *testing.T) {
+	dir := useTempDir(t)
+	key, err := NewExpression("1")
+	if err != nil {
+		t.Fatalf("NewExpression() error = %v", err)
+	}
+	var b bytes.Buffer
+	d := NewDeduper(csv.NewWriter(&b), key, false, false, 1)
+	for _, record := range [][]string{{"1"}, {"2"}, {"1"}} {
+		if err := d.Write(record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) == 0 {
+		t.Fatalf("expected rows to be spilled to %s", dir)
+	}
+	if err := d.Discard(); err != nil {
+		t.Fatalf("Discard() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Discard() left %d temporary files", len(entries))
+	}
+	if b.Len() != 0 {
+		t.Errorf("Discard() wrote %q", b.String())
+	}
+}
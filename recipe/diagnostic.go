@@ -0,0 +1,139 @@
+package recipe
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Severity is how serious a Diagnostic is. Errors stop a recipe from being
+// baked; warnings point out something that is probably a mistake.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is one problem found in a recipe. Line and Column are 1-based
+// and are 0 when the problem is with the recipe as a whole rather than one
+// line of it. Source is the text of the line.
+type Diagnostic struct {
+	Severity   Severity `json:"severity"`
+	Line       int      `json:"line,omitempty"`
+	Column     int      `json:"column,omitempty"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+	// Code identifies the lint check that found the problem. It is empty
+	// for parse and validation errors.
+	Code   string `json:"code,omitempty"`
+	Source string `json:"-"`
+}
+
+// Render describes the diagnostic with its location, the offending line and
+// a caret under the column. File names the recipe and may be empty.
+func (d Diagnostic) Render(file string) string {
+	var b strings.Builder
+	var location string
+	switch {
+	case file != "" && d.Line > 0:
+		location = fmt.Sprintf("%s:%d:%d: ", file, d.Line, d.Column)
+	case file != "":
+		location = file + ": "
+	case d.Line > 0:
+		location = fmt.Sprintf("line %d, column %d: ", d.Line, d.Column)
+	}
+	fmt.Fprintf(&b, "%s%s: %s", location, d.Severity, d.Message)
+	if d.Code != "" {
+		fmt.Fprintf(&b, " [%s]", d.Code)
+	}
+	b.WriteString("\n")
+	writeSourceCaret(&b, d.Source, d.Column)
+	if d.Suggestion != "" {
+		fmt.Fprintf(&b, "did you mean %s?\n", d.Suggestion)
+	}
+	return b.String()
+}
+
+// writeSourceCaret writes a line of source and a caret under column.
+func writeSourceCaret(b *strings.Builder, source string, column int) {
+	if source == "" {
+		return
+	}
+	source = strings.TrimRight(source, "\r")
+	fmt.Fprintf(b, "    %s\n", source)
+	// copy tabs from the source line so the caret lines up
+	var indent strings.Builder
+	for i, ch := range []rune(source) {
+		if i >= column-1 {
+			break
+		}
+		if ch == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	fmt.Fprintf(b, "    %s^\n", indent.String())
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// errorDiagnostic turns an error into a Diagnostic, keeping the position of
+// parse errors.
+func errorDiagnostic(err error) Diagnostic {
+	if parseErr, ok := err.(*ParseError); ok {
+		return Diagnostic{
+			Severity:   SeverityError,
+			Line:       parseErr.Line,
+			Column:     parseErr.Column,
+			Message:    parseErr.Message,
+			Suggestion: parseErr.Suggestion,
+			Source:     parseErr.Source,
+		}
+	}
+	return Diagnostic{Severity: SeverityError, Message: err.Error()}
+}
+
+// ParseAll parses a recipe like Parse, but instead of stopping at the first
+// line with an error it reports every one. The transformation holds every
+// line that parsed and remembers where each statement came from, so that
+// Validate can point at the lines it finds problems with.
+func ParseAll(source io.Reader) (*Transformation, []Diagnostic) {
+	m := &sourceMap{}
+	t, errs := parseLines(source, m, true)
+	t.source = m
+
+	var diagnostics []Diagnostic
+	for _, err := range errs {
+		diagnostics = append(diagnostics, errorDiagnostic(err))
+	}
+	return t, diagnostics
+}
+
+// diagnostic returns an error Diagnostic for the recipe assigned to output.
+// If step is not negative it points at that step of the pipe rather than the
+// start of the line. The position is only known when the transformation
+// came from ParseAll.
+func (t *Transformation) diagnostic(output Output, step int, format string, args ...interface{}) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Message: fmt.Sprintf(format, args...)}
+	statement, ok := t.source.find(output)
+	if !ok {
+		return d
+	}
+	position := statement.position
+	if step >= 0 && step < len(statement.operations) {
+		position = statement.operations[step]
+	}
+	d.Line, d.Column = position.Line, position.Column
+	d.Source = t.source.line(position.Line)
+	return d
+}
@@ -0,0 +1,143 @@
+package recipe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		wantColumns []int
+		want        []Diagnostic
+	}{
+		{
+			name:        "no problems",
+			source:      "1 <- 1\n2 <- 2\n",
+			wantColumns: []int{1, 2},
+		},
+		{
+			name:        "every bad line is reported",
+			source:      "1 <- 1 -> trimm\n2 <- 2\n3 <- 3 ->\n\n4 <- \"x\n",
+			wantColumns: []int{1, 2, 3, 4},
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 1, Column: 11, Message: "unrecognized function trimm", Suggestion: "trim", Source: "1 <- 1 -> trimm"},
+				{Severity: SeverityError, Line: 3, Column: 10, Message: "expected a column, literal, variable, parameter, function or ? but found [EOF]", Source: "3 <- 3 ->"},
+				{Severity: SeverityError, Line: 5, Column: 6, Message: "expected a column, literal, variable, parameter or function after <- but found [x]", Source: "4 <- \"x"},
+			},
+		},
+		{
+			name:        "duplicates",
+			source:      "1 <- 1\n1 <- 2\n$a <- 1\n$a <- 2\n",
+			wantColumns: []int{1},
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 2, Column: 1, Message: "column 1 already defined", Source: "1 <- 2"},
+				{Severity: SeverityError, Line: 4, Column: 1, Message: "variable $a already defined", Source: "$a <- 2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, got := ParseAll(strings.NewReader(tt.source))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAll() diagnostics = %+v, want %+v", got, tt.want)
+			}
+			if columns := sortedRecipeKeys(transformation.Columns); !reflect.DeepEqual(columns, tt.wantColumns) {
+				t.Errorf("ParseAll() columns = %v, want %v", columns, tt.wantColumns)
+			}
+		})
+	}
+}
+
+func TestTransformation_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []Diagnostic
+	}{
+		{
+			name:   "valid",
+			source: "1 <- 1\n!1 <- \"One\"\n",
+		},
+		{
+			name:   "no columns",
+			source: "$a <- 1\n",
+			want: []Diagnostic{
+				{Severity: SeverityError, Message: "no column recipes provided"},
+			},
+		},
+		{
+			name:   "every problem is reported",
+			source: "1 <- 1\n3 <- 3 -> sum -> trim\n5 <- 5\n!7 <- \"Seven\"\n$total <- 1 -> count\n",
+			want: []Diagnostic{
+				{Severity: SeverityError, Message: "missing column definition for column #2"},
+				{Severity: SeverityError, Line: 4, Column: 1, Message: "found header for column 7, but no recipe for column 7", Source: "!7 <- \"Seven\""},
+				{Severity: SeverityError, Line: 5, Column: 16, Message: "aggregate function count can only be used in column recipes, found in variable $total", Source: "$total <- 1 -> count"},
+				{Severity: SeverityError, Line: 2, Column: 11, Message: "aggregate function sum must be the last step of column 3", Source: "3 <- 3 -> sum -> trim"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, diagnostics := ParseAll(strings.NewReader(tt.source))
+			if len(diagnostics) > 0 {
+				t.Fatalf("ParseAll() diagnostics = %+v", diagnostics)
+			}
+			if got := transformation.Validate(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+
+			// ValidateRecipe reports the first error
+			err := transformation.ValidateRecipe()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("ValidateRecipe() error = %v, want nil", err)
+				}
+			} else if err == nil || err.Error() != tt.want[0].Message {
+				t.Errorf("ValidateRecipe() error = %v, want %v", err, tt.want[0].Message)
+			}
+		})
+	}
+}
+
+func TestDiagnostic_Render(t *testing.T) {
+	tests := []struct {
+		name       string
+		diagnostic Diagnostic
+		file       string
+		want       string
+	}{
+		{
+			name:       "positioned",
+			diagnostic: Diagnostic{Severity: SeverityError, Line: 2, Column: 6, Message: "unrecognized function trimm", Suggestion: "trim", Source: "1 <- trimm"},
+			file:       "clean.txt",
+			want:       "clean.txt:2:6: error: unrecognized function trimm\n    1 <- trimm\n         ^\ndid you mean trim?\n",
+		},
+		{
+			name:       "whole recipe",
+			diagnostic: Diagnostic{Severity: SeverityWarning, Message: "something odd"},
+			file:       "clean.txt",
+			want:       "clean.txt: warning: something odd\n",
+		},
+		{
+			name:       "lint check",
+			diagnostic: Diagnostic{Severity: SeverityWarning, Line: 1, Column: 1, Message: "variable $a is defined but never used", Code: "unused-variable", Source: "$a <- 1"},
+			file:       "clean.txt",
+			want:       "clean.txt:1:1: warning: variable $a is defined but never used [unused-variable]\n    $a <- 1\n    ^\n",
+		},
+		{
+			name:       "no file",
+			diagnostic: Diagnostic{Severity: SeverityError, Line: 1, Column: 1, Message: "oops"},
+			want:       "line 1, column 1: error: oops\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.diagnostic.Render(tt.file); got != tt.want {
+				t.Errorf("Render() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
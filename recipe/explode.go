@@ -0,0 +1,66 @@
+package recipe
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ElementVariable and ElementIndexVariable are set for column recipes while
+// an exploded row is written. They hold the current element and its 1-based
+// position.
+const (
+	ElementVariable      = "$element"
+	ElementIndexVariable = "$elementIndex"
+)
+
+// Explosion splits the value computed by Recipe on Delimiter and writes one
+// output row per element, so a single input row can become several output
+// rows.
+type Explosion struct {
+	Delimiter string
+	Recipe    Recipe
+}
+
+// AddExplode sets up the explode directive. A recipe may only have one.
+func (t *Transformation) AddExplode(delimiter string) error {
+	if t.Explode != nil {
+		return errors.New("explode already defined")
+	}
+	t.Explode = &Explosion{
+		Delimiter: delimiter,
+		Recipe:    Recipe{Output: Output{Type: Explode, Value: "explode"}},
+	}
+	return nil
+}
+
+// AddOperationToExplode appends an operation to the explode recipe.
+func (t *Transformation) AddOperationToExplode(operation Operation) {
+	if t.Explode == nil {
+		// safe to ignore: the error only occurs if already defined
+		_ = t.AddExplode(",")
+	}
+	t.Explode.Recipe.Pipe = append(t.Explode.Recipe.Pipe, operation)
+}
+
+// explodeRow returns the elements for the current row. Without an explode
+// directive there is a single element and no element variables are set.
+func (t *Transformation) explodeRow(context LineContext) ([]string, error) {
+	if t.Explode == nil {
+		return []string{""}, nil
+	}
+	value, err := t.processRecipe("explode", t.Explode.Recipe, context)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(value, t.Explode.Delimiter), nil
+}
+
+// setElement exposes an exploded element to the column recipes.
+func (t *Transformation) setElement(context LineContext, index int, element string) {
+	if t.Explode == nil {
+		return
+	}
+	context.Variables[ElementVariable] = element
+	context.Variables[ElementIndexVariable] = strconv.Itoa(index + 1)
+}
@@ -0,0 +1,38 @@
+package recipe
+
+// Expression is a recipe expression evaluated on its own against a single
+// row, for example the key used to sort or de-duplicate output rows. Column
+// references refer to the columns of the row it is given.
+type Expression struct {
+	Source string
+	recipe Recipe
+	t      *Transformation
+}
+
+// NewExpression parses source as the right-hand side of a recipe line.
+func NewExpression(source string) (*Expression, error) {
+	r, err := ParseExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	r.Output = Output{Type: Column, Value: source}
+	return &Expression{
+		Source: source,
+		recipe: r,
+		t:      NewTransformation(),
+	}, nil
+}
+
+// Evaluate returns the value of the expression for row. The lineNo is used by
+// lineno() and in error messages.
+func (e *Expression) Evaluate(row []string, lineNo int) (string, error) {
+	context := LineContext{
+		Variables: map[string]string{},
+		Columns:   make(map[int]string, len(row)),
+		LineNo:    lineNo,
+	}
+	for i, v := range row {
+		context.Columns[i+1] = v
+	}
+	return e.t.processRecipe("key", e.recipe, context)
+}
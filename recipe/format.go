@@ -0,0 +1,293 @@
+package recipe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// canonicalFuncNames gives the spelling fmt uses for functions whose
+// canonical name isn't all lower case. Function names are matched without
+// regard to case, so this only changes how a recipe looks.
+var canonicalFuncNames = map[string]string{
+	"countdistinct": "countDistinct",
+	"filldown":      "fillDown",
+	"firstchars":    "firstChars",
+	"formatdate":    "formatDate",
+	"formatdatef":   "formatDateF",
+	"ifempty":       "ifEmpty",
+	"isempty":       "isEmpty",
+	"isfuture":      "isFuture",
+	"ispast":        "isPast",
+	"lastchars":     "lastChars",
+	"numberformat":  "numberFormat",
+	"onlydigits":    "onlyDigits",
+	"padleft":       "padLeft",
+	"padright":      "padRight",
+	"readdate":      "readDate",
+	"readdatef":     "readDateF",
+	"regexreplace":  "regexReplace",
+	"removedigits":  "removeDigits",
+	"runningsum":    "runningSum",
+	"smartdate":     "smartDate",
+	"titlecase":     "titleCase",
+	"trimzeros":     "trimZeros",
+}
+
+// CanonicalFuncName returns the canonical spelling of a function name.
+func CanonicalFuncName(name string) string {
+	lower := strings.ToLower(name)
+	if canonical, ok := canonicalFuncNames[lower]; ok {
+		return canonical
+	}
+	return lower
+}
+
+func formatLiteral(value string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + escaper.Replace(value) + `"`
+}
+
+func formatArgument(a Argument) string {
+	switch a.Type {
+	case Literal:
+		return formatLiteral(a.Value)
+	case Column:
+		if n, err := strconv.Atoi(a.Value); err == nil {
+			return strconv.Itoa(n)
+		}
+	case Placeholder:
+		return "?"
+	}
+	return a.Value
+}
+
+func isJoinWithPlaceholder(o Operation) bool {
+	return strings.ToLower(o.Name) == "join" && len(o.Arguments) == 1 && o.Arguments[0].Type == Placeholder
+}
+
+// formatOperand writes a single step of a pipe the way it would be typed.
+func formatOperand(o Operation) string {
+	if strings.ToLower(o.Name) == "value" && len(o.Arguments) == 1 {
+		return formatArgument(o.Arguments[0])
+	}
+
+	name := CanonicalFuncName(o.Name)
+	placeholders := 0
+	for _, a := range o.Arguments {
+		if a.Type == Placeholder {
+			placeholders++
+		}
+	}
+
+	// a function given only its implicit placeholder arguments is written
+	// without parentheses
+	totalArgs := 0
+	for _, count := range allFuncs[strings.ToLower(o.Name)] {
+		totalArgs += count
+	}
+	if placeholders == len(o.Arguments) && len(o.Arguments) == totalArgs {
+		return name
+	}
+
+	args := o.Arguments
+	if placeholders == 1 && args[len(args)-1].Type == Placeholder {
+		// the parser adds a trailing placeholder when none is given
+		args = args[:len(args)-1]
+	}
+	formatted := make([]string, len(args))
+	for i, a := range args {
+		formatted[i] = formatArgument(a)
+	}
+	return name + "(" + strings.Join(formatted, ", ") + ")"
+}
+
+// FormatPipe writes the right-hand side of a recipe line in canonical form.
+// Joins created by + are written back as +.
+func FormatPipe(pipe []Operation) string {
+	var b strings.Builder
+	for i := 0; i < len(pipe); i++ {
+		o := pipe[i]
+		switch {
+		case i == 0:
+			b.WriteString(formatOperand(o))
+		case isJoinWithPlaceholder(o) && i+1 < len(pipe):
+			b.WriteString(" + ")
+			i++
+			b.WriteString(formatOperand(pipe[i]))
+		default:
+			b.WriteString(" -> ")
+			b.WriteString(formatOperand(o))
+		}
+	}
+	return b.String()
+}
+
+func formatComment(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	return " # " + comment
+}
+
+// FormatRecipe writes a recipe as a single canonical recipe line, including
+// its trailing comment.
+func FormatRecipe(r Recipe) string {
+	var target string
+	switch r.Output.Type {
+	case Header:
+		target = "!" + formatArgument(Argument{Type: Column, Value: r.Output.Value})
+	case Column:
+		target = formatArgument(Argument{Type: Column, Value: r.Output.Value})
+		if r.Type != nil {
+			target += ":" + r.Type.String()
+		}
+	default:
+		target = r.Output.Value
+	}
+	return target + " <- " + FormatPipe(r.Pipe) + formatComment(r.Comment)
+}
+
+// trailingComment returns the comment at the end of a line, if any. The
+// parser keeps comments for recipe lines but not for declarations.
+func trailingComment(line string) string {
+	s := NewScanner(strings.NewReader(line))
+	for {
+		tok, lit := s.Scan()
+		switch tok {
+		case EOF:
+			return ""
+		case COMMENT:
+			return lit
+		}
+	}
+}
+
+type formattedLine struct {
+	group    int
+	name     string
+	column   int
+	header   bool
+	order    int
+	comments []string
+	text     string
+}
+
+// Format order: parameter declarations, persistent initial values,
+// variables in the order they were defined, explode, and then each column
+// with its header line just before it.
+const (
+	groupParameters = iota
+	groupPersistentInit
+	groupVariables
+	groupExplode
+	groupColumns
+)
+
+// Format rewrites a recipe in canonical form: one statement per line with
+// single spaces around <-, -> and +, canonical function names, and headers
+// placed just before their columns. Comments on their own line stay with
+// the line that follows them; blank lines separate the groups of
+// statements. The recipe must parse.
+func Format(source io.Reader) (string, error) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(source); err != nil {
+		return "", err
+	}
+	src := buf.String()
+	if _, err := Parse(strings.NewReader(src)); err != nil {
+		return "", err
+	}
+
+	var lines []formattedLine
+	var comments []string
+	for i, l := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(l)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			comments = append(comments, strings.TrimSpace("# "+strings.TrimSpace(trimmed[1:])))
+			continue
+		}
+
+		t, err := Parse(strings.NewReader(l))
+		if err != nil {
+			return "", err
+		}
+		line := formattedLine{order: i, comments: comments}
+		comments = nil
+		switch {
+		case len(t.Columns) > 0:
+			for c, r := range t.Columns {
+				line.group, line.column, line.text = groupColumns, c, FormatRecipe(r)
+			}
+		case len(t.Headers) > 0:
+			for h, r := range t.Headers {
+				line.group, line.column, line.header, line.text = groupColumns, h, true, FormatRecipe(r)
+			}
+		case len(t.Variables) > 0:
+			for _, r := range t.Variables {
+				line.group, line.text = groupVariables, FormatRecipe(r)
+			}
+		case t.Explode != nil:
+			line.group = groupExplode
+			line.text = fmt.Sprintf("explode(%s) <- %s%s", formatLiteral(t.Explode.Delimiter), FormatPipe(t.Explode.Recipe.Pipe), formatComment(t.Explode.Recipe.Comment))
+		case len(t.PersistentInit) > 0:
+			for name, value := range t.PersistentInit {
+				line.group, line.name = groupPersistentInit, name
+				line.text = name + " = " + formatLiteral(value) + formatComment(trailingComment(l))
+			}
+		default:
+			for name, param := range t.Parameters {
+				line.group, line.name = groupParameters, name
+				line.text = name
+				if param.HasDefault {
+					line.text += " = " + formatLiteral(param.Default)
+				}
+				line.text += formatComment(trailingComment(l))
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		switch a.group {
+		case groupParameters, groupPersistentInit:
+			return a.name < b.name
+		case groupColumns:
+			if a.column != b.column {
+				return a.column < b.column
+			}
+			return a.header && !b.header
+		}
+		return a.order < b.order
+	})
+
+	var out strings.Builder
+	for i, line := range lines {
+		if i > 0 && line.group != lines[i-1].group {
+			out.WriteString("\n")
+		}
+		for _, comment := range line.comments {
+			out.WriteString(comment + "\n")
+		}
+		out.WriteString(line.text + "\n")
+	}
+	if len(comments) > 0 {
+		if len(lines) > 0 {
+			out.WriteString("\n")
+		}
+		for _, comment := range comments {
+			out.WriteString(comment + "\n")
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,131 @@
+package recipe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "spacing and function names",
+			source: "1<-  2->READDATE(\"01/02/2006\")  ->formatdate( \"Jan 2\" )+\" \"+3\n",
+			want:   "1 <- 2 -> readDate(\"01/02/2006\") -> formatDate(\"Jan 2\") + \" \" + 3\n",
+		},
+		{
+			name:   "headers next to their columns",
+			source: "2 <- 2\n1 <- 1\n!2 <- \"Two\"\n!1 <- \"One\"\n",
+			want:   "!1 <- \"One\"\n1 <- 1\n!2 <- \"Two\"\n2 <- 2\n",
+		},
+		{
+			name:   "comments are kept",
+			source: "# names\n  #  first\n1 <- 1 -> trim   #  first name  \n\n# the end\n",
+			want:   "# names\n# first\n1 <- 1 -> trim # first name\n\n# the end\n",
+		},
+		{
+			name:   "groups in order",
+			source: "1 <- $b\n$b <- %p\n@n = \"0\" # start\n%p = \"x\"\n$a <- 1\n@n <- @n -> add(\"1\")\nexplode(\";\") <- 1\n%q\n",
+			want:   "%p = \"x\"\n%q\n\n@n = \"0\" # start\n\n$b <- %p\n$a <- 1\n@n <- @n -> add(\"1\")\n\nexplode(\";\") <- 1\n\n1 <- $b\n",
+		},
+		{
+			name:   "arguments",
+			source: "1 <- lineno -> today() -> uppercase(?) -> add(?, \"1\") -> add(\"1\", ?) -> ifempty(\"x\", ?, ?) -> ? -> replace(\"\\\"\", \"\\\\\", ?)\n",
+			want:   "1 <- lineno -> today() -> uppercase -> add(?, \"1\") -> add(\"1\") -> ifEmpty(\"x\", ?, ?) -> ? -> replace(\"\\\"\", \"\\\\\")\n",
+		},
+		{
+			name:   "type annotations",
+			source: "2:DATE(\"2006-01-02\") <- 2\n1:date( \"01/02/2006\" ) <- 1\n3:Int <- 3\n",
+			want:   "1:date(\"01/02/2006\") <- 1\n2:date <- 2\n3:int <- 3\n",
+		},
+		{
+			name:    "invalid recipe",
+			source:  "1 <- 1 -> nosuchfunction\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(strings.NewReader(tt.source))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Format() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// normalizeNames lower-cases function names, which fmt is allowed to change.
+func normalizeNames(t *Transformation) {
+	normalize := func(r Recipe) Recipe {
+		pipe := make([]Operation, len(r.Pipe))
+		for i, o := range r.Pipe {
+			o.Name = strings.ToLower(o.Name)
+			pipe[i] = o
+		}
+		r.Pipe = pipe
+		return r
+	}
+	for k, r := range t.Columns {
+		t.Columns[k] = normalize(r)
+	}
+	for k, r := range t.Headers {
+		t.Headers[k] = normalize(r)
+	}
+	for k, r := range t.Variables {
+		t.Variables[k] = normalize(r)
+	}
+	if t.Explode != nil {
+		t.Explode.Recipe = normalize(t.Explode.Recipe)
+	}
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	sources := []string{
+		"1 <- 1\n2 <- 2 -> uppercase\n!1 <- \"ID\"\n",
+		"$full <- 1 + \" \" + 2 # the name\n1 <- $full -> titlecase\n2 <- 3 -> READDATE(\"01/02/2006\") -> formatDate(\"Jan 2\")\n",
+		"1 <- join(2) -> join(\"-\") -> add(?, 3) -> numberformat(\"2\")\n2 <- 1 -> ? -> lineno\n",
+		"%client = \"acme\"\n%cutoff\n1 <- %client + %cutoff\n",
+		"@seen = \"0\"\n@seen <- @seen -> add(\"1\")\n1 <- @seen -> prev\n",
+		"explode(\"; \") <- 2\n1 <- $element + \":\" + $elementIndex\n",
+		"1 <- 1\n2 <- 2 -> sum\n3 <- 3 -> countDistinct\n",
+		"1 <- \"say \\\"hi\\\" \\\\ bye\" -> regexreplace(\"[0-9]+\", \"#\", ?)\n",
+		"1 <- 1 -> ifEmpty(\"none\", ?, ?) -> change(\"a\", \"b\") -> substring(\"1\", \"2\", ?)\n",
+	}
+	for _, source := range sources {
+		t.Run(source, func(t *testing.T) {
+			formatted, err := Format(strings.NewReader(source))
+			if err != nil {
+				t.Fatalf("Format() unexpected error: %v", err)
+			}
+			original, err := Parse(strings.NewReader(source))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			reparsed, err := Parse(strings.NewReader(formatted))
+			if err != nil {
+				t.Fatalf("Parse() of formatted recipe unexpected error: %v\n%s", err, formatted)
+			}
+			normalizeNames(original)
+			normalizeNames(reparsed)
+			if !reflect.DeepEqual(original, reparsed) {
+				t.Errorf("formatted recipe parses differently:\n%s\noriginal %+v\nreparsed %+v", formatted, original, reparsed)
+			}
+
+			again, err := Format(strings.NewReader(formatted))
+			if err != nil {
+				t.Fatalf("Format() of formatted recipe unexpected error: %v", err)
+			}
+			if again != formatted {
+				t.Errorf("Format() is not stable:\n%s\nthen\n%s", formatted, again)
+			}
+		})
+	}
+}
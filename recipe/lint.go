@@ -0,0 +1,449 @@
+package recipe
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LintCheck describes one of the semantic checks made by Lint. Each check
+// has a code that identifies it when changing its severity or turning it
+// off.
+type LintCheck struct {
+	Code        string
+	Severity    Severity
+	Description string
+}
+
+// LintChecks lists every check made by Lint with its default severity.
+var LintChecks = []LintCheck{
+	{"undefined-variable", SeverityError, "a variable is used but never defined"},
+	{"use-before-definition", SeverityError, "a variable is used by a variable defined before it"},
+	{"unused-variable", SeverityWarning, "a variable is defined but never used"},
+	{"invalid-argument", SeverityError, "a literal argument will fail or be wrong at runtime"},
+	{"arity", SeverityError, "a function is given more arguments than it uses"},
+	{"missing-argument", SeverityWarning, "a function is given fewer arguments than it takes"},
+	{"header-column", SeverityWarning, "a header uses an input column its data column does not"},
+}
+
+// LookupLintCheck returns the check with the given code.
+func LookupLintCheck(code string) (LintCheck, bool) {
+	for _, c := range LintChecks {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return LintCheck{}, false
+}
+
+// LintOptions controls which checks Lint makes. Checks listed in Disabled
+// are skipped and Severities overrides the default severity of a check.
+// Both are keyed by check code.
+type LintOptions struct {
+	Disabled   map[string]bool
+	Severities map[string]Severity
+}
+
+// Lint looks for recipe lines that parse and validate but are probably
+// mistakes, such as a variable that is never used or a literal argument that
+// will fail on every row. A diagnostic can also be suppressed with a
+// `# lint:ignore code` comment on the line it is reported for; without a
+// code every check is ignored on that line.
+func (t *Transformation) Lint(options LintOptions) []Diagnostic {
+	checks := map[string]func() []Diagnostic{
+		"undefined-variable":    t.lintUndefinedVariables,
+		"use-before-definition": t.lintVariableOrder,
+		"unused-variable":       t.lintUnusedVariables,
+		"invalid-argument":      t.lintArguments,
+		"arity":                 t.lintArity,
+		"missing-argument":      t.lintMissingArguments,
+		"header-column":         t.lintHeaderColumns,
+	}
+
+	var diagnostics []Diagnostic
+	for _, check := range LintChecks {
+		if options.Disabled[check.Code] {
+			continue
+		}
+		severity := check.Severity
+		if s, ok := options.Severities[check.Code]; ok {
+			severity = s
+		}
+		for _, d := range checks[check.Code]() {
+			if t.lintIgnored(d.Line, check.Code) {
+				continue
+			}
+			d.Severity = severity
+			d.Code = check.Code
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	// report in the order of the recipe; problems without a line come first
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics
+}
+
+// lintIgnored reports whether a lint:ignore comment on the line turns off
+// the check.
+func (t *Transformation) lintIgnored(lineNo int, code string) bool {
+	comment := trailingComment(t.source.line(lineNo))
+	fields := strings.Fields(comment)
+	for i, f := range fields {
+		if f != "lint:ignore" {
+			continue
+		}
+		if i+1 == len(fields) {
+			return true
+		}
+		for _, c := range strings.Split(fields[i+1], ",") {
+			if c == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lintRecipes returns every recipe in the order it is evaluated: variables,
+// then the explode recipe, columns and headers.
+func (t *Transformation) lintRecipes() []Recipe {
+	var recipes []Recipe
+	for _, name := range t.VariableOrder {
+		recipes = append(recipes, t.Variables[name])
+	}
+	if t.Explode != nil {
+		recipes = append(recipes, t.Explode.Recipe)
+	}
+	for _, c := range sortedRecipeKeys(t.Columns) {
+		recipes = append(recipes, t.Columns[c])
+	}
+	for _, h := range sortedRecipeKeys(t.Headers) {
+		recipes = append(recipes, t.Headers[h])
+	}
+	return recipes
+}
+
+func (t *Transformation) lintUndefinedVariables() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, r := range t.lintRecipes() {
+		for i, o := range r.Pipe {
+			for _, a := range o.Arguments {
+				switch a.Type {
+				case Variable:
+					if a.Value == ElementVariable || a.Value == ElementIndexVariable {
+						if t.Explode == nil {
+							diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "%s is only set when the recipe has an explode directive", a.Value))
+						}
+						continue
+					}
+					if _, ok := t.Variables[a.Value]; !ok {
+						diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "variable %s is used but never defined", a.Value))
+					}
+				case Persistent:
+					_, defined := t.Variables[a.Value]
+					_, initialized := t.PersistentInit[a.Value]
+					if !defined && !initialized {
+						diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "persistent variable %s is used but never defined", a.Value))
+					}
+				}
+			}
+		}
+	}
+	return diagnostics
+}
+
+// lintVariableOrder finds variables that use a variable which is not
+// evaluated until after them. Persistent variables keep their value from
+// the previous row, so they may be used anywhere.
+func (t *Transformation) lintVariableOrder() []Diagnostic {
+	order := make(map[string]int, len(t.VariableOrder))
+	for i, name := range t.VariableOrder {
+		order[name] = i
+	}
+
+	var diagnostics []Diagnostic
+	for position, name := range t.VariableOrder {
+		v := t.Variables[name]
+		for i, o := range v.Pipe {
+			for _, a := range o.Arguments {
+				if a.Type != Variable {
+					continue
+				}
+				used, ok := order[a.Value]
+				if !ok || used < position {
+					continue
+				}
+				if a.Value == name {
+					diagnostics = append(diagnostics, t.diagnostic(v.Output, i, "variable %s uses itself", name))
+				} else {
+					diagnostics = append(diagnostics, t.diagnostic(v.Output, i, "variable %s uses %s before it is defined", name, a.Value))
+				}
+			}
+		}
+	}
+	return diagnostics
+}
+
+func (t *Transformation) lintUnusedVariables() []Diagnostic {
+	used := map[string]bool{}
+	for _, r := range t.lintRecipes() {
+		for _, o := range r.Pipe {
+			for _, a := range o.Arguments {
+				if a.Type == Variable {
+					used[a.Value] = true
+				}
+			}
+		}
+	}
+
+	var diagnostics []Diagnostic
+	for _, name := range t.VariableOrder {
+		v := t.Variables[name]
+		if v.Output.Type == Variable && !used[name] {
+			diagnostics = append(diagnostics, t.diagnostic(v.Output, -1, "variable %s is defined but never used", name))
+		}
+	}
+	return diagnostics
+}
+
+// argumentCheck validates a literal argument, returning a description of
+// the problem or "" if there isn't one.
+type argumentCheck func(value string) string
+
+// argumentChecks holds the checks for the literal arguments of each
+// function, keyed by argument position.
+var argumentChecks = map[string]map[int]argumentCheck{
+	"add":          {0: isNumber, 1: isNumber},
+	"subtract":     {0: isNumber, 1: isNumber},
+	"multiply":     {0: isNumber, 1: isNumber},
+	"divide":       {0: isNumber, 1: isNonZeroNumber},
+	"power":        {0: isNumber, 1: isNumber},
+	"mod":          {0: isInteger, 1: isNonZeroInteger},
+	"numberformat": {0: isInteger},
+	"firstchars":   {0: isCount},
+	"lastchars":    {0: isCount},
+	"repeat":       {0: isCount},
+	"nth":          {0: isNotEmpty, 1: isPosition},
+	"padleft":      {0: isCount, 1: isNotEmpty},
+	"padright":     {0: isCount, 1: isNotEmpty},
+	"substring":    {0: isPosition, 1: isCount},
+	"regexreplace": {0: isRegexp},
+	"formatdate":   {0: isDateLayout},
+	"formatdatef":  {0: isDateLayout},
+	"readdate":     {0: isDateLayout},
+	"readdatef":    {0: isDateLayout},
+}
+
+func isNumber(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return "is not a number"
+	}
+	return ""
+}
+
+func isNonZeroNumber(value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "is not a number"
+	}
+	if f == 0 {
+		return "is zero"
+	}
+	return ""
+}
+
+func isInteger(value string) string {
+	if _, err := strconv.Atoi(value); err != nil {
+		return "is not an integer"
+	}
+	return ""
+}
+
+func isNonZeroInteger(value string) string {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "is not an integer"
+	}
+	if n == 0 {
+		return "is zero"
+	}
+	return ""
+}
+
+func isCount(value string) string {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "is not an integer"
+	}
+	if n < 0 {
+		return "is negative"
+	}
+	return ""
+}
+
+func isPosition(value string) string {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "is not an integer"
+	}
+	if n < 1 {
+		return "must be 1 or more"
+	}
+	return ""
+}
+
+func isNotEmpty(value string) string {
+	if value == "" {
+		return "is empty"
+	}
+	return ""
+}
+
+func isRegexp(value string) string {
+	if _, err := regexp.Compile(value); err != nil {
+		return fmt.Sprintf("is not a valid regular expression: %v", err)
+	}
+	return ""
+}
+
+// isDateLayout checks for a layout without any of the fields of Go's
+// reference time, such as "YYYY-MM-DD", which formats any date to itself.
+func isDateLayout(value string) string {
+	sample := time.Date(2017, time.November, 23, 9, 8, 7, 0, time.UTC)
+	if sample.Format(value) == value {
+		return "has no date or time fields; layouts are written using the reference time, e.g. 2006-01-02"
+	}
+	return ""
+}
+
+func (t *Transformation) lintArguments() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, r := range t.lintRecipes() {
+		for i, o := range r.Pipe {
+			checks := argumentChecks[strings.ToLower(o.Name)]
+			for position, a := range o.Arguments {
+				check, ok := checks[position]
+				if !ok || a.Type != Literal {
+					continue
+				}
+				if problem := check(a.Value); problem != "" {
+					diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "argument %d to %s (%q) %s", position+1, o.Name, a.Value, problem))
+				}
+			}
+		}
+	}
+	return diagnostics
+}
+
+// givenArguments returns the number of arguments a function uses and the
+// number it was given, not counting the placeholder the parser adds to the
+// end of every argument list when one isn't given.
+func givenArguments(o Operation) (int, int, bool) {
+	funcArgs, ok := allFuncs[strings.ToLower(o.Name)]
+	if !ok {
+		return 0, 0, false
+	}
+	want := 0
+	for _, count := range funcArgs {
+		want += count
+	}
+	given := len(o.Arguments)
+	for given > want && o.Arguments[given-1].Type == Placeholder {
+		given--
+	}
+	return want, given, true
+}
+
+func (t *Transformation) lintArity() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, r := range t.lintRecipes() {
+		for i, o := range r.Pipe {
+			want, given, ok := givenArguments(o)
+			if ok && given > want {
+				diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "%s takes %d argument(s) but is given %d; the rest are ignored", o.Name, want, given))
+			}
+		}
+	}
+	return diagnostics
+}
+
+func (t *Transformation) lintMissingArguments() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, r := range t.lintRecipes() {
+		for i, o := range r.Pipe {
+			want, given, ok := givenArguments(o)
+			if !ok || given >= want {
+				continue
+			}
+			if t.source.implicitPlaceholder(r.Output, i) {
+				diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "%s takes %d argument(s) but is given %d plus the implicit ?; the rest are filled with ?", o.Name, want, given-1))
+			} else {
+				diagnostics = append(diagnostics, t.diagnostic(r.Output, i, "%s takes %d argument(s) but is given %d; the rest are filled with ?", o.Name, want, given))
+			}
+		}
+	}
+	return diagnostics
+}
+
+// lintHeaderColumns finds header recipes that use an input column the data
+// column under them doesn't, directly or through a variable. That is usually
+// a header taken from the wrong input column.
+func (t *Transformation) lintHeaderColumns() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, h := range sortedRecipeKeys(t.Headers) {
+		column, ok := t.Columns[h]
+		if !ok {
+			continue
+		}
+		header := t.Headers[h]
+		dataColumns := t.inputColumns(column, map[string]bool{})
+		for i, o := range header.Pipe {
+			for _, a := range o.Arguments {
+				if a.Type != Column || dataColumns[a.Value] {
+					continue
+				}
+				diagnostics = append(diagnostics, t.diagnostic(header.Output, i, "header for column %d uses input column %s, but column %d does not", h, a.Value, h))
+			}
+		}
+	}
+	return diagnostics
+}
+
+// inputColumns returns the input columns a recipe uses, including through
+// the variables it uses.
+func (t *Transformation) inputColumns(r Recipe, seen map[string]bool) map[string]bool {
+	columns := map[string]bool{}
+	for _, o := range r.Pipe {
+		for _, a := range o.Arguments {
+			switch a.Type {
+			case Column:
+				columns[a.Value] = true
+			case Variable, Persistent:
+				if (a.Value == ElementVariable || a.Value == ElementIndexVariable) && t.Explode != nil {
+					for c := range t.inputColumns(t.Explode.Recipe, seen) {
+						columns[c] = true
+					}
+					continue
+				}
+				v, ok := t.Variables[a.Value]
+				if !ok || seen[a.Value] {
+					continue
+				}
+				seen[a.Value] = true
+				for c := range t.inputColumns(v, seen) {
+					columns[c] = true
+				}
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,115 @@
+package recipe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformation_Lint(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		options LintOptions
+		want    []Diagnostic
+	}{
+		{
+			name:   "clean recipe",
+			source: "$name <- 1 + \" \" + 2\n1 <- $name -> firstChars(\"3\")\n2 <- 3 -> readDate(\"01/02/2006\")\n!1 <- 1\n",
+		},
+		{
+			name:   "undefined variables",
+			source: "1 <- $missing\n2 <- @total\n3 <- $element\n",
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 1, Column: 6, Message: "variable $missing is used but never defined", Code: "undefined-variable", Source: "1 <- $missing"},
+				{Severity: SeverityError, Line: 2, Column: 6, Message: "persistent variable @total is used but never defined", Code: "undefined-variable", Source: "2 <- @total"},
+				{Severity: SeverityError, Line: 3, Column: 6, Message: "$element is only set when the recipe has an explode directive", Code: "undefined-variable", Source: "3 <- $element"},
+			},
+		},
+		{
+			name:   "initialized persistent variables and exploded elements are defined",
+			source: "@total = \"0\"\nexplode(\";\") <- 1\n1 <- @total\n2 <- $element\n",
+		},
+		{
+			name:   "variables used before they are defined",
+			source: "$a <- $b\n$b <- $b\n@sum <- @sum -> add(1, ?)\n1 <- $a + @sum\n",
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 1, Column: 7, Message: "variable $a uses $b before it is defined", Code: "use-before-definition", Source: "$a <- $b"},
+				{Severity: SeverityError, Line: 2, Column: 7, Message: "variable $b uses itself", Code: "use-before-definition", Source: "$b <- $b"},
+			},
+		},
+		{
+			name:   "unused variables",
+			source: "$used <- 1\n$unused <- 2\n1 <- $used\n",
+			want: []Diagnostic{
+				{Severity: SeverityWarning, Line: 2, Column: 1, Message: "variable $unused is defined but never used", Code: "unused-variable", Source: "$unused <- 2"},
+			},
+		},
+		{
+			name:   "literal arguments that fail at runtime",
+			source: "1 <- 1 -> firstChars(\"three\") -> repeat(\"-1\")\n2 <- 2 -> regexReplace(\"[a-\", \"\")\n3 <- 3 -> formatDate(\"YYYY-MM-DD\") -> divide(?, \"0\")\n4 <- 4 -> nth(\"\", \"0\")\n",
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 1, Column: 11, Message: "argument 1 to firstChars (\"three\") is not an integer", Code: "invalid-argument", Source: "1 <- 1 -> firstChars(\"three\") -> repeat(\"-1\")"},
+				{Severity: SeverityError, Line: 1, Column: 34, Message: "argument 1 to repeat (\"-1\") is negative", Code: "invalid-argument", Source: "1 <- 1 -> firstChars(\"three\") -> repeat(\"-1\")"},
+				{Severity: SeverityError, Line: 2, Column: 11, Message: "argument 1 to regexReplace (\"[a-\") is not a valid regular expression: error parsing regexp: missing closing ]: `[a-`", Code: "invalid-argument", Source: "2 <- 2 -> regexReplace(\"[a-\", \"\")"},
+				{Severity: SeverityError, Line: 3, Column: 11, Message: "argument 1 to formatDate (\"YYYY-MM-DD\") has no date or time fields; layouts are written using the reference time, e.g. 2006-01-02", Code: "invalid-argument", Source: "3 <- 3 -> formatDate(\"YYYY-MM-DD\") -> divide(?, \"0\")"},
+				{Severity: SeverityError, Line: 3, Column: 39, Message: "argument 2 to divide (\"0\") is zero", Code: "invalid-argument", Source: "3 <- 3 -> formatDate(\"YYYY-MM-DD\") -> divide(?, \"0\")"},
+				{Severity: SeverityError, Line: 4, Column: 11, Message: "argument 1 to nth (\"\") is empty", Code: "invalid-argument", Source: "4 <- 4 -> nth(\"\", \"0\")"},
+				{Severity: SeverityError, Line: 4, Column: 11, Message: "argument 2 to nth (\"0\") must be 1 or more", Code: "invalid-argument", Source: "4 <- 4 -> nth(\"\", \"0\")"},
+			},
+		},
+		{
+			name:   "argument counts",
+			source: "1 <- add(1, 2, 3)\n2 <- 2 -> replace(\"a\")\n3 <- today()\n4 <- firstChars(\"2\", 4)\n5 <- 5 -> padLeft(\"5\")\n6 <- 6 -> replace(?, \"a\")\n",
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 1, Column: 6, Message: "add takes 2 argument(s) but is given 3; the rest are ignored", Code: "arity", Source: "1 <- add(1, 2, 3)"},
+				{Severity: SeverityWarning, Line: 2, Column: 11, Message: "replace takes 3 argument(s) but is given 1 plus the implicit ?; the rest are filled with ?", Code: "missing-argument", Source: "2 <- 2 -> replace(\"a\")"},
+				{Severity: SeverityWarning, Line: 5, Column: 11, Message: "padLeft takes 3 argument(s) but is given 1 plus the implicit ?; the rest are filled with ?", Code: "missing-argument", Source: "5 <- 5 -> padLeft(\"5\")"},
+				{Severity: SeverityWarning, Line: 6, Column: 11, Message: "replace takes 3 argument(s) but is given 2; the rest are filled with ?", Code: "missing-argument", Source: "6 <- 6 -> replace(?, \"a\")"},
+			},
+		},
+		{
+			name:   "header from a column the data doesn't use",
+			source: "$first <- 2\nexplode(\",\") <- 4\n1 <- 1\n2 <- $first\n3 <- $element\n!1 <- 2\n!2 <- 2\n!3 <- 4\n",
+			want: []Diagnostic{
+				{Severity: SeverityWarning, Line: 6, Column: 7, Message: "header for column 1 uses input column 2, but column 1 does not", Code: "header-column", Source: "!1 <- 2"},
+			},
+		},
+		{
+			name:    "checks can be disabled or change severity",
+			source:  "$unused <- 1\n1 <- $missing\n",
+			options: LintOptions{Disabled: map[string]bool{"undefined-variable": true}, Severities: map[string]Severity{"unused-variable": SeverityError}},
+			want: []Diagnostic{
+				{Severity: SeverityError, Line: 1, Column: 1, Message: "variable $unused is defined but never used", Code: "unused-variable", Source: "$unused <- 1"},
+			},
+		},
+		{
+			name:   "lint:ignore comments",
+			source: "$a <- 1 # lint:ignore unused-variable\n$b <- 2 # lint:ignore arity,unused-variable\n$c <- 3 # lint:ignore arity\n1 <- $missing # lint:ignore\n",
+			want: []Diagnostic{
+				{Severity: SeverityWarning, Line: 3, Column: 1, Message: "variable $c is defined but never used", Code: "unused-variable", Source: "$c <- 3 # lint:ignore arity"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, diagnostics := ParseAll(strings.NewReader(tt.source))
+			if len(diagnostics) > 0 {
+				t.Fatalf("ParseAll() diagnostics = %+v", diagnostics)
+			}
+			if got := transformation.Lint(tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupLintCheck(t *testing.T) {
+	check, ok := LookupLintCheck("unused-variable")
+	if !ok || check.Severity != SeverityWarning {
+		t.Errorf("LookupLintCheck(unused-variable) = %+v, %v", check, ok)
+	}
+	if _, ok := LookupLintCheck("no-such-check"); ok {
+		t.Errorf("LookupLintCheck(no-such-check) found a check")
+	}
+}
@@ -0,0 +1,102 @@
+package recipe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParameterEnvPrefix is prepended to the upper-cased parameter name (without
+// the leading %) to find a parameter value in the environment. For example,
+// %client is read from CSVCHEF_PARAM_CLIENT.
+const ParameterEnvPrefix = "CSVCHEF_PARAM_"
+
+// ParameterSpec describes a named value supplied when a recipe is run
+// rather than hard-coded as a literal. Parameters are referenced as %name and
+// may be declared with a default value using `%name = "value"`.
+type ParameterSpec struct {
+	Name       string
+	Default    string
+	HasDefault bool
+	Declared   bool
+}
+
+// DeclareParameter records an explicit parameter declaration. Declaring the
+// same parameter twice is an error.
+func (t *Transformation) DeclareParameter(name string, defaultValue string, hasDefault bool) error {
+	if t.Parameters == nil {
+		t.Parameters = make(map[string]ParameterSpec)
+	}
+	param, ok := t.Parameters[name]
+	if ok && param.Declared {
+		return fmt.Errorf("parameter %s already defined", name)
+	}
+	t.Parameters[name] = ParameterSpec{
+		Name:       name,
+		Default:    defaultValue,
+		HasDefault: hasDefault,
+		Declared:   true,
+	}
+	return nil
+}
+
+// AddParameterReference records that a parameter is used by the recipe. A
+// parameter that is referenced but never declared is required.
+func (t *Transformation) AddParameterReference(name string) {
+	if t.Parameters == nil {
+		t.Parameters = make(map[string]ParameterSpec)
+	}
+	if _, ok := t.Parameters[name]; ok {
+		return
+	}
+	t.Parameters[name] = ParameterSpec{Name: name}
+}
+
+func (t *Transformation) addParameterReferences(operation Operation) {
+	for _, arg := range operation.Arguments {
+		if arg.Type == Parameter {
+			t.AddParameterReference(arg.Value)
+		}
+	}
+}
+
+// BindParameters resolves a value for every parameter in the recipe. Values
+// are taken from values first (keys may be given with or without the leading
+// %), then from the environment via lookupEnv, and finally from the declared
+// default. Any parameter left without a value is reported in the error.
+// Either values or lookupEnv may be nil.
+func (t *Transformation) BindParameters(values map[string]string, lookupEnv func(string) (string, bool)) error {
+	bound := make(map[string]string)
+	var missing []string
+
+	for name, param := range t.Parameters {
+		bare := strings.TrimPrefix(name, "%")
+		if value, ok := values[bare]; ok {
+			bound[name] = value
+			continue
+		}
+		if value, ok := values[name]; ok {
+			bound[name] = value
+			continue
+		}
+		if lookupEnv != nil {
+			if value, ok := lookupEnv(ParameterEnvPrefix + strings.ToUpper(bare)); ok {
+				bound[name] = value
+				continue
+			}
+		}
+		if param.HasDefault {
+			bound[name] = param.Default
+			continue
+		}
+		missing = append(missing, name)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing value for required parameter(s): %s", strings.Join(missing, ", "))
+	}
+
+	t.ParameterValues = bound
+	return nil
+}
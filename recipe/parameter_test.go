@@ -0,0 +1,78 @@
+package recipe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformation_BindParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  string
+		values  map[string]string
+		env     map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:   "set value wins over environment and default",
+			recipe: "%client = \"ACME\"\n1 <- %client\n",
+			values: map[string]string{"client": "Globex"},
+			env:    map[string]string{"CSVCHEF_PARAM_CLIENT": "Initech"},
+			want:   map[string]string{"%client": "Globex"},
+		},
+		{
+			name:   "set value may include the leading percent",
+			recipe: "1 <- %client\n",
+			values: map[string]string{"%client": "Globex"},
+			want:   map[string]string{"%client": "Globex"},
+		},
+		{
+			name:   "environment wins over default",
+			recipe: "%client = \"ACME\"\n1 <- %client\n",
+			env:    map[string]string{"CSVCHEF_PARAM_CLIENT": "Initech"},
+			want:   map[string]string{"%client": "Initech"},
+		},
+		{
+			name:   "default is used when nothing else is provided",
+			recipe: "%client = \"ACME\"\n1 <- %client\n",
+			want:   map[string]string{"%client": "ACME"},
+		},
+		{
+			name:   "empty default is still a default",
+			recipe: "%suffix = \"\"\n1 <- 1 + %suffix\n",
+			want:   map[string]string{"%suffix": ""},
+		},
+		{
+			name:    "all missing required parameters are reported",
+			recipe:  "%cutoff\n1 <- %client + %cutoff\n",
+			wantErr: "missing value for required parameter(s): %client, %cutoff",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, err := Parse(strings.NewReader(tt.recipe))
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			lookupEnv := func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}
+			err = transformation.BindParameters(tt.values, lookupEnv)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("BindParameters() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BindParameters() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(transformation.ParameterValues, tt.want) {
+				t.Errorf("BindParameters() = %v, want %v", transformation.ParameterValues, tt.want)
+			}
+		})
+	}
+}
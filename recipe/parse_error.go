@@ -0,0 +1,119 @@
+package recipe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseError is a problem found while parsing a recipe. Line and Column are
+// 1-based and point at the start of the offending token. Source is the text
+// of the offending line. File is not known to the parser; callers that read
+// the recipe from a file can set it so Render names the file.
+type ParseError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+	Source  string
+	// Suggestion is the closest known function name when Message is about
+	// an unrecognized function.
+	Suggestion string
+}
+
+func (e *ParseError) Error() string {
+	message := fmt.Sprintf("error - line %d: %s", e.Line, e.Message)
+	if e.Suggestion != "" {
+		message += fmt.Sprintf(" (did you mean %s?)", e.Suggestion)
+	}
+	return message
+}
+
+// Render describes the error the way a compiler would: its location and
+// message, the offending source line and a caret under the column.
+func (e *ParseError) Render() string {
+	var b strings.Builder
+	if e.File != "" {
+		fmt.Fprintf(&b, "%s:%d:%d: %s\n", e.File, e.Line, e.Column, e.Message)
+	} else {
+		fmt.Fprintf(&b, "line %d, column %d: %s\n", e.Line, e.Column, e.Message)
+	}
+	writeSourceCaret(&b, e.Source, e.Column)
+	if e.Suggestion != "" {
+		fmt.Fprintf(&b, "did you mean %s?\n", e.Suggestion)
+	}
+	return b.String()
+}
+
+// lineError turns an error found on a line into a ParseError for that line.
+// Errors that don't have a position are placed at the start of the
+// statement.
+func lineError(err error, source string, start Position) error {
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		parseErr = &ParseError{Line: start.Line, Column: start.Column, Message: err.Error()}
+	}
+	parseErr.Source = source
+	return parseErr
+}
+
+// levenshtein returns the number of single character edits needed to turn
+// a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// suggestFunction returns the known function whose name is closest to
+// name, or "" if none is close enough to be a likely misspelling.
+func suggestFunction(name string) string {
+	lower := strings.ToLower(name)
+	names := make([]string, 0, len(allFuncs))
+	for f := range allFuncs {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 0
+	for _, f := range names {
+		distance := levenshtein(lower, f)
+		if best == "" || distance < bestDistance {
+			best, bestDistance = f, distance
+		}
+	}
+	// allow about one typo for every three letters
+	limit := len([]rune(lower)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if best == "" || bestDistance > limit {
+		return ""
+	}
+	return CanonicalFuncName(best)
+}
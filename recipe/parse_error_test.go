@@ -0,0 +1,167 @@
+package recipe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_ParseError(t *testing.T) {
+	tests := []struct {
+		name           string
+		source         string
+		wantLine       int
+		wantColumn     int
+		wantMessage    string
+		wantSuggestion string
+	}{
+		{
+			name:           "misspelled function",
+			source:         "1 <- 1\n2 <- 3 -> uppercse -> trim\n",
+			wantLine:       2,
+			wantColumn:     11,
+			wantMessage:    "unrecognized function uppercse",
+			wantSuggestion: "uppercase",
+		},
+		{
+			name:           "misspelled function keeps canonical case",
+			source:         "1 <- 1 -> readdat(\"01/02/2006\")\n",
+			wantLine:       1,
+			wantColumn:     11,
+			wantMessage:    "unrecognized function readdat",
+			wantSuggestion: "readDate",
+		},
+		{
+			name:        "unknown function with nothing close",
+			source:      "1 <- 1 -> frobnicate\n",
+			wantLine:    1,
+			wantColumn:  11,
+			wantMessage: "unrecognized function frobnicate",
+		},
+		{
+			name:        "missing operand after pipe",
+			source:      "\n\n  1 <- 2 -> \n",
+			wantLine:    3,
+			wantColumn:  13,
+			wantMessage: "expected a column, literal, variable, parameter, function or ? but found [EOF]",
+		},
+		{
+			name:        "bad function argument",
+			source:      "1 <- add(1, ->)\n",
+			wantLine:    1,
+			wantColumn:  13,
+			wantMessage: "expected function args for add, found [->]",
+		},
+		{
+			name:        "missing assignment",
+			source:      "1 2\n",
+			wantLine:    1,
+			wantColumn:  3,
+			wantMessage: "expected assignment ( <- ) but found [2] instead",
+		},
+		{
+			name:        "duplicate column points at the statement",
+			source:      "1 <- 1\n 1 <- 2\n",
+			wantLine:    2,
+			wantColumn:  2,
+			wantMessage: "column 1 already defined",
+		},
+		{
+			name:        "bad target",
+			source:      "\"x\" <- 1\n",
+			wantLine:    1,
+			wantColumn:  1,
+			wantMessage: "expected column, header or variable but found [x]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.source))
+			parseErr, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("Parse() error = %#v, want a *ParseError", err)
+			}
+			if parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("Parse() error at %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tt.wantLine, tt.wantColumn)
+			}
+			if parseErr.Message != tt.wantMessage {
+				t.Errorf("Parse() error message = %q, want %q", parseErr.Message, tt.wantMessage)
+			}
+			if parseErr.Suggestion != tt.wantSuggestion {
+				t.Errorf("Parse() error suggestion = %q, want %q", parseErr.Suggestion, tt.wantSuggestion)
+			}
+			if want := strings.Split(tt.source, "\n")[tt.wantLine-1]; parseErr.Source != want {
+				t.Errorf("Parse() error source = %q, want %q", parseErr.Source, want)
+			}
+		})
+	}
+}
+
+func TestParseError_Render(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *ParseError
+		want string
+	}{
+		{
+			name: "with file and suggestion",
+			err:  &ParseError{File: "clean.txt", Line: 2, Column: 11, Message: "unrecognized function uppercse", Source: "2 <- 3 -> uppercse", Suggestion: "uppercase"},
+			want: "clean.txt:2:11: unrecognized function uppercse\n    2 <- 3 -> uppercse\n              ^\ndid you mean uppercase?\n",
+		},
+		{
+			name: "tabs line up",
+			err:  &ParseError{Line: 1, Column: 7, Message: "oops", Source: "\t1 <-\tx"},
+			want: "line 1, column 7: oops\n    \t1 <-\tx\n    \t    \t^\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Render(); got != tt.want {
+				t.Errorf("Render() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError_Error(t *testing.T) {
+	err := &ParseError{Line: 3, Column: 4, Message: "unrecognized function trimm", Suggestion: "trim"}
+	want := "error - line 3: unrecognized function trimm (did you mean trim?)"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestParseExpression_ParseError(t *testing.T) {
+	_, err := ParseExpression("3 -> lowercas")
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("ParseExpression() error = %#v, want a *ParseError", err)
+	}
+	if parseErr.Column != 6 || parseErr.Source != "3 -> lowercas" || parseErr.Suggestion != "lowercase" {
+		t.Errorf("ParseExpression() error = %+v", parseErr)
+	}
+}
+
+func TestScanner_Pos(t *testing.T) {
+	s := NewScanner(strings.NewReader("1 <- 2\n$a"))
+	var got []Position
+	for {
+		pos := s.Pos()
+		tok, _ := s.Scan()
+		if tok == EOF {
+			break
+		}
+		if tok != WS {
+			got = append(got, pos)
+		}
+	}
+	want := []Position{{1, 1}, {1, 3}, {1, 6}, {2, 1}}
+	if len(got) != len(want) {
+		t.Fatalf("Pos() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Pos() = %v, want %v", got, want)
+			break
+		}
+	}
+}
@@ -3,6 +3,7 @@ package recipe
 import (
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -680,6 +681,180 @@ func TestTransformation_ParseExecute(t *testing.T) {
 			wantParseErr:     true,
 			wantParseErrText: "error - line 5: variable $foo already defined",
 		},
+		{
+			name:   "parameter default is used when no value is bound",
+			recipe: "%client = \"ACME\"\n1 <- %client + \"-\" + 1\n",
+			input:  "a\nb\n",
+			want:   "ACME-a\nACME-b\n",
+		},
+		{
+			name:        "required parameter without a value is an error",
+			recipe:      "1 <- %client\n",
+			input:       "a\n",
+			wantErr:     true,
+			wantErrText: "missing value for required parameter(s): %client",
+		},
+		{
+			name:             "parameter can only be declared once",
+			recipe:           "%client\n%client = \"x\"\n1 <- 1\n",
+			wantParseErr:     true,
+			wantParseErrText: "error - line 2: parameter %client already defined",
+		},
+		{
+			name:          "persistent variable keeps a running balance across rows",
+			recipe:        "@balance = \"0\"\n@balance <- add(@balance, 2) -> trimZeros\n1 <- 1\n2 <- @balance\n",
+			input:         "item,balance\na,5\nb,-2\nc,10\n",
+			processHeader: true,
+			want:          "item,balance\na,5\nb,3\nc,13\n",
+		},
+		{
+			name:   "persistent variable read after its assignment has this row's value",
+			recipe: "$before <- @last\n@last <- 1\n$after <- @last\n1 <- $before\n2 <- $after\n",
+			input:  "a\nb\n",
+			want:   ",a\na,b\n",
+		},
+		{
+			name:   "columns see this row's persistent value wherever they are in the recipe",
+			recipe: "2 <- @bal\n@bal = \"0\"\n@bal <- add(@bal, 1) -> trimZeros\n1 <- 1\n",
+			input:  "1\n2\n3\n",
+			want:   "1,1\n2,3\n3,6\n",
+		},
+		{
+			name:             "persistent variable can only be initialized once",
+			recipe:           "@count = \"0\"\n@count = \"1\"\n1 <- 1\n",
+			wantParseErr:     true,
+			wantParseErrText: "error - line 2: persistent variable @count already initialized",
+		},
+		{
+			name:             "persistent variable initial value must be a literal",
+			recipe:           "@count = 1\n1 <- 1\n",
+			wantParseErr:     true,
+			wantParseErrText: "error - line 1: initial value for @count must be a literal, found [1]",
+		},
+		{
+			name:        "undefined persistent variable is an error",
+			recipe:      "1 <- @nope\n",
+			input:       "a\n",
+			wantErr:     true,
+			wantErrText: "line 1 / column 1: persistent variable '@nope' referenced, but it is not defined",
+		},
+		{
+			name:          "prev returns the value from the previous data row",
+			recipe:        "1 <- 1\n2 <- prev(1)\n",
+			input:         "h\na\nb\nc\n",
+			processHeader: true,
+			want:          "h,column 2\na,\nb,a\nc,b\n",
+		},
+		{
+			name:          "runningSum totals values and ignores the header row",
+			recipe:        "1 <- 1\n2 <- runningSum(2) -> trimZeros\n",
+			input:         "name,amount\na,5\nb,\nc,2.5\n",
+			processHeader: true,
+			want:          "name,amount\na,5\nb,5\nc,7.5\n",
+		},
+		{
+			name:          "stateful functions in variables do not count the header row",
+			recipe:        "$n <- counter(\"k\")\n!1 <- \"n\"\n1 <- $n\n",
+			input:         "h\na\nb\n",
+			processHeader: true,
+			want:          "n\n1\n2\n",
+		},
+		{
+			name:        "runningSum of a non-numeric value is an error",
+			recipe:      "1 <- runningSum(1)\n",
+			input:       "1\nabc\n",
+			wantErr:     true,
+			wantErrText: "line 2 / column 1: runningsum(): input is not numeric: got 'abc'",
+		},
+		{
+			name:   "fillDown carries the last non-empty value forward",
+			recipe: "1 <- fillDown(1)\n2 <- 2\n",
+			input:  ",x\nred,a\n,b\n,c\nblue,d\n,e\n",
+			want:   ",x\nred,a\nred,b\nred,c\nblue,d\nblue,e\n",
+		},
+		{
+			name:   "counter numbers rows within a group",
+			recipe: "1 <- 1\n2 <- counter(1)\n",
+			input:  "a\na\nb\na\nb\n",
+			want:   "a,1\na,2\nb,1\na,3\nb,2\n",
+		},
+		{
+			name:   "each stateful function call keeps its own state",
+			recipe: "1 <- counter(\"x\")\n2 <- counter(\"x\")\n3 <- counter(\"x\") + counter(\"x\")\n",
+			input:  "a\nb\n",
+			want:   "1,1,11\n2,2,22\n",
+		},
+		{
+			name:          "explode writes one row per element",
+			recipe:        "explode(\";\") <- 2\n!2 <- \"tag\"\n1 <- 1\n2 <- $element -> trim\n3 <- $elementIndex\n",
+			input:         "id,tags\n1,a; b;c\n2,d\n3,\n",
+			processHeader: true,
+			want:          "id,tag,column 3\n1,a,1\n1,b,2\n1,c,3\n2,d,1\n3,,1\n",
+		},
+		{
+			name:   "explode can use variables and a comment",
+			recipe: "$tags <- 2 -> uppercase\nexplode(\"|\") <- $tags # one row per tag\n1 <- 1 + \"-\" + $element\n",
+			input:  "x,a|b\n",
+			want:   "x-A\nx-B\n",
+		},
+		{
+			name:             "explode can only be defined once",
+			recipe:           "explode(\";\") <- 1\nexplode(\",\") <- 2\n1 <- $element\n",
+			wantParseErr:     true,
+			wantParseErrText: "error - line 2: explode already defined",
+		},
+		{
+			name:             "explode delimiter must not be empty",
+			recipe:           "explode(\"\") <- 1\n1 <- $element\n",
+			wantParseErr:     true,
+			wantParseErrText: "error - line 1: explode delimiter must not be empty",
+		},
+		{
+			name:             "element variables are reserved",
+			recipe:           "$element <- 1\n1 <- $element\n",
+			wantParseErr:     true,
+			wantParseErrText: "error - line 1: $element is reserved for explode and cannot be assigned",
+		},
+		{
+			name:          "aggregate recipe groups by the non-aggregated columns",
+			recipe:        "1 <- 1\n2 <- count\n3 <- 2 -> sum\n4 <- avg(2)\n5 <- min(2)\n6 <- max(2)\n!2 <- \"voters\"\n",
+			input:         "state,amount\nUT,5\nCO,2\nUT,10\nUT,\nCO,4.5\n",
+			processHeader: true,
+			want:          "state,voters,column 3,column 4,column 5,column 6\nCO,2,6.5,3.25,2,4.5\nUT,3,15,7.5,5,10\n",
+		},
+		{
+			name:   "aggregate first, last and countDistinct",
+			recipe: "1 <- 1 -> uppercase\n2 <- 2 -> first\n3 <- last(2)\n4 <- countDistinct(2)\n",
+			input:  "a,x\nb,y\nA,z\na,x\n",
+			want:   "A,x,x,2\nB,y,y,1\n",
+		},
+		{
+			name:   "aggregate with multiple key columns",
+			recipe: "1 <- 1\n2 <- 2\n3 <- count(3)\n",
+			input:  "a,1,x\na,2,x\na,1,x\n",
+			want:   "a,1,2\na,2,1\n",
+		},
+		{
+			name:        "aggregate sum of a non-numeric value is an error",
+			recipe:      "1 <- 1\n2 <- sum(2)\n",
+			input:       "a,1\na,two\n",
+			wantErr:     true,
+			wantErrText: "line 2 / column 2: sum(): input is not numeric: got 'two'",
+		},
+		{
+			name:        "aggregate function must be the last step",
+			recipe:      "1 <- 1 -> sum -> trimZeros\n",
+			input:       "1\n",
+			wantErr:     true,
+			wantErrText: "aggregate function sum must be the last step of column 1",
+		},
+		{
+			name:        "aggregate function is not allowed in a variable",
+			recipe:      "$total <- sum(1)\n1 <- $total\n",
+			input:       "1\n",
+			wantErr:     true,
+			wantErrText: "aggregate function sum can only be used in column recipes, found in variable $total",
+		},
 		{
 			name:   "power function works with integers",
 			recipe: "1 <- power(\"2\", 1)",
@@ -763,3 +938,101 @@ func TestTransformation_ParseExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestTransformation_Execute_PinnedNow(t *testing.T) {
+	defer func(now func() time.Time) { Now = now }(Now)
+
+	bake := func(now time.Time) []byte {
+		Now = func() time.Time { return now }
+		transformation, err := Parse(strings.NewReader("1 <- now()\n2 <- today()\n3 <- 1 -> isFuture(\"future\", \"past\")\n4 <- 1 -> isPast(\"past\", \"future\")\n"))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		var b bytes.Buffer
+		writer := csv.NewWriter(&b)
+		if _, err := transformation.Execute(csv.NewReader(strings.NewReader("1977-11-07\n2031-09-01\n")), writer, false, -1, false); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		return b.Bytes()
+	}
+
+	now := time.Date(2021, 8, 30, 18, 22, 13, 0, time.UTC)
+	first := bake(now)
+	want := "2021-08-30T18:22:13Z,2021-08-30,past,past\n2021-08-30T18:22:13Z,2021-08-30,future,future\n"
+	if string(first) != want {
+		t.Errorf("Execute() = %q, want %q", first, want)
+	}
+	if second := bake(now); !bytes.Equal(first, second) {
+		t.Errorf("Execute() differs between runs with the same time: %q and %q", first, second)
+	}
+}
+
+// evenLines keeps the even input lines and fails on a line containing "stop".
+type evenLines struct {
+	headers int
+}
+
+func (f *evenLines) Keep(lineNo int, row []string, header bool) (bool, error) {
+	if header {
+		f.headers++
+		return false, nil
+	}
+	if row[0] == "stop" {
+		return false, errors.New("stopped")
+	}
+	return lineNo%2 == 0, nil
+}
+
+func TestTransformation_Execute_Filter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		skipped int
+		wantErr string
+	}{
+		{
+			name:    "rejected rows are skipped",
+			input:   "head\na\nb\nc\nd\n",
+			want:    "head,1\nA,1\nC,2\n",
+			skipped: 2,
+		},
+		{
+			name:    "errors stop the bake",
+			input:   "head\na\nstop\n",
+			wantErr: "stopped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, err := Parse(strings.NewReader("1 <- 1 -> uppercase\n2 <- counter(\"\")\n!2 <- \"1\"\n"))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			filter := &evenLines{}
+			transformation.Filter = filter
+
+			var b bytes.Buffer
+			writer := csv.NewWriter(&b)
+			result, err := transformation.Execute(csv.NewReader(strings.NewReader(tt.input)), writer, true, -1, false)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Execute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Execute() = %q, want %q", b.String(), tt.want)
+			}
+			if result.SkippedLines != tt.skipped || result.Lines != 4 {
+				t.Errorf("Execute() skipped %d of %d lines, want %d of 4", result.SkippedLines, result.Lines, tt.skipped)
+			}
+			if filter.headers != 1 {
+				t.Errorf("Filter saw %d headers, want 1", filter.headers)
+			}
+		})
+	}
+}
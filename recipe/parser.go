@@ -3,6 +3,7 @@ package recipe
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -10,49 +11,78 @@ import (
 )
 
 var allFuncs = map[string][]int{
-	"uppercase":    {1},
-	"lowercase":    {1},
-	"join":         {1},
-	"add":          {2},
-	"subtract":     {2},
-	"multiply":     {2},
-	"divide":       {2},
-	"change":       {3},
-	"changei":      {3},
-	"ifempty":      {3},
-	"isempty":      {3}, // alias for ifempty
-	"numberformat": {2},
-	"lineno":       {0},
-	"removedigits": {1},
-	"onlydigits":   {1},
-	"mod":          {2},
-	"trim":         {1},
-	"trimzeros":    {1},
-	"firstchars":   {1},
-	"lastchars":    {1},
-	"repeat":       {2},
-	"replace":      {3},
-	"today":        {0},
-	"now":          {0},
-	"formatdate":   {2},
-	"formatdatef":  {2},
-	"readdate":     {2},
-	"readdatef":    {2},
-	"smartdate":    {1},
-	"ispast":       {3},
-	"isfuture":     {3},
-	"power":        {2},
-	"age":          {1},
-	"coalesce":     {2},
-	"nth":          {3},
-	"padleft":      {3},
-	"padright":     {3},
-	"titlecase":    {1},
-	"regexreplace": {3},
-	"substring":    {3},
+	"uppercase":     {1},
+	"lowercase":     {1},
+	"join":          {1},
+	"add":           {2},
+	"subtract":      {2},
+	"multiply":      {2},
+	"divide":        {2},
+	"change":        {3},
+	"changei":       {3},
+	"ifempty":       {3},
+	"isempty":       {3}, // alias for ifempty
+	"numberformat":  {2},
+	"lineno":        {0},
+	"removedigits":  {1},
+	"onlydigits":    {1},
+	"mod":           {2},
+	"trim":          {1},
+	"trimzeros":     {1},
+	"firstchars":    {2},
+	"lastchars":     {2},
+	"repeat":        {2},
+	"replace":       {3},
+	"today":         {0},
+	"now":           {0},
+	"formatdate":    {2},
+	"formatdatef":   {2},
+	"readdate":      {2},
+	"readdatef":     {2},
+	"smartdate":     {1},
+	"ispast":        {3},
+	"isfuture":      {3},
+	"power":         {2},
+	"age":           {1},
+	"coalesce":      {2},
+	"nth":           {3},
+	"padleft":       {3},
+	"padright":      {3},
+	"titlecase":     {1},
+	"regexreplace":  {3},
+	"substring":     {3},
+	"prev":          {1},
+	"runningsum":    {1},
+	"filldown":      {1},
+	"counter":       {1},
+	"count":         {1},
+	"sum":           {1},
+	"min":           {1},
+	"max":           {1},
+	"avg":           {1},
+	"first":         {1},
+	"last":          {1},
+	"countdistinct": {1},
 }
 
 func Parse(source io.Reader) (*Transformation, error) {
+	return parse(source, nil)
+}
+
+// parse does the work of Parse. If m is not nil, it also records where each
+// statement, operation and comment was found.
+func parse(source io.Reader, m *sourceMap) (*Transformation, error) {
+	transformation, errs := parseLines(source, m, false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return transformation, nil
+}
+
+// parseLines parses each line of a recipe into a transformation. Unless
+// recoverErrors is set it stops at the first line with an error; otherwise
+// it skips that line and carries on, so every error is found.
+func parseLines(source io.Reader, m *sourceMap, recoverErrors bool) (*Transformation, []error) {
 	transformation := NewTransformation()
 
 	// split by newlines
@@ -60,138 +90,263 @@ func Parse(source io.Reader) (*Transformation, error) {
 	_, _ = buf.ReadFrom(source)
 	s := buf.String()
 	lines := strings.Split(s, "\n")
+	if m != nil {
+		m.lines = lines
+	}
 
+	var errs []error
 	for lineNo, l := range lines {
 		if strings.TrimSpace(l) == "" {
 			// blank lines make the reader get a \0 which is eof which causes the parser to exit, so we
 			// ignore that right here.
 			continue
 		}
-		p := NewParser(strings.NewReader(l))
+		if err := parseLine(transformation, l, lineNo, m); err != nil {
+			errs = append(errs, err)
+			if !recoverErrors {
+				break
+			}
+		}
+	}
 
-		// Full Line Comment
-		tok, lit := p.scanIgnoreWhitespace()
-		if tok == COMMENT {
-			p.scanComment()
-			continue
+	return transformation, errs
+}
+
+// parseLine parses a single non-blank line of a recipe into transformation.
+func parseLine(transformation *Transformation, l string, lineNo int, m *sourceMap) error {
+	p := newLineParser(l, lineNo+1)
+
+	// Full Line Comment
+	tok, lit := p.scanIgnoreWhitespace()
+	start := p.pos
+	if tok == COMMENT {
+		m.addComment(Comment{Text: lit, Position: start})
+		p.scanComment()
+		return nil
+	}
+	if tok == EOF {
+		return nil
+	}
+
+	if tok == PARAMETER {
+		if err := consumeParameterDeclaration(p, transformation, lit); err != nil {
+			return lineError(err, l, start)
 		}
-		if tok == EOF {
-			break
+		m.addStatement(statementSource{kind: ParameterStatement, output: Output{Type: Parameter, Value: lit}, position: start, comment: trailingComment(l)})
+		return nil
+	}
+
+	if tok == PERSISTENT {
+		// @name = "value" sets the starting value instead of a per-row recipe
+		if next, _ := p.scanIgnoreWhitespace(); next == EQUALS {
+			if err := consumePersistentInit(p, transformation, lit); err != nil {
+				return lineError(err, l, start)
+			}
+			m.addStatement(statementSource{kind: InitStatement, output: Output{Type: Persistent, Value: lit}, position: start, comment: trailingComment(l)})
+			return nil
+		}
+		p.unscan()
+	}
+
+	isExplode := tok == FUNCTION && strings.ToLower(lit) == "explode"
+
+	if tok != COLUMN_ID && tok != VARIABLE && tok != HEADER && tok != PERSISTENT && !isExplode {
+		return lineError(fmt.Errorf("expected column, header or variable but found [%s]", lit), l, start)
+	}
+
+	// Found column or variable to assign result to
+	target := lit
+	var targetType DataType
+	switch tok {
+	case FUNCTION:
+		delimiter, err := consumeExplodeDelimiter(p)
+		if err != nil {
+			return lineError(err, l, start)
+		}
+		if err := transformation.AddExplode(delimiter); err != nil {
+			return lineError(err, l, start)
+		}
+		target = "explode"
+		targetType = Explode
+	case COLUMN_ID:
+		err := transformation.AddOutputToColumn(lit)
+		if err != nil {
+			return lineError(err, l, start)
+		}
+		targetType = Column
+	case VARIABLE:
+		if lit == ElementVariable || lit == ElementIndexVariable {
+			return lineError(fmt.Errorf("%s is reserved for explode and cannot be assigned", lit), l, start)
+		}
+		err := transformation.AddOutputToVariable(lit)
+		if err != nil {
+			return lineError(err, l, start)
+		}
+		transformation.VariableOrder = append(transformation.VariableOrder, lit)
+		targetType = Variable
+	case PERSISTENT:
+		err := transformation.AddOutputToPersistent(lit)
+		if err != nil {
+			return lineError(err, l, start)
+		}
+		transformation.VariableOrder = append(transformation.VariableOrder, lit)
+		targetType = Persistent
+	case HEADER:
+		err := transformation.AddOutputToHeader(lit)
+		if err != nil {
+			return lineError(err, l, start)
+		}
+		targetType = Header
+	}
+
+	// A column may be annotated with the type of its values, as in 3:int
+	if next, _ := p.scan(); next == COLON {
+		if targetType != Column {
+			return lineError(p.errorf("only columns can have a type annotation"), l, start)
+		}
+		columnType, err := consumeColumnType(p)
+		if err != nil {
+			return lineError(err, l, start)
 		}
+		columnNum, _ := strconv.Atoi(target)
+		recipe := transformation.Columns[columnNum]
+		recipe.Type = &columnType
+		transformation.Columns[columnNum] = recipe
+	} else {
+		p.unscan()
+	}
+
+	statement := statementSource{kind: RecipeStatement, output: Output{Type: targetType, Value: target}, position: start}
+	addOperation := func(column int, operation Operation) {
+		statement.operations = append(statement.operations, Position{Line: lineNo + 1, Column: column})
+		statement.implicit = append(statement.implicit, false)
+		transformation.AddOperationByType(targetType, target, operation)
+	}
+	addCall := func(column int, operation Operation, implicit bool) {
+		addOperation(column, operation)
+		statement.implicit[len(statement.implicit)-1] = implicit
+	}
+
+	// After column or variable, we need the assignment <- operator
+	if err := consumeAssignment(p); err != nil {
+		return lineError(err, l, start)
+	}
 
-		if tok != COLUMN_ID && tok != VARIABLE && tok != HEADER {
-			return transformation, fmt.Errorf("expected column, header or variable on line %d, but found %s", lineNo, lit)
+	// grab first pipe piece - literal, column, variable, function, function w/ args
+	tok, lit = p.scanIgnoreWhitespace()
+	switch tok {
+	case COLUMN_ID:
+		addOperation(p.pos.Column, getColumn(lit))
+	case LITERAL:
+		addOperation(p.pos.Column, getLiteral(lit))
+	case VARIABLE:
+		addOperation(p.pos.Column, getVariable(lit))
+	case PERSISTENT:
+		addOperation(p.pos.Column, getPersistent(lit))
+	case PARAMETER:
+		transformation.AddParameterReference(lit)
+		addOperation(p.pos.Column, getParameter(lit))
+	case FUNCTION:
+		function, column := lit, p.pos.Column
+		operation, implicit, err := consumeFunctionArgs(p, function)
+		if err != nil {
+			return lineError(err, l, start)
 		}
+		transformation.addParameterReferences(operation)
+		addCall(column, operation, implicit)
+	default:
+		return lineError(p.errorf("expected a column, literal, variable, parameter or function after <- but found [%s]", lit), l, start)
+	}
 
-		// Found column or variable to assign result to
-		target := lit
-		var targetType DataType
+LOOPSCAN:
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
 		switch tok {
-		case COLUMN_ID:
-			err := transformation.AddOutputToColumn(lit)
-			if err != nil {
-				return nil, fmt.Errorf("error - line %d: %s", lineNo+1, err.Error())
+		case EOF:
+			break LOOPSCAN
+		case PIPE:
+			// a pipe just connects to the next operand scanned below
+		case PLUS:
+			addOperation(p.pos.Column, getJoinWithPlaceholder())
+		case COMMENT:
+			if targetType == Variable || targetType == Persistent {
+				recipe := transformation.Variables[target]
+				recipe.Comment = lit
+				transformation.Variables[target] = recipe
 			}
-			targetType = Column
-		case VARIABLE:
-			err := transformation.AddOutputToVariable(lit)
-			if err != nil {
-				return nil, fmt.Errorf("error - line %d: %s", lineNo+1, err.Error())
+			if targetType == Column {
+				columnNum, _ := strconv.Atoi(target)
+				recipe := transformation.Columns[columnNum]
+				recipe.Comment = lit
+				transformation.Columns[columnNum] = recipe
 			}
-			transformation.VariableOrder = append(transformation.VariableOrder, lit)
-			targetType = Variable
-		case HEADER:
-			err := transformation.AddOutputToHeader(lit)
-			if err != nil {
-				return nil, fmt.Errorf("error - line %d: %s", lineNo+1, err.Error())
+			if targetType == Header {
+				headerNum, _ := strconv.Atoi(target)
+				recipe := transformation.Headers[headerNum]
+				recipe.Comment = lit
+				transformation.Headers[headerNum] = recipe
 			}
-			targetType = Header
-		}
-
-		// After column or variable, we need the assignment <- operator
-		if err := consumeAssignment(p); err != nil {
-			return nil, err
+			if targetType == Explode {
+				transformation.Explode.Recipe.Comment = lit
+			}
+			break LOOPSCAN
+		default:
+			// any other connector token falls through to scan the next operand
 		}
 
-		// grab first pipe piece - literal, column, variable, function, function w/ args
+		// After connection scan stuff we can do (column, variable, literal, function)
+		// Comments or EOL are no bueno here like 1 <- 2 + # comment <- what??
 		tok, lit = p.scanIgnoreWhitespace()
 		switch tok {
 		case COLUMN_ID:
-			transformation.AddOperationByType(targetType, target, getColumn(lit))
-		case LITERAL:
-			transformation.AddOperationByType(targetType, target, getLiteral(lit))
+			addOperation(p.pos.Column, getColumn(lit))
 		case VARIABLE:
-			transformation.AddOperationByType(targetType, target, getVariable(lit))
+			addOperation(p.pos.Column, getVariable(lit))
+		case PERSISTENT:
+			addOperation(p.pos.Column, getPersistent(lit))
+		case PARAMETER:
+			transformation.AddParameterReference(lit)
+			addOperation(p.pos.Column, getParameter(lit))
+		case LITERAL:
+			addOperation(p.pos.Column, getLiteral(lit))
 		case FUNCTION:
-			function := lit
-			operation, err := consumeFunctionArgs(p, function)
+			function, column := lit, p.pos.Column
+			operation, implicit, err := consumeFunctionArgs(p, function)
 			if err != nil {
-				return nil, err
+				return lineError(err, l, start)
 			}
-			transformation.AddOperationByType(targetType, target, operation)
+			transformation.addParameterReferences(operation)
+			addCall(column, operation, implicit)
+		case PLACEHOLDER:
+			addOperation(p.pos.Column, getPlaceholder())
 		default:
-			return nil, fmt.Errorf("unexpected token [%d] %s", tok, lit)
+			return lineError(p.errorf("expected a column, literal, variable, parameter, function or ? but found [%s]", lit), l, start)
 		}
+	}
+	m.addStatement(statement)
+	return nil
+}
 
-	LOOPSCAN:
-		for {
-			tok, lit := p.scanIgnoreWhitespace()
-			switch tok {
-			case EOF:
-				break LOOPSCAN
-			case PIPE:
-				// a pipe just connects to the next operand scanned below
-			case PLUS:
-				transformation.AddOperationByType(targetType, target, getJoinWithPlaceholder())
-			case COMMENT:
-				if targetType == Variable {
-					recipe := transformation.Variables[target]
-					recipe.Comment = lit
-					transformation.Variables[target] = recipe
-				}
-				if targetType == Column {
-					columnNum, _ := strconv.Atoi(target)
-					recipe := transformation.Columns[columnNum]
-					recipe.Comment = lit
-					transformation.Columns[columnNum] = recipe
-				}
-				if targetType == Header {
-					headerNum, _ := strconv.Atoi(target)
-					recipe := transformation.Headers[headerNum]
-					recipe.Comment = lit
-					transformation.Headers[headerNum] = recipe
-				}
-				break LOOPSCAN
-			default:
-				// any other connector token falls through to scan the next operand
-			}
-
-			// After connection scan stuff we can do (column, variable, literal, function)
-			// Comments or EOL are no bueno here like 1 <- 2 + # comment <- what??
-			tok, lit = p.scanIgnoreWhitespace()
-			switch tok {
-			case COLUMN_ID:
-				transformation.AddOperationByType(targetType, target, getColumn(lit))
-			case VARIABLE:
-				transformation.AddOperationByType(targetType, target, getVariable(lit))
-			case LITERAL:
-				transformation.AddOperationByType(targetType, target, getLiteral(lit))
-			case FUNCTION:
-				function := lit
-				operation, err := consumeFunctionArgs(p, function)
-				if err != nil {
-					return nil, err
-				}
-				transformation.AddOperationByType(targetType, target, operation)
-			case PLACEHOLDER:
-				transformation.AddOperationByType(targetType, target, getPlaceholder())
-			default:
-				return nil, fmt.Errorf("unexpected token [%d]-'%s' in parse loop", tok, lit)
+// ParseExpression parses the part of a recipe line that comes after the
+// assignment operator, such as `3 -> trim -> lowercase`, into a Recipe.
+func ParseExpression(expression string) (Recipe, error) {
+	if strings.Contains(expression, "\n") {
+		return Recipe{}, errors.New("expression must be a single line")
+	}
+	const prefix = "1 <- "
+	t, err := Parse(strings.NewReader(prefix + expression))
+	if err != nil {
+		// report the position within the expression rather than the line
+		// it was parsed as
+		if parseErr, ok := err.(*ParseError); ok {
+			parseErr.Source = expression
+			if parseErr.Column > len(prefix) {
+				parseErr.Column -= len(prefix)
 			}
 		}
+		return Recipe{}, err
 	}
-
-	return transformation, nil
+	return t.Columns[1], nil
 }
 
 func getLiteral(lit string) Operation {
@@ -238,6 +393,24 @@ func getVariable(lit string) Operation {
 	}
 }
 
+func getPersistent(lit string) Operation {
+	return Operation{
+		Name: "value",
+		Arguments: []Argument{
+			persistentArg(lit),
+		},
+	}
+}
+
+func getParameter(lit string) Operation {
+	return Operation{
+		Name: "value",
+		Arguments: []Argument{
+			parameterArg(lit),
+		},
+	}
+}
+
 func getJoinWithPlaceholder() Operation {
 	return Operation{
 		Name: "join",
@@ -261,6 +434,13 @@ func getOutputForVariable(v string) Output {
 	}
 }
 
+func getOutputForPersistent(v string) Output {
+	return Output{
+		Type:  Persistent,
+		Value: v,
+	}
+}
+
 func getOutputForHeader(h string) Output {
 	return Output{
 		Type:  Header,
@@ -271,16 +451,115 @@ func getOutputForHeader(h string) Output {
 func consumeAssignment(p *Parser) error {
 	tok, lit := p.scanIgnoreWhitespace()
 	if tok != ASSIGNMENT {
-		return fmt.Errorf("expected assignment ( <- ) but found [%s] (%d) instead", lit, tok)
+		return p.errorf("expected assignment ( <- ) but found [%s] instead", lit)
 	}
 	return nil
 }
 
-func consumeFunctionArgs(p *Parser, name string) (Operation, error) {
+// consumeColumnType reads the type of a column annotation after the colon:
+// a type name, and for dates an optional layout in parentheses.
+func consumeColumnType(p *Parser) (ColumnType, error) {
+	tok, name := p.scan()
+	if tok != FUNCTION {
+		return ColumnType{}, p.errorf("expected a type after : but found [%s]", name)
+	}
+	namePos := p.pos
+	var layout *string
+	if tok, _ := p.scan(); tok == OPEN_PAREN {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != LITERAL {
+			return ColumnType{}, p.errorf("expected a quoted layout for %s but found [%s]", name, lit)
+		}
+		layout = &lit
+		if tok, lit := p.scanIgnoreWhitespace(); tok != CLOSE_PAREN {
+			return ColumnType{}, p.errorf("expected ) after the layout but found [%s]", lit)
+		}
+	} else {
+		p.unscan()
+	}
+	columnType, err := parseColumnType(strings.ToLower(name), layout)
+	if err != nil {
+		return ColumnType{}, &ParseError{Line: namePos.Line, Column: namePos.Column, Message: err.Error()}
+	}
+	return columnType, nil
+}
+
+// consumeParameterDeclaration handles a line that starts with a parameter.
+// A parameter on its own declares it as required, while `%name = "value"`
+// declares it with a default value.
+func consumeParameterDeclaration(p *Parser, t *Transformation, name string) error {
+	tok, lit := p.scanIgnoreWhitespace()
+	switch tok {
+	case EOF, COMMENT:
+		return t.DeclareParameter(name, "", false)
+	case EQUALS:
+	default:
+		return p.errorf("expected = or end of line after parameter %s but found [%s]", name, lit)
+	}
+
+	tok, lit = p.scanIgnoreWhitespace()
+	if tok != LITERAL {
+		return p.errorf("default value for parameter %s must be a literal, found [%s]", name, lit)
+	}
+	defaultValue := lit
+
+	tok, lit = p.scanIgnoreWhitespace()
+	if tok != EOF && tok != COMMENT {
+		return p.errorf("unexpected [%s] after default value for parameter %s", lit, name)
+	}
+
+	return t.DeclareParameter(name, defaultValue, true)
+}
+
+// consumePersistentInit handles `@name = "value"`, which sets the value a
+// persistent variable holds before the first row is processed. The parser
+// must be positioned just after the = sign.
+func consumePersistentInit(p *Parser, t *Transformation, name string) error {
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != LITERAL {
+		return p.errorf("initial value for %s must be a literal, found [%s]", name, lit)
+	}
+	initial := lit
+
+	tok, lit = p.scanIgnoreWhitespace()
+	if tok != EOF && tok != COMMENT {
+		return p.errorf("unexpected [%s] after initial value for %s", lit, name)
+	}
+
+	return t.InitPersistent(name, initial)
+}
+
+// consumeExplodeDelimiter reads the ("delimiter") that follows explode at
+// the start of a line.
+func consumeExplodeDelimiter(p *Parser) (string, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != OPEN_PAREN {
+		return "", p.errorf("expected ( after explode but found [%s]", lit)
+	}
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != LITERAL {
+		return "", p.errorf("explode delimiter must be a literal, found [%s]", lit)
+	}
+	if lit == "" {
+		return "", p.errorf("explode delimiter must not be empty")
+	}
+	delimiter := lit
+	if tok, lit := p.scanIgnoreWhitespace(); tok != CLOSE_PAREN {
+		return "", p.errorf("expected ) after explode delimiter but found [%s]", lit)
+	}
+	return delimiter, nil
+}
+
+// consumeFunctionArgs reads the arguments of a call to the named function. It
+// also reports whether a placeholder the recipe doesn't show was added to
+// them, either because the call has no parentheses or because no argument
+// was written as ?.
+func consumeFunctionArgs(p *Parser, name string) (Operation, bool, error) {
 	// check if the function even exists
 	funcArgs, ok := allFuncs[strings.ToLower(name)]
 	if !ok {
-		return Operation{}, fmt.Errorf("unrecognized function %s", name)
+		err := p.errorf("unrecognized function %s", name).(*ParseError)
+		err.Suggestion = suggestFunction(name)
+		return Operation{}, false, err
 	}
 	var totalArgs int
 
@@ -303,7 +582,7 @@ func consumeFunctionArgs(p *Parser, name string) (Operation, error) {
 			operation.Arguments = append(operation.Arguments, placeholderArg())
 		}
 
-		return operation, nil
+		return operation, true, nil
 	}
 
 	var gotPlaceholder bool // track if the placeholder was explicitly provided or not
@@ -313,7 +592,7 @@ ARGLOOP:
 		tok, lit := p.scanIgnoreWhitespace()
 		switch tok {
 		case EOF:
-			return operation, fmt.Errorf("expected function args for %s. found EOF", name)
+			return operation, false, p.errorf("expected function args for %s, found end of line", name)
 		case LITERAL:
 			args = append(args, literalArg(lit))
 		case PLACEHOLDER:
@@ -323,23 +602,28 @@ ARGLOOP:
 			args = append(args, columnArg(lit))
 		case VARIABLE:
 			args = append(args, variableArg(lit))
+		case PARAMETER:
+			args = append(args, parameterArg(lit))
+		case PERSISTENT:
+			args = append(args, persistentArg(lit))
 		case COMMA:
 			// commas just separate arguments; keep scanning
 		case CLOSE_PAREN:
 			break ARGLOOP
 		default:
-			return operation, fmt.Errorf("expected function args, got [%d] - %s", tok, lit)
+			return operation, false, p.errorf("expected function args for %s, found [%s]", name, lit)
 		}
 	}
 
-	if !gotPlaceholder || len(args) == 0 {
+	implicit := !gotPlaceholder || len(args) == 0
+	if implicit {
 		args = append(args, placeholderArg())
 	}
 	//var args []Argument
 	// must now get args until we get a close paren
 	operation.Arguments = args
 
-	return operation, nil
+	return operation, implicit, nil
 }
 
 func variableArg(lit string) Argument {
@@ -363,6 +647,20 @@ func literalArg(lit string) Argument {
 	}
 }
 
+func parameterArg(lit string) Argument {
+	return Argument{
+		Type:  Parameter,
+		Value: lit,
+	}
+}
+
+func persistentArg(lit string) Argument {
+	return Argument{
+		Type:  Persistent,
+		Value: lit,
+	}
+}
+
 func placeholderArg() Argument {
 	return Argument{
 		Type:  Placeholder,
@@ -375,6 +673,14 @@ func NewParser(r io.Reader) *Parser {
 	return &Parser{s: NewScanner(r)}
 }
 
+// newLineParser returns a parser for a single line of a recipe, which is
+// line lineNo of the recipe file.
+func newLineParser(line string, lineNo int) *Parser {
+	p := NewParser(strings.NewReader(line))
+	p.s.line = lineNo - 1
+	return p
+}
+
 func isWhiteSpace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n'
 }
@@ -391,6 +697,12 @@ var eof = rune(0)
 
 type Scanner struct {
 	r *bufio.Reader
+	// line and column are where the next rune will be read from, counting
+	// from 0. previousColumn is the column before the last newline read so
+	// that it can be unread.
+	line, column   int
+	previousColumn int
+	lastNewline    bool
 }
 
 func NewScanner(r io.Reader) *Scanner {
@@ -402,8 +714,11 @@ type Parser struct {
 	buf struct {
 		tok Token
 		lit string
+		pos Position
 		n   int
 	}
+	// pos is where the most recently scanned token started.
+	pos Position
 }
 
 // read reads the next rune from the buffered reader
@@ -413,10 +728,33 @@ func (s *Scanner) read() rune {
 	if err != nil {
 		return eof
 	}
+	s.lastNewline = ch == '\n'
+	if s.lastNewline {
+		s.previousColumn = s.column
+		s.line++
+		s.column = 0
+	} else {
+		s.column++
+	}
 	return ch
 }
 
-func (s *Scanner) unread() { _ = s.r.UnreadRune() }
+func (s *Scanner) unread() {
+	if s.r.UnreadRune() != nil {
+		return
+	}
+	if s.lastNewline {
+		s.line--
+		s.column = s.previousColumn
+	} else {
+		s.column--
+	}
+}
+
+// Pos returns the position of the next rune to be scanned.
+func (s *Scanner) Pos() Position {
+	return Position{Line: s.line + 1, Column: s.column + 1}
+}
 
 // Scan returns the next token and literal value
 func (s *Scanner) Scan() (tok Token, lit string) {
@@ -442,6 +780,14 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 	} else if ch == '$' {
 		s.unread()
 		return s.scanVariable()
+	} else if ch == '@' {
+		s.unread()
+		_, lit := s.scanVariable()
+		return PERSISTENT, lit
+	} else if ch == '%' {
+		s.unread()
+		_, lit := s.scanVariable()
+		return PARAMETER, lit
 	} else if ch == '"' {
 		s.unread()
 		return s.scanLiteral()
@@ -468,6 +814,10 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 		return CLOSE_PAREN, string(ch)
 	case ',':
 		return COMMA, string(ch)
+	case '=':
+		return EQUALS, string(ch)
+	case ':':
+		return COLON, string(ch)
 	}
 
 	return ILLEGAL, string(ch)
@@ -647,18 +997,28 @@ func (p *Parser) scan() (tok Token, lit string) {
 	// If we have a token on the buffer, then return it.
 	if p.buf.n != 0 {
 		p.buf.n = 0
+		p.pos = p.buf.pos
 		return p.buf.tok, p.buf.lit
 	}
 
 	// Otherwise read the next token from the scanner.
+	p.pos = p.s.Pos()
 	tok, lit = p.s.Scan()
 
 	// Save it to the buffer in case we unscan it later.
-	p.buf.tok, p.buf.lit = tok, lit
+	p.buf.tok, p.buf.lit, p.buf.pos = tok, lit, p.pos
 
 	return
 }
 
+// unscan pushes the previously read token back onto the buffer.
+func (p *Parser) unscan() { p.buf.n = 1 }
+
+// errorf returns a ParseError at the most recently scanned token.
+func (p *Parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.pos.Line, Column: p.pos.Column, Message: fmt.Sprintf(format, args...)}
+}
+
 func (p *Parser) scanComment() string {
 	var tok Token
 	var lit string
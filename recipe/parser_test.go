@@ -575,6 +575,112 @@ func TestParse(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "parameter declared with a default and referenced",
+			args: args{source: strings.NewReader("%client = \"ACME\" # default client\n1 <- %client")},
+			want: &Transformation{
+				Variables: map[string]Recipe{},
+				Columns: map[int]Recipe{
+					1: {
+						Output: getOutputForColumn("1"),
+						Pipe: []Operation{
+							getParameter("%client"),
+						},
+					},
+				},
+				Headers: map[int]Recipe{},
+				Parameters: map[string]ParameterSpec{
+					"%client": {Name: "%client", Default: "ACME", HasDefault: true, Declared: true},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "parameter referenced in function args without declaration is required",
+			args: args{source: strings.NewReader("1 <- isPast(\"old\", \"new\", %cutoff)")},
+			want: &Transformation{
+				Variables: map[string]Recipe{},
+				Columns: map[int]Recipe{
+					1: {
+						Output: getOutputForColumn("1"),
+						Pipe: []Operation{
+							getFunction("isPast", []Argument{
+								literalArg("old"),
+								literalArg("new"),
+								parameterArg("%cutoff"),
+								placeholderArg(),
+							}),
+						},
+					},
+				},
+				Headers: map[int]Recipe{},
+				Parameters: map[string]ParameterSpec{
+					"%cutoff": {Name: "%cutoff"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "parameter declared without a default",
+			args: args{source: strings.NewReader("%client\n")},
+			want: &Transformation{
+				Variables: map[string]Recipe{},
+				Columns:   map[int]Recipe{},
+				Headers:   map[int]Recipe{},
+				Parameters: map[string]ParameterSpec{
+					"%client": {Name: "%client", Declared: true},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "parameter default must be a literal",
+			args:    args{source: strings.NewReader("%client = 3")},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "parameter can only be declared once",
+			args:    args{source: strings.NewReader("%client = \"a\"\n%client = \"b\"")},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "persistent variable with initial value and recipe",
+			args: args{source: strings.NewReader("@total = \"0\"\n@total <- add(@total, 3) # running total")},
+			want: &Transformation{
+				Variables: map[string]Recipe{
+					"@total": {
+						Output: getOutputForPersistent("@total"),
+						Pipe: []Operation{
+							getFunction("add", []Argument{
+								persistentArg("@total"),
+								columnArg("3"),
+								placeholderArg(),
+							}),
+						},
+						Comment: "running total",
+					},
+				},
+				Columns:        map[int]Recipe{},
+				Headers:        map[int]Recipe{},
+				VariableOrder:  []string{"@total"},
+				PersistentInit: map[string]string{"@total": "0"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "persistent variable cannot be defined twice",
+			args:    args{source: strings.NewReader("@total <- 1\n@total <- 2")},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "parameter cannot be assigned a pipe",
+			args:    args{source: strings.NewReader("%client <- 1")},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,93 @@
+package recipe
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// PreviewResult is the value one recipe produced for one input row. Element
+// is the 1-based explode element for column recipes when the recipe
+// explodes rows, and 0 otherwise.
+type PreviewResult struct {
+	LineNo  int
+	Header  bool
+	Element int
+	Value   string
+	Err     error
+}
+
+// Preview evaluates the recipe for target against each row without writing
+// any output. Unlike Execute it does not require the recipe to be complete,
+// so a recipe can be tried out one line at a time. Column and explode
+// recipes are only evaluated for data rows and header recipes only for the
+// header row.
+func (t *Transformation) Preview(rows [][]string, processHeader bool, target Output) ([]PreviewResult, error) {
+	if len(t.Parameters) > 0 && t.ParameterValues == nil {
+		if err := t.BindParameters(nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	t.state = t.newRowState()
+
+	var results []PreviewResult
+	for i, row := range rows {
+		header := processHeader && i == 0
+		context := t.newLineContext(row, i+1, header)
+		result := PreviewResult{LineNo: i + 1, Header: header}
+		if err := t.processVariables(context); err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+
+		switch target.Type {
+		case Variable:
+			result.Value = context.Variables[target.Value]
+		case Persistent:
+			if header {
+				continue
+			}
+			result.Value = context.Persistent[target.Value]
+		case Header:
+			if !header {
+				continue
+			}
+			h, _ := strconv.Atoi(target.Value)
+			result.Value, result.Err = t.processRecipe("header", t.Headers[h], context)
+		case Explode:
+			if header || t.Explode == nil {
+				continue
+			}
+			result.Value, result.Err = t.processRecipe("explode", t.Explode.Recipe, context)
+		case Column:
+			if header {
+				continue
+			}
+			c, _ := strconv.Atoi(target.Value)
+			elements, err := t.explodeRow(context)
+			if err != nil {
+				result.Err = err
+				break
+			}
+			for index, element := range elements {
+				t.setElement(context, index, element)
+				elementResult := result
+				if t.Explode != nil {
+					elementResult.Element = index + 1
+				}
+				elementResult.Value, elementResult.Err = t.processRecipe("column", t.Columns[c], context)
+				if columnType := t.Columns[c].Type; elementResult.Err == nil && columnType != nil {
+					if err := columnType.Check(elementResult.Value); err != nil {
+						elementResult.Err = fmt.Errorf("line %d / column %d: %v", context.LineNo, c, err)
+					}
+				}
+				results = append(results, elementResult)
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("unable to preview %s %s", target.Type, target.Value)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,117 @@
+package recipe
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformation_Preview(t *testing.T) {
+	rows := [][]string{
+		{"name", "tags"},
+		{"ann", "a;b"},
+		{"bob", ""},
+	}
+	tests := []struct {
+		name    string
+		recipe  string
+		target  Output
+		header  bool
+		want    []PreviewResult
+		wantErr bool
+	}{
+		{
+			name:   "incomplete recipe column",
+			recipe: "2 <- 1 -> uppercase\n",
+			target: Output{Type: Column, Value: "2"},
+			header: true,
+			want: []PreviewResult{
+				{LineNo: 2, Value: "ANN"},
+				{LineNo: 3, Value: "BOB"},
+			},
+		},
+		{
+			name:   "no header",
+			recipe: "1 <- 1 -> uppercase\n",
+			target: Output{Type: Column, Value: "1"},
+			want: []PreviewResult{
+				{LineNo: 1, Value: "NAME"},
+				{LineNo: 2, Value: "ANN"},
+				{LineNo: 3, Value: "BOB"},
+			},
+		},
+		{
+			name:   "header",
+			recipe: "1 <- 1\n!1 <- 1 -> uppercase\n",
+			target: Output{Type: Header, Value: "1"},
+			header: true,
+			want: []PreviewResult{
+				{LineNo: 1, Header: true, Value: "NAME"},
+			},
+		},
+		{
+			name:   "variable sees earlier variables",
+			recipe: "$a <- 1\n$b <- $a + \"!\"\n",
+			target: Output{Type: Variable, Value: "$b"},
+			header: true,
+			want: []PreviewResult{
+				{LineNo: 1, Header: true, Value: "name!"},
+				{LineNo: 2, Value: "ann!"},
+				{LineNo: 3, Value: "bob!"},
+			},
+		},
+		{
+			name:   "persistent skips header",
+			recipe: "@n <- @n + \"x\"\n",
+			target: Output{Type: Persistent, Value: "@n"},
+			header: true,
+			want: []PreviewResult{
+				{LineNo: 2, Value: "x"},
+				{LineNo: 3, Value: "xx"},
+			},
+		},
+		{
+			name:   "exploded column",
+			recipe: "explode(\";\") <- 2\n1 <- $element\n",
+			target: Output{Type: Column, Value: "1"},
+			header: true,
+			want: []PreviewResult{
+				{LineNo: 2, Element: 1, Value: "a"},
+				{LineNo: 2, Element: 2, Value: "b"},
+				{LineNo: 3, Element: 1, Value: ""},
+			},
+		},
+		{
+			name:   "errors are per row",
+			recipe: "1 <- 2 -> add(\"1\")\n",
+			target: Output{Type: Column, Value: "1"},
+			header: true,
+			want: []PreviewResult{
+				{LineNo: 2, Err: errors.New("line 2 / column 1: add(): second arg to Add was not numeric: a;b")},
+				{LineNo: 3, Err: errors.New("line 3 / column 1: add(): second arg to Add was not numeric: ")},
+			},
+		},
+		{
+			name:    "missing parameter",
+			recipe:  "1 <- %who\n",
+			target:  Output{Type: Column, Value: "1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, err := Parse(strings.NewReader(tt.recipe))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			got, err := transformation.Preview(rows, tt.header, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Preview() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Preview() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
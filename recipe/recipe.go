@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -55,6 +56,18 @@ func (a *Argument) GetValue(context LineContext, placeholder string) (string, er
 			return "", fmt.Errorf("variable '%s' referenced, but it is not defined", a.Value)
 		}
 		value = varValue
+	case Parameter:
+		paramValue, ok := context.Parameters[a.Value]
+		if !ok {
+			return "", fmt.Errorf("parameter '%s' referenced, but no value was provided", a.Value)
+		}
+		value = paramValue
+	case Persistent:
+		persistentValue, ok := context.Persistent[a.Value]
+		if !ok {
+			return "", fmt.Errorf("persistent variable '%s' referenced, but it is not defined", a.Value)
+		}
+		value = persistentValue
 	case Literal:
 		return a.Value, nil
 	case Placeholder:
@@ -75,6 +88,9 @@ type Recipe struct {
 	Output  Output
 	Pipe    []Operation
 	Comment string
+	// Type, when set, is the type a column annotation promises the
+	// column's values have.
+	Type *ColumnType
 }
 
 type Transformation struct {
@@ -83,16 +99,59 @@ type Transformation struct {
 	Headers       map[int]Recipe
 	VariableOrder []string
 	Sanitize      bool
+	// Parameters holds every parameter declared or referenced by the recipe.
+	// It is nil when the recipe uses no parameters.
+	Parameters map[string]ParameterSpec
+	// ParameterValues holds the resolved parameter values once
+	// BindParameters has been called.
+	ParameterValues map[string]string
+	// PersistentInit holds the starting values of persistent (@) variables
+	// that were given one with `@name = "value"`.
+	PersistentInit map[string]string
+	// Explode, when set, splits each input row into several output rows.
+	Explode *Explosion
+	// MaxGroupsInMemory limits how many groups an aggregate recipe keeps in
+	// memory before spilling partial results to disk. Zero uses the default.
+	MaxGroupsInMemory int
+	// Tracer, when set, is given a step-by-step trace of each recipe
+	// evaluated for the lines it asks for.
+	Tracer Tracer
+	// Filter, when set, decides which input rows are baked.
+	Filter RowFilter
+	// TypeErrorLog, when set, is told about each row left out because a
+	// value broke its column type. Rows are only left out when parse errors
+	// aren't errors; otherwise the first such value stops the bake.
+	TypeErrorLog io.Writer
+
+	state *rowState
+	// source records where each statement was found when the
+	// transformation came from ParseAll.
+	source *sourceMap
 }
 
 type TransformationResult struct {
 	HeaderLines int
-	Lines       int
+	// Lines counts the input lines processed, not including the header
+	Lines int
+	// OutputLines counts the data rows written, which differs from Lines
+	// when the recipe explodes rows
+	OutputLines int
+	// SkippedLines counts the input lines, included in Lines, that the
+	// Filter rejected or that were left out for breaking a column type
+	SkippedLines int
+	// TypeErrors counts the rows left out because a value broke its column
+	// type: input lines, also counted in SkippedLines, or for aggregate
+	// recipes, groups
+	TypeErrors int
 }
 
+// Dump writes a readable description of the transformation to w. Columns and
+// headers are listed in column order, variables in the order they are
+// evaluated and parameters and persistent initial values by name.
 func (t *Transformation) Dump(w io.Writer) {
 	_, _ = fmt.Fprintln(w, "Headers: \n=====")
-	for _, h := range t.Headers {
+	for _, key := range sortedRecipeKeys(t.Headers) {
+		h := t.Headers[key]
 		_, _ = fmt.Fprintf(w, "Header: %s\n", h.Output.Value)
 		_, _ = fmt.Fprintf(w, "pipe: ")
 		for _, p := range h.Pipe {
@@ -106,8 +165,30 @@ func (t *Transformation) Dump(w io.Writer) {
 		_, _ = fmt.Fprintf(w, "Comment: # %s\n---\n", h.Comment)
 	}
 
+	_, _ = fmt.Fprintln(w, "Parameters: \n======")
+	names := make([]string, 0, len(t.Parameters))
+	for name := range t.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := t.Parameters[name]
+		_, _ = fmt.Fprintf(w, "Param: %s\n", p.Name)
+		if p.HasDefault {
+			_, _ = fmt.Fprintf(w, "Default: %s\n---\n", p.Default)
+		} else {
+			_, _ = fmt.Fprint(w, "Required\n---\n")
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "Persistent initial values: \n======")
+	for _, name := range sortedStringKeys(t.PersistentInit) {
+		_, _ = fmt.Fprintf(w, "%s = %s\n", name, t.PersistentInit[name])
+	}
+
 	_, _ = fmt.Fprintln(w, "Variables: \n======")
-	for _, v := range t.Variables {
+	for _, name := range t.VariableOrder {
+		v := t.Variables[name]
 		_, _ = fmt.Fprintf(w, "Var: %s\n", v.Output.Value)
 		_, _ = fmt.Fprint(w, "pipe: ")
 		for _, p := range v.Pipe {
@@ -121,10 +202,28 @@ func (t *Transformation) Dump(w io.Writer) {
 		_, _ = fmt.Fprintf(w, "Comment: %s\n---\n", v.Comment)
 	}
 
+	if t.Explode != nil {
+		_, _ = fmt.Fprintf(w, "Explode on %q\n", t.Explode.Delimiter)
+		_, _ = fmt.Fprint(w, "pipe: ")
+		for _, p := range t.Explode.Recipe.Pipe {
+			_, _ = fmt.Fprint(w, p.Name+"(")
+			for _, a := range p.Arguments {
+				_, _ = fmt.Fprintf(w, "%s: %s, ", a.Type.String(), a.Value)
+			}
+			_, _ = fmt.Fprint(w, ") -> ")
+		}
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintf(w, "Comment: %s\n---\n", t.Explode.Recipe.Comment)
+	}
+
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintln(w, "Columns: \n======")
-	for _, c := range t.Columns {
+	for _, key := range sortedRecipeKeys(t.Columns) {
+		c := t.Columns[key]
 		_, _ = fmt.Fprintf(w, "Column: %s\n", c.Output.Value)
+		if c.Type != nil {
+			_, _ = fmt.Fprintf(w, "Type: %s\n", c.Type)
+		}
 		_, _ = fmt.Fprint(w, "pipe: ")
 		for _, p := range c.Pipe {
 			_, _ = fmt.Fprintf(w, p.Name+"(")
@@ -138,6 +237,24 @@ func (t *Transformation) Dump(w io.Writer) {
 	}
 }
 
+func sortedRecipeKeys(recipes map[int]Recipe) []int {
+	keys := make([]int, 0, len(recipes))
+	for k := range recipes {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func sortedStringKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (t *Transformation) AddOutputToVariable(variable string) error {
 	_, ok := t.Variables[variable]
 	if ok {
@@ -147,6 +264,15 @@ func (t *Transformation) AddOutputToVariable(variable string) error {
 	return nil
 }
 
+func (t *Transformation) AddOutputToPersistent(variable string) error {
+	_, ok := t.Variables[variable]
+	if ok {
+		return fmt.Errorf("persistent variable %s already defined", variable)
+	}
+	t.Variables[variable] = Recipe{Output: getOutputForPersistent(variable)}
+	return nil
+}
+
 func (t *Transformation) AddOutputToColumn(column string) error {
 	output := getOutputForColumn(column)
 	columnNum, _ := strconv.Atoi(column)
@@ -169,15 +295,49 @@ func (t *Transformation) AddOutputToHeader(header string) error {
 	return nil
 }
 
-func (t *Transformation) Execute(reader *csv.Reader, writer *csv.Writer, processHeader bool, lineLimit int, parseErrIsErr bool) (*TransformationResult, error) {
+// RowWriter receives the rows Execute writes. A *csv.Writer satisfies it, and
+// it can be wrapped to post-process output rows before they are written.
+type RowWriter interface {
+	Write(record []string) error
+	Flush()
+}
+
+// RowFilter decides which input rows are baked.
+type RowFilter interface {
+	// Keep reports whether the row read from input line lineNo should be
+	// baked. The header row is always processed, so the answer for it is
+	// ignored. An error stops the bake.
+	Keep(lineNo int, row []string, header bool) (bool, error)
+}
+
+func (t *Transformation) Execute(reader *csv.Reader, writer RowWriter, processHeader bool, lineLimit int, parseErrIsErr bool) (*TransformationResult, error) {
 	defer writer.Flush()
 
 	numColumns := len(t.Columns)
+	// Columns are evaluated in order, so traces and stateful functions see
+	// them the same way on every run.
+	columnOrder := sortedRecipeKeys(t.Columns)
 
 	if err := t.ValidateRecipe(); err != nil {
 		return nil, err
 	}
+	if len(t.Parameters) > 0 && t.ParameterValues == nil {
+		// Nothing was bound by the caller, so fall back to the declared defaults
+		if err := t.BindParameters(nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	t.state = t.newRowState()
 	var linesRead int
+	var outputLines int
+	var skippedLines int
+	var typeErrors int
+
+	var agg *aggregator
+	if t.IsAggregate() {
+		agg = t.newAggregator()
+		defer func() { _ = agg.discard() }()
+	}
 
 	for lineLimit <= 0 || linesRead < lineLimit {
 		row, err := reader.Read()
@@ -197,25 +357,22 @@ func (t *Transformation) Execute(reader *csv.Reader, writer *csv.Writer, process
 		}
 		linesRead++
 
-		var context = LineContext{
-			Variables: map[string]string{},
-			Columns:   map[int]string{},
-			LineNo:    linesRead,
-		}
-		// Load context with all the columns
-		for i, v := range row {
-			context.Columns[i+1] = v
-		}
-
-		// process variables
-		for _, v := range t.VariableOrder {
-			variableName := t.Variables[v].Output.Value
-			variableRecipe := t.Variables[v]
-			placeholder, err := t.processRecipe("variable", variableRecipe, context)
+		if t.Filter != nil {
+			isHeader := processHeader && linesRead == 1
+			keep, err := t.Filter.Keep(linesRead, row, isHeader)
 			if err != nil {
 				return nil, err
 			}
-			context.Variables[variableName] = placeholder
+			if !keep && !isHeader {
+				skippedLines++
+				continue
+			}
+		}
+
+		t.state.begin()
+		context := t.newLineContext(row, linesRead, processHeader && linesRead == 1)
+		if err := t.processVariables(context); err != nil {
+			return nil, err
 		}
 
 		if processHeader && linesRead == 1 {
@@ -231,7 +388,7 @@ func (t *Transformation) Execute(reader *csv.Reader, writer *csv.Writer, process
 				output[i] = value
 			}
 
-			for h := range t.Headers {
+			for _, h := range sortedRecipeKeys(t.Headers) {
 				headerRecipe := t.Headers[h]
 				placeholder, err := t.processRecipe("header", headerRecipe, context)
 				if err != nil {
@@ -247,20 +404,51 @@ func (t *Transformation) Execute(reader *csv.Reader, writer *csv.Writer, process
 		}
 
 		if !processHeader || linesRead > 1 {
-			var output = make(map[int]string)
+			elements, err := t.explodeRow(context)
+			if err != nil {
+				return nil, err
+			}
 
-			for c := range t.Columns {
-				columnRecipe := t.Columns[c]
-				placeholder, err := t.processRecipe("column", columnRecipe, context)
-				if err != nil {
-					return nil, err
+			var rows []map[int]string
+			for index, element := range elements {
+				t.setElement(context, index, element)
+
+				var output = make(map[int]string)
+
+				for _, c := range columnOrder {
+					columnRecipe := t.Columns[c]
+					placeholder, err := t.processRecipe("column", columnRecipe, context)
+					if err != nil {
+						return nil, err
+					}
+					output[c] = placeholder
 				}
-				output[c] = placeholder
+
+				if agg != nil {
+					if err := agg.add(context.LineNo, output); err != nil {
+						return nil, err
+					}
+					continue
+				}
+
+				rows = append(rows, output)
 			}
 
-			err = t.outputCsvRow(numColumns, output, writer)
-			if err != nil {
-				return nil, err
+			if err := t.checkRowTypes(context.LineNo, rows); err != nil {
+				if parseErrIsErr {
+					return nil, err
+				}
+				t.reportSkipped(err)
+				t.state.rollback()
+				skippedLines++
+				typeErrors++
+				rows = nil
+			}
+			for _, output := range rows {
+				if err := t.outputCsvRow(numColumns, output, writer); err != nil {
+					return nil, err
+				}
+				outputLines++
 			}
 		}
 
@@ -269,20 +457,85 @@ func (t *Transformation) Execute(reader *csv.Reader, writer *csv.Writer, process
 		}
 	}
 
+	if agg != nil {
+		groups, err := agg.finish(func(output map[int]string) error {
+			if err := t.checkColumnTypes(0, output); err != nil {
+				if parseErrIsErr {
+					return err
+				}
+				t.reportSkipped(err)
+				typeErrors++
+				return nil
+			}
+			return t.outputCsvRow(numColumns, output, writer)
+		})
+		if err != nil {
+			return nil, err
+		}
+		outputLines = groups - typeErrors
+	}
+
 	var headerLines int
 	if processHeader {
 		headerLines = 1
 	}
 
 	result := TransformationResult{
-		Lines:       linesRead - headerLines,
-		HeaderLines: headerLines,
+		Lines:        linesRead - headerLines,
+		HeaderLines:  headerLines,
+		OutputLines:  outputLines,
+		SkippedLines: skippedLines,
+		TypeErrors:   typeErrors,
 	}
 
 	return &result, nil
 }
 
-func (t *Transformation) outputCsvRow(numColumns int, output map[int]string, writer *csv.Writer) error {
+// newLineContext builds the context for evaluating recipes against row.
+func (t *Transformation) newLineContext(row []string, lineNo int, header bool) LineContext {
+	context := LineContext{
+		Variables:  map[string]string{},
+		Columns:    map[int]string{},
+		Parameters: t.ParameterValues,
+		Persistent: t.rowState().persistent,
+		LineNo:     lineNo,
+		Header:     header,
+	}
+	// Load context with all the columns
+	for i, v := range row {
+		context.Columns[i+1] = v
+	}
+	return context
+}
+
+// processVariables evaluates the variable recipes in the order they were
+// defined and stores their values in the context.
+func (t *Transformation) processVariables(context LineContext) error {
+	for _, v := range t.VariableOrder {
+		variableName := t.Variables[v].Output.Value
+		variableRecipe := t.Variables[v]
+		if variableRecipe.Output.Type == Persistent {
+			// persistent variables only change on data rows
+			if context.Header {
+				continue
+			}
+			placeholder, err := t.processRecipe("persistent", variableRecipe, context)
+			if err != nil {
+				return err
+			}
+			t.rowState().SetPersistent(variableName, placeholder)
+			continue
+		}
+		placeholder, err := t.processRecipe("variable", variableRecipe, context)
+		if err != nil {
+			return err
+		}
+		context.Variables[variableName] = placeholder
+	}
+	return nil
+}
+
+func (t *Transformation) outputCsvRow(numColumns int, output map[int]string, writer RowWriter) error {
 	var outputRow []string
 	for i := 1; i <= numColumns; i++ {
 		cell := output[i]
@@ -298,14 +551,26 @@ func (t *Transformation) outputCsvRow(numColumns int, output map[int]string, wri
 	return nil
 }
 
-func (t *Transformation) processRecipe(recipeType string, variable Recipe, context LineContext) (string, error) {
+func (t *Transformation) processRecipe(recipeType string, variable Recipe, context LineContext) (result string, err error) {
 	var placeholder string
 	var value string
 	mode := Replace
 
 	errorPrefix := fmt.Sprintf("line %d / %s %s:", context.LineNo, recipeType, variable.Output.Value)
 
-	for _, o := range variable.Pipe {
+	var trace *RecipeTrace
+	if t.Tracer != nil && t.Tracer.Tracing(context.LineNo) {
+		trace = &RecipeTrace{LineNo: context.LineNo, Type: recipeType, Target: variable.Output.Value}
+		defer func() {
+			trace.finish(placeholder, value, result, err)
+			t.Tracer.Trace(*trace)
+		}()
+	}
+
+	for i, o := range variable.Pipe {
+		if trace != nil {
+			trace.step(o, context, placeholder, value, mode)
+		}
 		opName := strings.ToLower(o.Name)
 		switch opName {
 		case "value":
@@ -654,6 +919,40 @@ func (t *Transformation) processRecipe(recipeType string, variable Recipe, conte
 				return "", fmt.Errorf("%s %s(): %v", errorPrefix, opName, err)
 			}
 			value = result
+		case "count", "sum", "min", "max", "avg", "first", "last", "countdistinct":
+			// aggregate functions pass the value through; it is combined
+			// with the rest of its group once every row has been read
+			args, err := processArgs(1, o.Arguments, context, placeholder)
+			if err != nil {
+				return "", fmt.Errorf("%s %s(): error evaluating arg: %v", errorPrefix, opName, err)
+			}
+			value = args[0]
+		case "prev", "runningsum", "filldown", "counter":
+			args, err := processArgs(1, o.Arguments, context, placeholder)
+			if err != nil {
+				return "", fmt.Errorf("%s %s(): error evaluating arg: %v", errorPrefix, opName, err)
+			}
+			if context.Header {
+				// the header row passes values through without touching the state
+				value = args[0]
+				break
+			}
+			state := t.rowState()
+			site := fmt.Sprintf("%s %s #%d", recipeType, variable.Output.Value, i)
+			switch opName {
+			case "prev":
+				value = state.Prev(site, args[0])
+			case "runningsum":
+				result, err := state.RunningSum(site, args[0])
+				if err != nil {
+					return "", fmt.Errorf("%s %s(): %v", errorPrefix, opName, err)
+				}
+				value = result
+			case "filldown":
+				value = state.FillDown(site, args[0])
+			case "counter":
+				value = state.Counter(site, args[0])
+			}
 		// TODO make function calling more smart, using the allFuncs thing
 		default:
 			return "", fmt.Errorf("%s error: processing variable, unimplemented operation %s", errorPrefix, o.Name)
@@ -749,44 +1048,62 @@ func (t *Transformation) AddOperationToHeader(header string, operation Operation
 
 func (t *Transformation) AddOperationByType(targetType DataType, target string, operation Operation) {
 	switch targetType {
-	case Variable:
+	case Variable, Persistent:
 		t.AddOperationToVariable(target, operation)
 	case Column:
 		t.AddOperationToColumn(target, operation)
 	case Header:
 		t.AddOperationToHeader(target, operation)
+	case Explode:
+		t.AddOperationToExplode(operation)
 	}
 }
 
 func (t *Transformation) ValidateRecipe() error {
+	for _, d := range t.Validate() {
+		if d.Severity == SeverityError {
+			return errors.New(d.Message)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the recipe is complete and returns every problem it
+// finds, rather than just the first as ValidateRecipe does.
+func (t *Transformation) Validate() []Diagnostic {
+	var diagnostics []Diagnostic
 	numColumns := len(t.Columns)
 
 	// recipe with no columns is pointless/invalid
 	if numColumns == 0 {
-		return errors.New("no column recipes provided")
+		diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Message: "no column recipes provided"})
 	}
 
 	// validate all columns are specified
 	for c := 1; c <= numColumns; c++ {
 		if _, ok := t.Columns[c]; !ok {
-			return fmt.Errorf("missing column definition for column #%d", c)
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("missing column definition for column #%d", c)})
 		}
 	}
 
 	// ensure there are not header recipes for a column we don't have
-	for h := range t.Headers {
+	for _, h := range sortedRecipeKeys(t.Headers) {
 		if _, ok := t.Columns[h]; !ok {
-			return fmt.Errorf("found header for column %d, but no recipe for column %d", h, h)
+			diagnostics = append(diagnostics, t.diagnostic(t.Headers[h].Output, -1, "found header for column %d, but no recipe for column %d", h, h))
 		}
 	}
 
-	return nil
+	return append(diagnostics, t.aggregateDiagnostics()...)
 }
 
 type LineContext struct {
-	Variables map[string]string
-	Columns   map[int]string
-	LineNo    int
+	Variables  map[string]string
+	Columns    map[int]string
+	Parameters map[string]string
+	Persistent map[string]string
+	LineNo     int
+	// Header is true while the header row is being processed
+	Header bool
 }
 
 func NewTransformation() *Transformation {
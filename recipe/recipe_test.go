@@ -3,6 +3,7 @@ package recipe
 import (
 	"bytes"
 	"encoding/csv"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -800,3 +801,63 @@ func TestTransformation_Execute(t1 *testing.T) {
 		})
 	}
 }
+
+func TestTransformation_ExecuteCountsExplodedRows(t *testing.T) {
+	transformation, err := Parse(strings.NewReader("explode(\";\") <- 2\n1 <- 1\n2 <- $element\n"))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	var b bytes.Buffer
+	result, err := transformation.Execute(csv.NewReader(strings.NewReader("id,tags\n1,a;b;c\n2,d\n")), csv.NewWriter(&b), true, -1, false)
+	if err != nil {
+		t.Fatalf("unexpected execute error: %v", err)
+	}
+	want := TransformationResult{HeaderLines: 1, Lines: 2, OutputLines: 4}
+	if *result != want {
+		t.Errorf("Execute() result = %+v, want %+v", *result, want)
+	}
+}
+
+func TestTransformation_ExecuteAggregateSpillsToDisk(t *testing.T) {
+	recipe := "1 <- 1\n2 <- count\n3 <- sum(2)\n4 <- first(3)\n5 <- last(3)\n6 <- countDistinct(3)\n"
+	input := "b,1,p\na,2,q\nc,3,r\na,4,s\nb,5,p\nc,6,t\na,7,q\n"
+	want := "a,3,13,q,q,2\nb,2,6,p,p,1\nc,2,9,r,t,2\n"
+
+	for _, maxGroups := range []int{0, 1, 2} {
+		transformation, err := Parse(strings.NewReader(recipe))
+		if err != nil {
+			t.Fatalf("unexpected parse error: %v", err)
+		}
+		transformation.MaxGroupsInMemory = maxGroups
+
+		var b bytes.Buffer
+		result, err := transformation.Execute(csv.NewReader(strings.NewReader(input)), csv.NewWriter(&b), false, -1, false)
+		if err != nil {
+			t.Fatalf("max groups %d: unexpected execute error: %v", maxGroups, err)
+		}
+		if got := b.String(); got != want {
+			t.Errorf("max groups %d: Execute() = %q, want %q", maxGroups, got, want)
+		}
+		if result.Lines != 7 || result.OutputLines != 3 {
+			t.Errorf("max groups %d: result = %+v, want 7 lines and 3 output lines", maxGroups, *result)
+		}
+	}
+}
+
+func TestTransformation_ExecuteAggregateRemovesSpillOnError(t *testing.T) {
+	dir := useTempDir(t)
+	transformation, err := Parse(strings.NewReader("1 <- 1\n2 <- sum(2)\n"))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	transformation.MaxGroupsInMemory = 1
+
+	var b bytes.Buffer
+	_, err = transformation.Execute(csv.NewReader(strings.NewReader("a,1\nb,2\nc,3\nd,x\n")), csv.NewWriter(&b), false, -1, false)
+	if err == nil {
+		t.Fatal("expected an execute error for the value that isn't a number")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Execute() left %d temporary files", len(entries))
+	}
+}
@@ -0,0 +1,213 @@
+package recipe
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dstockto/csv-chef/extsort"
+)
+
+// Collation controls how the values of a sort key are compared.
+type Collation string
+
+const (
+	StringCollation  Collation = "string"
+	NumericCollation Collation = "numeric"
+	DateCollation    Collation = "date"
+)
+
+// SortKey is one key used to order output rows. Keys are compared in the
+// order given; later keys only break ties in earlier ones.
+type SortKey struct {
+	Expression *Expression
+	Descending bool
+	Collation  Collation
+}
+
+// ParseSortKey reads a sort key of the form `expression[:modifier...]`
+// where each modifier is one of asc, desc, string, numeric or date. For
+// example "5:numeric:desc" or "2 -> lowercase". Keys default to ascending
+// string order.
+func ParseSortKey(spec string) (SortKey, error) {
+	key := SortKey{Collation: StringCollation}
+	parts := strings.Split(spec, ":")
+	var direction, collation string
+modifiers:
+	for len(parts) > 1 {
+		modifier := strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))
+		switch modifier {
+		case "asc", "desc":
+			if direction != "" {
+				return key, fmt.Errorf("sort key '%s' has more than one direction", spec)
+			}
+			direction = modifier
+		case "string", "numeric", "date":
+			if collation != "" {
+				return key, fmt.Errorf("sort key '%s' has more than one collation", spec)
+			}
+			collation = modifier
+		default:
+			// not a modifier, so the colon is part of the expression
+			break modifiers
+		}
+		parts = parts[:len(parts)-1]
+	}
+	key.Descending = direction == "desc"
+	if collation != "" {
+		key.Collation = Collation(collation)
+	}
+
+	expression, err := NewExpression(strings.Join(parts, ":"))
+	if err != nil {
+		return key, fmt.Errorf("sort key '%s': %v", spec, err)
+	}
+	key.Expression = expression
+	return key, nil
+}
+
+// sortValue turns a key value into the form compared while sorting. Numbers
+// and dates are kept as numbers; values that can't be read as one are
+// prefixed so they sort after every value that can.
+func (k SortKey) sortValue(value string) string {
+	switch k.Collation {
+	case NumericCollation:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return "n" + strings.TrimSpace(value)
+		}
+		return "x" + value
+	case DateCollation:
+		if value != "" {
+			if normalized, err := SmartDate(value); err == nil {
+				if d, err := time.Parse(time.RFC3339, normalized); err == nil {
+					return "n" + strconv.FormatInt(d.Unix(), 10)
+				}
+			}
+		}
+		return "x" + value
+	}
+	return value
+}
+
+// compare returns -1, 0 or 1 for two values produced by sortValue, in the
+// key's direction. Unreadable numbers and dates sort last in both
+// directions.
+func (k SortKey) compare(a, b string) int {
+	if k.Collation != StringCollation && a[0] != b[0] {
+		// numbers ("n") sort before unreadable values ("x")
+		return strings.Compare(a[:1], b[:1])
+	}
+	c := k.compareValues(a, b)
+	if k.Descending {
+		return -c
+	}
+	return c
+}
+
+// compareValues compares two values of the same kind in ascending order.
+func (k SortKey) compareValues(a, b string) int {
+	if k.Collation == StringCollation || a[0] == 'x' {
+		return strings.Compare(a, b)
+	}
+	af, _ := strconv.ParseFloat(a[1:], 64)
+	bf, _ := strconv.ParseFloat(b[1:], 64)
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+// RowSorter is a RowWriter that sorts output rows by one or more keys. Rows
+// are held back until Close is called and are spilled to temporary files
+// once there are too many to keep in memory, so files of any size can be
+// sorted. The sort is stable: rows with equal keys keep their original
+// order.
+type RowSorter struct {
+	writer RowWriter
+	keys   []SortKey
+	header bool
+	sorter *extsort.Sorter
+	lineNo int
+}
+
+// NewRowSorter returns a RowSorter writing to writer. If header is true the
+// first row written is passed through untouched. maxInMemory limits how many
+// rows are buffered before spilling to disk; zero uses the default.
+func NewRowSorter(writer RowWriter, keys []SortKey, header bool, maxInMemory int) *RowSorter {
+	s := &RowSorter{
+		writer: writer,
+		keys:   keys,
+		header: header,
+	}
+	s.sorter = extsort.New(s.less, maxInMemory, "")
+	return s
+}
+
+// records are [key values..., row...]
+func (s *RowSorter) less(a, b []string) bool {
+	for i, key := range s.keys {
+		if c := key.compare(a[i], b[i]); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// Write records a row, or writes it straight through if it is the header.
+func (s *RowSorter) Write(record []string) error {
+	if s.header {
+		s.header = false
+		return s.writer.Write(record)
+	}
+	s.lineNo++
+	sortRecord := make([]string, 0, len(s.keys)+len(record))
+	for _, key := range s.keys {
+		value, err := key.Expression.Evaluate(record, s.lineNo)
+		if err != nil {
+			return fmt.Errorf("sort key: %v", err)
+		}
+		sortRecord = append(sortRecord, key.sortValue(value))
+	}
+	return s.sorter.Add(append(sortRecord, record...))
+}
+
+// Flush flushes the underlying writer. Rows held for sorting are not written
+// until Close.
+func (s *RowSorter) Flush() {
+	s.writer.Flush()
+}
+
+// Discard drops the held rows without writing them and removes their
+// temporary files. It does nothing after Close, so it can be deferred to
+// clean up when the bake fails.
+func (s *RowSorter) Discard() error {
+	return s.sorter.Discard()
+}
+
+// Close writes the held rows to the underlying writer in sorted order.
+func (s *RowSorter) Close() error {
+	it, err := s.sorter.Sort()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = it.Close() }()
+	for {
+		record, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.writer.Write(record[len(s.keys):]); err != nil {
+			return err
+		}
+	}
+	s.writer.Flush()
+	return nil
+}
@@ -0,0 +1,168 @@
+package recipe
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseSortKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		spec           string
+		wantSource     string
+		wantDescending bool
+		wantCollation  Collation
+		wantErr        bool
+	}{
+		{name: "plain column", spec: "3", wantSource: "3", wantCollation: StringCollation},
+		{name: "descending numeric", spec: "3:numeric:desc", wantSource: "3", wantDescending: true, wantCollation: NumericCollation},
+		{name: "modifiers in any order", spec: "2:DESC:date", wantSource: "2", wantDescending: true, wantCollation: DateCollation},
+		{name: "expression", spec: "2 -> lowercase:asc", wantSource: "2 -> lowercase", wantCollation: StringCollation},
+		{name: "colon inside expression", spec: `1 + ":" + 2`, wantSource: `1 + ":" + 2`, wantCollation: StringCollation},
+		{name: "two directions", spec: "1:asc:desc", wantErr: true},
+		{name: "two collations", spec: "1:date:numeric", wantErr: true},
+		{name: "bad expression", spec: "1 -> nosuchfunc:desc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSortKey(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSortKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Expression.Source != tt.wantSource {
+				t.Errorf("ParseSortKey() source = %q, want %q", got.Expression.Source, tt.wantSource)
+			}
+			if got.Descending != tt.wantDescending {
+				t.Errorf("ParseSortKey() descending = %v, want %v", got.Descending, tt.wantDescending)
+			}
+			if got.Collation != tt.wantCollation {
+				t.Errorf("ParseSortKey() collation = %v, want %v", got.Collation, tt.wantCollation)
+			}
+		})
+	}
+}
+
+func TestRowSorter(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        []string
+		header      bool
+		maxInMemory int
+		input       string
+		want        string
+	}{
+		{
+			name:  "string order",
+			keys:  []string{"1"},
+			input: "b\na\nc\n10\n9\n",
+			want:  "10\n9\na\nb\nc\n",
+		},
+		{
+			name:  "numeric order with unreadable values last",
+			keys:  []string{"1:numeric"},
+			input: "10\nn/a\n9\n-1.5\n \n",
+			want:  "-1.5\n9\n10\n\" \"\nn/a\n",
+		},
+		{
+			name:  "descending numeric",
+			keys:  []string{"1:numeric:desc"},
+			input: "10\n9\n100\n",
+			want:  "100\n10\n9\n",
+		},
+		{
+			name:  "descending numeric with unreadable values last",
+			keys:  []string{"1:numeric:desc"},
+			input: "n/a\n10\nbad\n9\n100\n",
+			want:  "100\n10\n9\nn/a\nbad\n",
+		},
+		{
+			name:  "descending date with unreadable values last",
+			keys:  []string{"1:date:desc"},
+			input: "soon\n2020-12-25\n2021-03-01\n",
+			want:  "2021-03-01\n2020-12-25\nsoon\n",
+		},
+		{
+			name:  "date order",
+			keys:  []string{"1:date"},
+			input: "2021-03-01\n2020-12-25\n2021-01-15\n",
+			want:  "2020-12-25\n2021-01-15\n2021-03-01\n",
+		},
+		{
+			name:   "multiple keys keep header first and are stable",
+			keys:   []string{"1", "2 -> lowercase"},
+			header: true,
+			input:  "zip,last,id\n90210,smith,1\n10001,Jones,2\n90210,adams,3\n10001,jones,4\n",
+			want:   "zip,last,id\n10001,Jones,2\n10001,jones,4\n90210,adams,3\n90210,smith,1\n",
+		},
+		{
+			name:        "spills to disk",
+			keys:        []string{"1:numeric"},
+			maxInMemory: 2,
+			input:       "5\n3\n1\n4\n2\n",
+			want:        "1\n2\n3\n4\n5\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []SortKey
+			for _, spec := range tt.keys {
+				key, err := ParseSortKey(spec)
+				if err != nil {
+					t.Fatalf("ParseSortKey() unexpected error: %v", err)
+				}
+				keys = append(keys, key)
+			}
+			var b bytes.Buffer
+			s := NewRowSorter(csv.NewWriter(&b), keys, tt.header, tt.maxInMemory)
+
+			rows, err := csv.NewReader(strings.NewReader(tt.input)).ReadAll()
+			if err != nil {
+				t.Fatalf("unexpected input error: %v", err)
+			}
+			for _, row := range rows {
+				if err := s.Write(row); err != nil {
+					t.Fatalf("Write() unexpected error: %v", err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() unexpected error: %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("RowSorter wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowSorter_Discard(t *testing.T) {
+	dir := useTempDir(t)
+	var b bytes.Buffer
+	key, err := ParseSortKey("1")
+	if err != nil {
+		t.Fatalf("ParseSortKey() error = %v", err)
+	}
+	s := NewRowSorter(csv.NewWriter(&b), []SortKey{key}, false, 1)
+	for _, record := range [][]string{{"b"}, {"a"}, {"c"}} {
+		if err := s.Write(record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) == 0 {
+		t.Fatalf("expected rows to be spilled to %s", dir)
+	}
+	if err := s.Discard(); err != nil {
+		t.Fatalf("Discard() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Discard() left %d temporary files", len(entries))
+	}
+	if b.Len() != 0 {
+		t.Errorf("Discard() wrote %q", b.String())
+	}
+}
@@ -0,0 +1,158 @@
+package recipe
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// rowState holds the values that carry over from one input row to the next
+// while Execute runs: persistent (@) variables and the memory of the
+// stateful functions prev, runningSum, fillDown and counter. Each stateful
+// function call in a recipe keeps its own memory, keyed by where it appears.
+//
+// Only data rows update the state. The header row and rows skipped because
+// of CSV parse errors leave it untouched, and a row left out for breaking a
+// column type has its changes undone.
+type rowState struct {
+	persistent map[string]string
+	previous   map[string]string
+	sums       map[string]float64
+	filled     map[string]string
+	counters   map[string]map[string]int
+	// undo puts back, newest last, the values changed since begin.
+	undo []func()
+}
+
+// newRowState builds the state for the start of a run. Persistent variables
+// that were not given a starting value start out empty.
+func (t *Transformation) newRowState() *rowState {
+	state := &rowState{
+		persistent: make(map[string]string),
+		previous:   make(map[string]string),
+		sums:       make(map[string]float64),
+		filled:     make(map[string]string),
+		counters:   make(map[string]map[string]int),
+	}
+	for name, recipe := range t.Variables {
+		if recipe.Output.Type == Persistent {
+			state.persistent[name] = ""
+		}
+	}
+	for name, value := range t.PersistentInit {
+		state.persistent[name] = value
+	}
+	return state
+}
+
+// rowState returns the state for the current Execute run, creating a fresh
+// one if the recipe is evaluated outside of Execute.
+func (t *Transformation) rowState() *rowState {
+	if t.state == nil {
+		t.state = t.newRowState()
+	}
+	return t.state
+}
+
+// begin starts a row, forgetting how to undo the changes of earlier rows.
+func (s *rowState) begin() {
+	s.undo = s.undo[:0]
+}
+
+// rollback undoes the changes made since begin.
+func (s *rowState) rollback() {
+	for i := len(s.undo) - 1; i >= 0; i-- {
+		s.undo[i]()
+	}
+	s.undo = s.undo[:0]
+}
+
+// remember records how to put back the value of key in m.
+func remember(s *rowState, m map[string]string, key string) {
+	old, ok := m[key]
+	s.undo = append(s.undo, func() {
+		if ok {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// SetPersistent sets a persistent variable.
+func (s *rowState) SetPersistent(name string, value string) {
+	remember(s, s.persistent, name)
+	s.persistent[name] = value
+}
+
+// Prev returns the value seen at this call site on the previous data row and
+// remembers the current value. The first data row gets an empty string.
+func (s *rowState) Prev(site string, value string) string {
+	previous := s.previous[site]
+	remember(s, s.previous, site)
+	s.previous[site] = value
+	return previous
+}
+
+// RunningSum adds value to the total kept for this call site and returns the
+// new total. An empty value counts as zero.
+func (s *rowState) RunningSum(site string, value string) (string, error) {
+	var num float64
+	if value != "" {
+		var err error
+		num, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("input is not numeric: got '%s'", value)
+		}
+	}
+	old, ok := s.sums[site]
+	s.undo = append(s.undo, func() {
+		if ok {
+			s.sums[site] = old
+		} else {
+			delete(s.sums, site)
+		}
+	})
+	s.sums[site] += num
+	return fmt.Sprintf("%f", s.sums[site]), nil
+}
+
+// FillDown returns value if it is not empty, otherwise the last non-empty
+// value seen at this call site.
+func (s *rowState) FillDown(site string, value string) string {
+	if value != "" {
+		remember(s, s.filled, site)
+		s.filled[site] = value
+		return value
+	}
+	return s.filled[site]
+}
+
+// Counter returns how many data rows so far, including this one, had the
+// given key at this call site.
+func (s *rowState) Counter(site string, key string) string {
+	counts, ok := s.counters[site]
+	if !ok {
+		counts = make(map[string]int)
+		s.counters[site] = counts
+	}
+	counts[key]++
+	s.undo = append(s.undo, func() {
+		if counts[key]--; counts[key] == 0 {
+			delete(counts, key)
+		}
+	})
+	return strconv.Itoa(counts[key])
+}
+
+// InitPersistent sets the starting value for a persistent variable.
+// Initializing the same variable twice is an error.
+func (t *Transformation) InitPersistent(name string, value string) error {
+	if t.PersistentInit == nil {
+		t.PersistentInit = make(map[string]string)
+	}
+	if _, ok := t.PersistentInit[name]; ok {
+		return fmt.Errorf("persistent variable %s already initialized", name)
+	}
+	t.PersistentInit[name] = value
+	return nil
+}
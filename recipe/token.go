@@ -22,4 +22,8 @@ const (
 	CLOSE_PAREN       //14 - )
 	COMMA             //15 - ,
 	HEADER            //16 - !<digits>
+	PARAMETER         //17 - starts w/ %
+	EQUALS            //18 - =
+	PERSISTENT        //19 - starts w/ @
+	COLON             //20 - :
 )
@@ -25,11 +25,15 @@ func _() {
 	_ = x[CLOSE_PAREN-14]
 	_ = x[COMMA-15]
 	_ = x[HEADER-16]
+	_ = x[PARAMETER-17]
+	_ = x[EQUALS-18]
+	_ = x[PERSISTENT-19]
+	_ = x[COLON-20]
 }
 
-const _Token_name = "ILLEGALEOFWSNEWLINECOLUMN_IDASSIGNMENTPIPECOMMENTPLACEHOLDERPLUSLITERALVARIABLEFUNCTIONOPEN_PARENCLOSE_PARENCOMMAHEADER"
+const _Token_name = "ILLEGALEOFWSNEWLINECOLUMN_IDASSIGNMENTPIPECOMMENTPLACEHOLDERPLUSLITERALVARIABLEFUNCTIONOPEN_PARENCLOSE_PARENCOMMAHEADERPARAMETEREQUALSPERSISTENTCOLON"
 
-var _Token_index = [...]uint8{0, 7, 10, 12, 19, 28, 38, 42, 49, 60, 64, 71, 79, 87, 97, 108, 113, 119}
+var _Token_index = [...]uint8{0, 7, 10, 12, 19, 28, 38, 42, 49, 60, 64, 71, 79, 87, 97, 108, 113, 119, 128, 134, 144, 149}
 
 func (i Token) String() string {
 	if i < 0 || i >= Token(len(_Token_index)-1) {
@@ -0,0 +1,140 @@
+package recipe
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tracer receives a step-by-step account of how recipes were evaluated.
+type Tracer interface {
+	// Tracing reports whether recipes evaluated for the input line should be
+	// traced.
+	Tracing(lineNo int) bool
+	Trace(trace RecipeTrace)
+}
+
+// TraceArgument is an operation argument and the value it resolved to.
+type TraceArgument struct {
+	Argument Argument
+	Value    string
+	Err      error
+}
+
+// TraceStep is one operation of a pipe. Mode is the join mode in effect when
+// the operation ran; in Join mode its value is appended to the placeholder
+// instead of replacing it.
+type TraceStep struct {
+	Operation Operation
+	Arguments []TraceArgument
+	Mode      JoinMode
+	Before    string
+	Value     string
+	After     string
+	Err       error
+}
+
+// RecipeTrace is the evaluation of one recipe for one input line.
+type RecipeTrace struct {
+	LineNo int
+	Type   string
+	Target string
+	Steps  []TraceStep
+	Result string
+	Err    error
+}
+
+func (r *RecipeTrace) closeStep(placeholder, value string) {
+	if len(r.Steps) == 0 {
+		return
+	}
+	last := &r.Steps[len(r.Steps)-1]
+	last.Value = value
+	last.After = placeholder
+}
+
+// step closes the previous step and starts a new one for o.
+func (r *RecipeTrace) step(o Operation, context LineContext, placeholder, value string, mode JoinMode) {
+	r.closeStep(placeholder, value)
+	step := TraceStep{Operation: o, Mode: mode, Before: placeholder}
+	for _, arg := range o.Arguments {
+		resolved, err := arg.GetValue(context, placeholder)
+		step.Arguments = append(step.Arguments, TraceArgument{Argument: arg, Value: resolved, Err: err})
+	}
+	r.Steps = append(r.Steps, step)
+}
+
+func (r *RecipeTrace) finish(placeholder, value, result string, err error) {
+	if err != nil && len(r.Steps) > 0 {
+		r.Steps[len(r.Steps)-1].Err = err
+	} else {
+		r.closeStep(placeholder, value)
+	}
+	r.Result = result
+	r.Err = err
+}
+
+// describeArgument names an argument the way it is written in a recipe.
+func describeArgument(a Argument) string {
+	switch a.Type {
+	case Column:
+		return "column " + a.Value
+	case Literal:
+		return fmt.Sprintf("literal %q", a.Value)
+	case Placeholder:
+		return "placeholder"
+	}
+	return a.Value
+}
+
+// WriteTrace writes a readable account of a recipe trace to w.
+func WriteTrace(w io.Writer, trace RecipeTrace) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "line %d / %s %s\n", trace.LineNo, trace.Type, trace.Target)
+	for i, step := range trace.Steps {
+		fmt.Fprintf(&b, "  step %d: %s\n", i+1, step.Operation.Name)
+		for j, arg := range step.Arguments {
+			if arg.Err != nil {
+				fmt.Fprintf(&b, "    arg %d: %s = error: %v\n", j+1, describeArgument(arg.Argument), arg.Err)
+				continue
+			}
+			fmt.Fprintf(&b, "    arg %d: %s = %q\n", j+1, describeArgument(arg.Argument), arg.Value)
+		}
+		fmt.Fprintf(&b, "    mode: %s\n", step.Mode)
+		if step.Err != nil {
+			fmt.Fprintf(&b, "    placeholder: %q\n", step.Before)
+			fmt.Fprintf(&b, "    error: %v\n", step.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "    placeholder: %q -> %q\n", step.Before, step.After)
+	}
+	if trace.Err == nil {
+		fmt.Fprintf(&b, "  result: %q\n", trace.Result)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type lineTracer struct {
+	w     io.Writer
+	lines map[int]bool
+}
+
+// NewLineTracer returns a Tracer that writes traces for the given input line
+// numbers to w. Line 1 is the first line of the input, which is the header
+// row when headers are processed.
+func NewLineTracer(w io.Writer, lines ...int) Tracer {
+	tracer := &lineTracer{w: w, lines: make(map[int]bool)}
+	for _, line := range lines {
+		tracer.lines[line] = true
+	}
+	return tracer
+}
+
+func (l *lineTracer) Tracing(lineNo int) bool {
+	return l.lines[lineNo]
+}
+
+func (l *lineTracer) Trace(trace RecipeTrace) {
+	_ = WriteTrace(l.w, trace)
+}
@@ -0,0 +1,122 @@
+package recipe
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestTransformation_ExecuteTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  string
+		input   string
+		lines   []int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "variable and join",
+			recipe: "$f <- 1 -> trim\n1 <- $f + \" \" + 2\n2 <- 2\n",
+			input:  "first,last\n bob ,smith\nann,jones\n",
+			lines:  []int{2},
+			want: `line 2 / variable $f
+  step 1: value
+    arg 1: column 1 = " bob "
+    mode: Replace
+    placeholder: "" -> " bob "
+  step 2: trim
+    arg 1: placeholder = " bob "
+    mode: Replace
+    placeholder: " bob " -> "bob"
+  result: "bob"
+line 2 / column 1
+  step 1: value
+    arg 1: $f = "bob"
+    mode: Replace
+    placeholder: "" -> "bob"
+  step 2: join
+    arg 1: placeholder = "bob"
+    mode: Replace
+    placeholder: "bob" -> "bob"
+  step 3: value
+    arg 1: literal " " = " "
+    mode: Join
+    placeholder: "bob" -> "bob "
+  step 4: join
+    arg 1: placeholder = "bob "
+    mode: Replace
+    placeholder: "bob " -> "bob "
+  step 5: value
+    arg 1: column 2 = "smith"
+    mode: Join
+    placeholder: "bob " -> "bob smith"
+  result: "bob smith"
+line 2 / column 2
+  step 1: value
+    arg 1: column 2 = "smith"
+    mode: Replace
+    placeholder: "" -> "smith"
+  result: "smith"
+`,
+		},
+		{
+			name:   "header line",
+			recipe: "1 <- 1\n!1 <- \"Name\"\n",
+			input:  "first\nbob\n",
+			lines:  []int{1},
+			want: `line 1 / header 1
+  step 1: value
+    arg 1: literal "Name" = "Name"
+    mode: Replace
+    placeholder: "" -> "Name"
+  result: "Name"
+`,
+		},
+		{
+			name:   "error step",
+			recipe: "1 <- 1 -> add(\"1\")\n",
+			input:  "n\nx\n",
+			lines:  []int{2},
+			want: `line 2 / column 1
+  step 1: value
+    arg 1: column 1 = "x"
+    mode: Replace
+    placeholder: "" -> "x"
+  step 2: add
+    arg 1: literal "1" = "1"
+    arg 2: placeholder = "x"
+    mode: Replace
+    placeholder: "x"
+    error: line 2 / column 1: add(): second arg to Add was not numeric: x
+`,
+			wantErr: true,
+		},
+		{
+			name:   "untraced line",
+			recipe: "1 <- 1\n",
+			input:  "n\nx\n",
+			lines:  []int{5},
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformation, err := Parse(strings.NewReader(tt.recipe))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			var trace bytes.Buffer
+			transformation.Tracer = NewLineTracer(&trace, tt.lines...)
+			_, err = transformation.Execute(csv.NewReader(strings.NewReader(tt.input)), csv.NewWriter(ioutil.Discard), true, -1, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := trace.String(); got != tt.want {
+				t.Errorf("trace =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,11 @@ package recipe
 
 import "strconv"
 
-// MaxInputColumnReferenced scans every recipe (Variables, Columns and
-// Headers), every Operation in each recipe's Pipe and every Argument. For
-// arguments whose Type is Column, it parses the Value as an integer and
-// tracks the highest column number referenced. It returns 0 if no input
-// columns are referenced.
+// MaxInputColumnReferenced scans every recipe (Variables, Columns, Headers
+// and the explode recipe), every Operation in each recipe's Pipe and every
+// Argument. For arguments whose Type is Column, it parses the Value as an
+// integer and tracks the highest column number referenced. It returns 0 if
+// no input columns are referenced.
 func (t *Transformation) MaxInputColumnReferenced() int {
 	max := 0
 
@@ -21,6 +21,9 @@ func (t *Transformation) MaxInputColumnReferenced() int {
 	}
 	check(t.Columns)
 	check(t.Headers)
+	if t.Explode != nil {
+		scanRecipe(t.Explode.Recipe, &max)
+	}
 
 	return max
 }
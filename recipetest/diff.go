@@ -0,0 +1,64 @@
+package recipetest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CellDiff is a single difference between expected and actual output. Row
+// and Column are 1-based; Column is 0 when a whole row is missing or extra.
+type CellDiff struct {
+	Row      int
+	Column   int
+	Expected string
+	Actual   string
+}
+
+func (d CellDiff) String() string {
+	if d.Column == 0 {
+		if d.Actual == "" {
+			return fmt.Sprintf("row %d: missing row %s", d.Row, d.Expected)
+		}
+		return fmt.Sprintf("row %d: unexpected row %s", d.Row, d.Actual)
+	}
+	return fmt.Sprintf("row %d, column %d: expected %q, got %q", d.Row, d.Column, d.Expected, d.Actual)
+}
+
+// Diff compares expected and actual rows cell by cell. Rows present in only
+// one of them are reported as a whole, as are cells present in only one
+// version of a row.
+func Diff(expected, actual [][]string) []CellDiff {
+	var diffs []CellDiff
+	for r := 0; r < len(expected) || r < len(actual); r++ {
+		switch {
+		case r >= len(actual):
+			diffs = append(diffs, CellDiff{Row: r + 1, Expected: formatRow(expected[r])})
+			continue
+		case r >= len(expected):
+			diffs = append(diffs, CellDiff{Row: r + 1, Actual: formatRow(actual[r])})
+			continue
+		}
+		want, got := expected[r], actual[r]
+		for c := 0; c < len(want) || c < len(got); c++ {
+			var w, g string
+			if c < len(want) {
+				w = want[c]
+			}
+			if c < len(got) {
+				g = got[c]
+			}
+			if c >= len(want) || c >= len(got) || w != g {
+				diffs = append(diffs, CellDiff{Row: r + 1, Column: c + 1, Expected: w, Actual: g})
+			}
+		}
+	}
+	return diffs
+}
+
+func formatRow(row []string) string {
+	quoted := make([]string, len(row))
+	for i, cell := range row {
+		quoted[i] = fmt.Sprintf("%q", cell)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
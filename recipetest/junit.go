@@ -0,0 +1,106 @@
+package recipetest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Suite is the results of every case for one recipe.
+type Suite struct {
+	Recipe  string
+	Results []Result
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the results as a JUnit XML report, with one test suite
+// per recipe and one test case per recipe test case.
+func WriteJUnit(w io.Writer, suites []Suite) error {
+	report := junitTestSuites{}
+	var total time.Duration
+	for _, suite := range suites {
+		js := junitTestSuite{Name: suite.Recipe}
+		var suiteTime time.Duration
+		for _, result := range suite.Results {
+			tc := junitTestCase{
+				Name:      result.Case.Name,
+				ClassName: suite.Recipe,
+				File:      result.Case.Source,
+				Time:      seconds(result.Duration),
+			}
+			switch {
+			case result.Err != nil:
+				tc.Error = &junitMessage{Message: result.Err.Error(), Body: result.Err.Error()}
+				js.Errors++
+			case len(result.Diffs) > 0:
+				lines := make([]string, len(result.Diffs))
+				for i, d := range result.Diffs {
+					lines[i] = d.String()
+				}
+				tc.Failure = &junitMessage{
+					Message: fmt.Sprintf("%d cell(s) differ from the expected output", len(result.Diffs)),
+					Body:    strings.Join(lines, "\n"),
+				}
+				js.Failures++
+			}
+			js.Tests++
+			suiteTime += result.Duration
+			js.TestCases = append(js.TestCases, tc)
+		}
+		js.Time = seconds(suiteTime)
+		report.Tests += js.Tests
+		report.Failures += js.Failures
+		report.Errors += js.Errors
+		total += suiteTime
+		report.Suites = append(report.Suites, js)
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,193 @@
+// Package recipetest runs recipe test cases: an input CSV is baked with a
+// recipe and the output is compared, cell by cell, with the expected CSV.
+//
+// Test cases live next to the recipe they test. For a recipe named
+// clean.txt, the fixture pair clean.in.csv and clean.out.csv is one case and
+// every pair clean.<name>.in.csv and clean.<name>.out.csv is another. Cases
+// may also be written inline in clean.test.yaml (or clean.test.yml):
+//
+//	cases:
+//	  - name: uppercases names
+//	    set:
+//	      client: acme
+//	    input: |
+//	      name
+//	      bob
+//	    output: |
+//	      NAME
+//	      BOB
+package recipetest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dstockto/csv-chef/recipe"
+	"gopkg.in/yaml.v2"
+)
+
+// Case is a single recipe test: the recipe is run against Input and the
+// result must match Expected exactly.
+type Case struct {
+	Name       string            `yaml:"name"`
+	Input      string            `yaml:"input"`
+	Expected   string            `yaml:"output"`
+	Parameters map[string]string `yaml:"set"`
+	NoHeader   bool              `yaml:"no_header"`
+	Lines      int               `yaml:"lines"`
+	// Source is the file the case was read from.
+	Source string `yaml:"-"`
+}
+
+type testFile struct {
+	Cases []Case `yaml:"cases"`
+}
+
+// Discover finds the test cases for the recipe at recipePath. Cases are
+// returned sorted by name. A fixture input without a matching output file is
+// an error.
+func Discover(recipePath string) ([]Case, error) {
+	dir := filepath.Dir(recipePath)
+	base := filepath.Base(recipePath)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	var cases []Case
+	inputs, err := filepath.Glob(filepath.Join(dir, globEscape(stem)+"*.in.csv"))
+	if err != nil {
+		return nil, err
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".in.csv")
+		if name == stem {
+			name = "default"
+		} else if strings.HasPrefix(name, stem+".") {
+			name = strings.TrimPrefix(name, stem+".")
+		} else {
+			// belongs to another recipe whose name starts with this one
+			continue
+		}
+
+		inputData, err := ioutil.ReadFile(input)
+		if err != nil {
+			return nil, err
+		}
+		output := strings.TrimSuffix(input, ".in.csv") + ".out.csv"
+		outputData, err := ioutil.ReadFile(output)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("test input %s has no expected output %s", input, output)
+			}
+			return nil, err
+		}
+		cases = append(cases, Case{
+			Name:     name,
+			Input:    string(inputData),
+			Expected: string(outputData),
+			Lines:    -1,
+			Source:   input,
+		})
+	}
+
+	for _, ext := range []string{".test.yaml", ".test.yml"} {
+		path := filepath.Join(dir, stem+ext)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var file testFile
+		if err := yaml.UnmarshalStrict(data, &file); err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", path, err)
+		}
+		for i, c := range file.Cases {
+			if c.Name == "" {
+				c.Name = fmt.Sprintf("case %d", i+1)
+			}
+			if c.Lines == 0 {
+				c.Lines = -1
+			}
+			c.Source = path
+			cases = append(cases, c)
+		}
+	}
+
+	sort.SliceStable(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+func globEscape(s string) string {
+	replacer := strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`, `\`, `\\`)
+	return replacer.Replace(s)
+}
+
+// Result is the outcome of running one Case.
+type Result struct {
+	Case     Case
+	Duration time.Duration
+	// Err is set when the recipe could not be run at all.
+	Err   error
+	Diffs []CellDiff
+}
+
+// Passed reports whether the case ran and produced the expected output.
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Diffs) == 0
+}
+
+// Run bakes the case input with the recipe source and compares the output
+// with the expected output. The recipe is parsed fresh for every case so no
+// state carries over between cases.
+func Run(recipeSource string, c Case) Result {
+	start := time.Now()
+	result := Result{Case: c}
+	actual, err := bake(recipeSource, c)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	expectedReader := csv.NewReader(strings.NewReader(c.Expected))
+	expectedReader.FieldsPerRecord = -1
+	expected, err := expectedReader.ReadAll()
+	if err != nil {
+		result.Err = fmt.Errorf("unable to read expected output: %v", err)
+		return result
+	}
+	result.Diffs = Diff(expected, actual)
+	return result
+}
+
+func bake(recipeSource string, c Case) ([][]string, error) {
+	transformer, err := recipe.Parse(strings.NewReader(recipeSource))
+	if err != nil {
+		return nil, err
+	}
+	if err := transformer.BindParameters(c.Parameters, nil); err != nil {
+		return nil, err
+	}
+
+	lines := c.Lines
+	if lines > 0 && !c.NoHeader {
+		lines++
+	}
+
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	if _, err := transformer.Execute(csv.NewReader(strings.NewReader(c.Input)), w, !c.NoHeader, lines, true); err != nil {
+		return nil, err
+	}
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return csv.NewReader(&out).ReadAll()
+}
@@ -0,0 +1,213 @@
+package recipetest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "recipetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "fixture pairs and yaml cases",
+			files: map[string]string{
+				"clean.txt":             "1 <- 1\n",
+				"clean.in.csv":          "a\n",
+				"clean.out.csv":         "a\n",
+				"clean.upper.in.csv":    "b\n",
+				"clean.upper.out.csv":   "b\n",
+				"cleaner.other.in.csv":  "c\n",
+				"cleaner.other.out.csv": "c\n",
+				"clean.test.yaml":       "cases:\n  - name: inline\n    input: \"a\\n\"\n    output: \"a\\n\"\n  - input: \"b\\n\"\n    output: \"b\\n\"\n",
+			},
+			wantNames: []string{"case 2", "default", "inline", "upper"},
+		},
+		{
+			name:      "no cases",
+			files:     map[string]string{"clean.txt": "1 <- 1\n"},
+			wantNames: nil,
+		},
+		{
+			name: "missing expected output",
+			files: map[string]string{
+				"clean.txt":         "1 <- 1\n",
+				"clean.only.in.csv": "a\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown yaml field",
+			files: map[string]string{
+				"clean.txt":       "1 <- 1\n",
+				"clean.test.yaml": "cases:\n  - name: x\n    inptu: a\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			cases, err := Discover(filepath.Join(dir, "clean.txt"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, c := range cases {
+				names = append(names, c.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Discover() names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipe    string
+		c         Case
+		wantDiffs []CellDiff
+		wantErr   bool
+	}{
+		{
+			name:   "passes",
+			recipe: "1 <- 1 -> uppercase\n!1 <- \"NAME\"\n",
+			c:      Case{Input: "name\nbob\n", Expected: "NAME\nBOB\n", Lines: -1},
+		},
+		{
+			name:   "cell differs",
+			recipe: "1 <- 1\n2 <- 2 -> lowercase\n",
+			c:      Case{Input: "a,B\nc,D\n", Expected: "a,B\nc,D\n", Lines: -1},
+			wantDiffs: []CellDiff{
+				{Row: 2, Column: 2, Expected: "D", Actual: "d"},
+			},
+		},
+		{
+			name:   "parameters and line limit",
+			recipe: "1 <- %who\n",
+			c:      Case{Input: "h\n1\n2\n", Expected: "h\nacme\n", Parameters: map[string]string{"who": "acme"}, Lines: 1},
+		},
+		{
+			name:   "no header",
+			recipe: "1 <- 1 -> uppercase\n",
+			c:      Case{Input: "a\n", Expected: "A\n", NoHeader: true, Lines: -1},
+		},
+		{
+			name:   "missing row",
+			recipe: "1 <- 1\n",
+			c:      Case{Input: "a\n", Expected: "a\nb\n", Lines: -1},
+			wantDiffs: []CellDiff{
+				{Row: 2, Expected: `["b"]`},
+			},
+		},
+		{
+			name:    "recipe error",
+			recipe:  "1 <- 1 -> nosuchfunc\n",
+			c:       Case{Input: "a\n", Expected: "a\n", Lines: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Run(tt.recipe, tt.c)
+			if (got.Err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", got.Err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got.Diffs, tt.wantDiffs) {
+				t.Errorf("Run() diffs = %v, want %v", got.Diffs, tt.wantDiffs)
+			}
+			if got.Passed() != (tt.wantDiffs == nil && !tt.wantErr) {
+				t.Errorf("Run() passed = %v", got.Passed())
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected [][]string
+		actual   [][]string
+		want     []CellDiff
+	}{
+		{
+			name:     "same",
+			expected: [][]string{{"a", "b"}},
+			actual:   [][]string{{"a", "b"}},
+		},
+		{
+			name:     "extra row and extra cell",
+			expected: [][]string{{"a"}},
+			actual:   [][]string{{"a", "b"}, {"c"}},
+			want: []CellDiff{
+				{Row: 1, Column: 2, Actual: "b"},
+				{Row: 2, Actual: `["c"]`},
+			},
+		},
+		{
+			name:     "missing cell",
+			expected: [][]string{{"a", ""}},
+			actual:   [][]string{{"a"}},
+			want: []CellDiff{
+				{Row: 1, Column: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.expected, tt.actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	suites := []Suite{{
+		Recipe: "clean.txt",
+		Results: []Result{
+			{Case: Case{Name: "ok"}},
+			{Case: Case{Name: "bad"}, Diffs: []CellDiff{{Row: 1, Column: 1, Expected: "a", Actual: "b"}}},
+		},
+	}}
+	var b bytes.Buffer
+	if err := WriteJUnit(&b, suites); err != nil {
+		t.Fatalf("WriteJUnit() unexpected error: %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		`<testsuites tests="2" failures="1" errors="0"`,
+		`<testsuite name="clean.txt" tests="2" failures="1"`,
+		`<testcase name="ok" classname="clean.txt"`,
+		`row 1, column 1: expected &#34;a&#34;, got &#34;b&#34;</failure>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteJUnit() output missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package repl implements an interactive session for building a recipe one
+// line at a time. Each recipe line typed is parsed together with the lines
+// already accepted and evaluated against a handful of input rows so the
+// result can be seen straight away.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dstockto/csv-chef/recipe"
+)
+
+const help = `Type a recipe line, such as 1 <- 3 -> uppercase, to try it against the
+input rows. Lines that parse are accepted; typing a line for a column,
+header or variable that was already accepted replaces it.
+
+Commands:
+  :show          list the accepted recipe lines
+  :undo          take back the most recently accepted line, restoring any
+                 line it replaced
+  :history       list everything typed this session
+  :save <file>   write the accepted lines to a recipe file (:save! overwrites)
+  :help          show this help
+  :quit          leave the session
+`
+
+type acceptedLine struct {
+	target string
+	text   string
+}
+
+// undoStep is what :undo needs to take back an accepted line: the lines
+// accepted before it, and the line it replaced, if any.
+type undoStep struct {
+	previous []acceptedLine
+	line     string
+	replaced string
+}
+
+// Session holds the state of one REPL session.
+type Session struct {
+	rows   [][]string
+	header bool
+	out    io.Writer
+
+	// Parameters and LookupEnv supply recipe parameter values just as
+	// --set and the environment do for bake.
+	Parameters map[string]string
+	LookupEnv  func(string) (string, bool)
+	// HistoryWriter, if set, receives every line typed.
+	HistoryWriter io.Writer
+
+	accepted []acceptedLine
+	undo     []undoStep
+	history  []string
+}
+
+// New returns a session that evaluates recipe lines against rows. If header
+// is true the first row is the header row.
+func New(rows [][]string, header bool, out io.Writer) *Session {
+	return &Session{rows: rows, header: header, out: out}
+}
+
+// Run reads lines from in until it is exhausted or :quit is entered.
+func (s *Session) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		s.printf("> ")
+		if !scanner.Scan() {
+			s.printf("\n")
+			return scanner.Err()
+		}
+		if !s.Eval(scanner.Text()) {
+			return nil
+		}
+	}
+}
+
+func (s *Session) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(s.out, format, args...)
+}
+
+// Recipe returns the accepted lines as recipe text.
+func (s *Session) Recipe() string {
+	var b strings.Builder
+	for _, line := range s.accepted {
+		b.WriteString(line.text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Eval handles a single line of input. It returns false once the session
+// should end.
+func (s *Session) Eval(input string) bool {
+	line := strings.TrimSpace(input)
+	if line == "" {
+		return true
+	}
+	s.history = append(s.history, line)
+	if s.HistoryWriter != nil {
+		_, _ = fmt.Fprintln(s.HistoryWriter, line)
+	}
+
+	if strings.HasPrefix(line, ":") {
+		return s.command(line)
+	}
+	s.evalRecipeLine(line)
+	return true
+}
+
+func (s *Session) command(line string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ":quit", ":exit", ":q":
+		return false
+	case ":help":
+		s.printf("%s", help)
+	case ":show":
+		if len(s.accepted) == 0 {
+			s.printf("No recipe lines accepted yet\n")
+		}
+		s.printf("%s", s.Recipe())
+	case ":undo":
+		if len(s.undo) == 0 {
+			s.printf("Nothing to undo\n")
+			break
+		}
+		step := s.undo[len(s.undo)-1]
+		s.undo = s.undo[:len(s.undo)-1]
+		s.accepted = step.previous
+		s.printf("Removed: %s\n", step.line)
+		if step.replaced != "" {
+			s.printf("Restored: %s\n", step.replaced)
+		}
+	case ":history":
+		for i, h := range s.history {
+			s.printf("%4d  %s\n", i+1, h)
+		}
+	case ":save", ":save!":
+		if len(fields) != 2 {
+			s.printf("Usage: %s <file>\n", fields[0])
+			break
+		}
+		if err := s.save(fields[1], fields[0] == ":save!"); err != nil {
+			s.printf("Error: %v\n", err)
+			break
+		}
+		s.printf("Saved %d line(s) to %s\n", len(s.accepted), fields[1])
+	default:
+		s.printf("Unknown command %s, type :help for a list of commands\n", fields[0])
+	}
+	return true
+}
+
+func (s *Session) save(path string, overwrite bool) error {
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return fmt.Errorf("%s already exists, use :save! to overwrite it", path)
+	}
+	return ioutil.WriteFile(path, []byte(s.Recipe()), 0644)
+}
+
+// lineTarget parses a recipe line on its own to find out what it assigns.
+// The key identifies the line so a later line for the same target replaces
+// it; output is what to preview, or nil for declarations.
+func lineTarget(line string) (string, *recipe.Output, error) {
+	t, err := recipe.Parse(strings.NewReader(line))
+	if err != nil {
+		return "", nil, err
+	}
+	for c, r := range t.Columns {
+		return fmt.Sprintf("column %d", c), &r.Output, nil
+	}
+	for h, r := range t.Headers {
+		return fmt.Sprintf("header %d", h), &r.Output, nil
+	}
+	for name, r := range t.Variables {
+		return name, &r.Output, nil
+	}
+	if t.Explode != nil {
+		return "explode", &t.Explode.Recipe.Output, nil
+	}
+	for name, param := range t.Parameters {
+		if param.Declared {
+			return "parameter " + name, nil, nil
+		}
+	}
+	for name := range t.PersistentInit {
+		return "init " + name, nil, nil
+	}
+	return "", nil, nil
+}
+
+// printError shows an error, pointing at the problem in the line for parse
+// errors.
+func (s *Session) printError(err error) {
+	if parseErr, ok := err.(*recipe.ParseError); ok {
+		s.printf("Error: %s", parseErr.Render())
+		return
+	}
+	s.printf("Error: %v\n", err)
+}
+
+func (s *Session) evalRecipeLine(line string) {
+	key, target, err := lineTarget(line)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	if key == "" {
+		// only a comment
+		return
+	}
+
+	accepted := make([]acceptedLine, 0, len(s.accepted)+1)
+	replaced := ""
+	for _, a := range s.accepted {
+		if a.target == key {
+			replaced = a.text
+			a.text = line
+		}
+		accepted = append(accepted, a)
+	}
+	if replaced == "" {
+		accepted = append(accepted, acceptedLine{target: key, text: line})
+	}
+
+	var b strings.Builder
+	for _, a := range accepted {
+		b.WriteString(a.text + "\n")
+	}
+	t, err := recipe.Parse(strings.NewReader(b.String()))
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	s.undo = append(s.undo, undoStep{previous: s.accepted, line: line, replaced: replaced})
+	s.accepted = accepted
+	if target == nil {
+		s.printf("Accepted\n")
+		return
+	}
+
+	if err := t.BindParameters(s.Parameters, s.LookupEnv); err != nil {
+		s.printf("Error: %v\n", err)
+		return
+	}
+	results, err := t.Preview(s.rows, s.header, *target)
+	if err != nil {
+		s.printf("Error: %v\n", err)
+		return
+	}
+	if len(results) == 0 {
+		s.printf("No rows to show\n")
+	}
+	for _, r := range results {
+		label := fmt.Sprintf("row %d", r.LineNo)
+		if r.Header {
+			label = "header"
+		}
+		if r.Element > 0 {
+			label = fmt.Sprintf("%s.%d", label, r.Element)
+		}
+		if r.Err != nil {
+			s.printf("  %s: error: %v\n", label, r.Err)
+			continue
+		}
+		s.printf("  %s: %q\n", label, r.Value)
+	}
+}
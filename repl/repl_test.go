@@ -0,0 +1,130 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var rows = [][]string{
+	{"name", "date"},
+	{"ann", "03/04/2021"},
+	{"bob", "12/25/2020"},
+}
+
+func TestSession_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput []string
+		wantRecipe string
+	}{
+		{
+			name:  "evaluates lines against rows",
+			input: "1 <- 2 -> readDate(\"01/02/2006\") -> formatDate(\"Jan 2\")\n",
+			wantOutput: []string{
+				"  row 2: \"Mar 4\"\n",
+				"  row 3: \"Dec 25\"\n",
+			},
+			wantRecipe: "1 <- 2 -> readDate(\"01/02/2006\") -> formatDate(\"Jan 2\")\n",
+		},
+		{
+			name:       "parse errors are not accepted",
+			input:      "1 <- 1 -> nosuchfunction\n",
+			wantOutput: []string{"Error: line 1, column 11: unrecognized function nosuchfunction\n    1 <- 1 -> nosuchfunction\n              ^\n"},
+			wantRecipe: "",
+		},
+		{
+			name:       "redefining a target replaces it",
+			input:      "$n <- 1\n1 <- $n\n$n <- 1 -> uppercase\n",
+			wantOutput: []string{"  row 2: \"ANN\"\n"},
+			wantRecipe: "$n <- 1 -> uppercase\n1 <- $n\n",
+		},
+		{
+			name:       "undo",
+			input:      "1 <- 1\n2 <- 2\n:undo\n",
+			wantOutput: []string{"Removed: 2 <- 2\n"},
+			wantRecipe: "1 <- 1\n",
+		},
+		{
+			name:       "undo restores a replaced line",
+			input:      "1 <- 1\n2 <- 2\n1 <- 1 -> uppercase\n:undo\n",
+			wantOutput: []string{"Removed: 1 <- 1 -> uppercase\nRestored: 1 <- 1\n"},
+			wantRecipe: "1 <- 1\n2 <- 2\n",
+		},
+		{
+			name:       "undo goes back one line at a time",
+			input:      "1 <- 1\n1 <- 2\n:undo\n:undo\n:undo\n",
+			wantOutput: []string{"Restored: 1 <- 1\n", "Removed: 1 <- 1\n", "Nothing to undo\n"},
+			wantRecipe: "",
+		},
+		{
+			name:       "parameters and declarations",
+			input:      "%who = \"me\"\n1 <- %who\n",
+			wantOutput: []string{"Accepted\n", "  row 2: \"me\"\n"},
+			wantRecipe: "%who = \"me\"\n1 <- %who\n",
+		},
+		{
+			name:       "history and quit",
+			input:      "1 <- 1\n:history\n:quit\n2 <- 2\n",
+			wantOutput: []string{"   1  1 <- 1\n   2  :history\n"},
+			wantRecipe: "1 <- 1\n",
+		},
+		{
+			name:       "unknown command",
+			input:      ":frobnicate\n",
+			wantOutput: []string{"Unknown command :frobnicate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			s := New(rows, true, &out)
+			if err := s.Run(strings.NewReader(tt.input)); err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Run() output missing %q:\n%s", want, out.String())
+				}
+			}
+			if got := s.Recipe(); got != tt.wantRecipe {
+				t.Errorf("Recipe() = %q, want %q", got, tt.wantRecipe)
+			}
+		})
+	}
+}
+
+func TestSession_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "recipe.txt")
+
+	var out, history bytes.Buffer
+	s := New(rows, true, &out)
+	s.HistoryWriter = &history
+	input := "1 <- 1\n:save " + path + "\n2 <- 2\n:save " + path + "\n:save! " + path + "\n"
+	if err := s.Run(strings.NewReader(input)); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "already exists, use :save! to overwrite it") {
+		t.Errorf("expected :save to refuse to overwrite, got:\n%s", out.String())
+	}
+	saved, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != "1 <- 1\n2 <- 2\n" {
+		t.Errorf("saved recipe = %q", saved)
+	}
+	if history.String() != input {
+		t.Errorf("history = %q, want %q", history.String(), input)
+	}
+}